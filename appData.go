@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// appDataDir returns (and creates if needed) a per-user directory Gopad can keep its state in
+func appDataDir(subDir string) (string, error) {
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir = filepath.Join(dir, "gopad", subDir)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// writeFileAtomic writes data to a temp file next to fPath then renames it over fPath,
+// so readers never see a half written file
+func writeFileAtomic(fPath string, data []byte, perm os.FileMode) error {
+
+	tmpPath, err := writeTempFile(fPath, data, perm)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPath, fPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+// writeTempFile writes data to a new temp file next to fPath, which can later be renamed over fPath.
+// The temp file is synced to disk and has its permissions set to perm.
+func writeTempFile(fPath string, data []byte, perm os.FileMode) (tmpPath string, err error) {
+
+	f, err := os.CreateTemp(filepath.Dir(fPath), filepath.Base(fPath)+".tmp*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath = f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	return tmpPath, nil
+}
@@ -0,0 +1,731 @@
+package buffer
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxPieceSize bounds how many bytes a single piece can hold. Anything that needs to look inside
+// a piece (splitting it, finding the n-th newline etc.) only ever scans at most this many bytes,
+// which is what keeps all operations at O(log n) no matter how large the file is.
+const maxPieceSize = 16 * 1024
+
+// counts are the totals we track for every piece and subtree
+type counts struct {
+	runes    int
+	tabs     int
+	newlines int
+}
+
+func (c *counts) add(other counts) {
+	c.runes += other.runes
+	c.tabs += other.tabs
+	c.newlines += other.newlines
+}
+
+func countBytes(bs []byte) counts {
+	return counts{
+		runes:    utf8.RuneCount(bs),
+		tabs:     bytes.Count(bs, []byte{'\t'}),
+		newlines: bytes.Count(bs, []byte{'\n'}),
+	}
+}
+
+type piece struct {
+	// isAdd is true if the piece points into the add buffer, otherwise it points into the original buffer
+	isAdd bool
+	start int
+	len   int
+
+	// Pieces never start or end in the middle of a rune, so rune counts of pieces can simply be summed
+	counts
+}
+
+// node is a piece stored in a treap that is ordered by position in the document (implicit keys).
+// Every node keeps the byte, rune, tab and newline totals of its subtree so we can descend by any of them.
+type node struct {
+	p     piece
+	prio  uint32
+	left  *node
+	right *node
+
+	size int
+	sub  counts
+}
+
+func (n *node) Size() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *node) Counts() counts {
+	if n == nil {
+		return counts{}
+	}
+	return n.sub
+}
+
+func (n *node) Lines() int {
+	return n.Counts().newlines
+}
+
+func (n *node) Runes() int {
+	return n.Counts().runes
+}
+
+func (n *node) update() {
+
+	n.size = n.left.Size() + n.p.len + n.right.Size()
+
+	n.sub = n.left.Counts()
+	n.sub.add(n.p.counts)
+	n.sub.add(n.right.Counts())
+}
+
+// Buffer is a piece table whose pieces are kept in a balanced tree.
+//
+// The original file bytes are never modified or copied, and everything typed is appended to a separate
+// add buffer. The document is the in-order sequence of pieces pointing into these two buffers, so an
+// insert or delete anywhere only touches O(log n) nodes, and line<->offset lookups are O(log n) as well.
+//
+// All offsets are byte offsets into the UTF-8 encoded document. Lines are separated by '\n', and a document
+// always has at least one (possibly empty) line.
+type Buffer struct {
+	orig []byte
+	add  []byte
+	root *node
+
+	randState uint32
+
+	//listeners are called after every change
+	listeners      []listener
+	lastListenerID ListenerID
+
+	//version goes up by one with every change
+	version int
+}
+
+// Len returns the document size in bytes
+func (b *Buffer) Len() int {
+	return b.root.Size()
+}
+
+// LineCount returns the number of lines in the document, which is always at least 1
+func (b *Buffer) LineCount() int {
+	return b.root.Lines() + 1
+}
+
+// RuneCount returns the number of runes in the document
+func (b *Buffer) RuneCount() int {
+	return b.root.Runes()
+}
+
+// Insert places text at byte offset off. Offsets outside the document are clamped to it.
+func (b *Buffer) Insert(off int, text []byte) {
+
+	if len(text) == 0 {
+		return
+	}
+	off = clampInt(off, 0, b.Len())
+	defer b.notify(off, 0, len(text))
+
+	left, right := b.split(b.root, off)
+
+	//Typing one char at a time produces lots of tiny consecutive appends, so instead of creating a new
+	//piece for each we grow the last piece if it happens to end at the end of the add buffer
+	if b.extendLast(left, text) {
+		b.add = append(b.add, text...)
+		b.root = b.merge(left, right)
+		return
+	}
+
+	for len(text) > 0 {
+
+		n := chunkLen(text, maxPieceSize)
+		start := len(b.add)
+		b.add = append(b.add, text[:n]...)
+		left = b.merge(left, b.newNode(b.newPiece(true, start, n)))
+
+		text = text[n:]
+	}
+
+	b.root = b.merge(left, right)
+}
+
+// InsertString is like Insert but takes a string
+func (b *Buffer) InsertString(off int, text string) {
+	b.Insert(off, []byte(text))
+}
+
+// Delete removes count bytes starting at byte offset off. Ranges outside the document are clamped to it.
+func (b *Buffer) Delete(off, count int) {
+
+	off = clampInt(off, 0, b.Len())
+	count = clampInt(count, 0, b.Len()-off)
+	if count == 0 {
+		return
+	}
+	defer b.notify(off, count, 0)
+
+	left, right := b.split(b.root, off)
+	_, right = b.split(right, count)
+	b.root = b.merge(left, right)
+}
+
+// ListenerID identifies a function registered with Listen
+type ListenerID int
+
+type listener struct {
+	id ListenerID
+	fn func(off, removed, inserted int)
+}
+
+// Listen registers fn to be called after every change to the buffer, with the byte offset the change starts at
+// and how many bytes were removed and inserted there.
+// This lets things derived from the contents (e.g. syntax highlighting) redo only the parts affected by the change.
+// The returned id is used to stop listening with Unlisten.
+func (b *Buffer) Listen(fn func(off, removed, inserted int)) ListenerID {
+	b.lastListenerID++
+	b.listeners = append(b.listeners, listener{id: b.lastListenerID, fn: fn})
+	return b.lastListenerID
+}
+
+// Unlisten stops calling the function registered with Listen that got id
+func (b *Buffer) Unlisten(id ListenerID) {
+
+	for i, l := range b.listeners {
+		if l.id == id {
+			b.listeners = append(b.listeners[:i], b.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// Version returns a number that changes whenever the contents change, which is cheaper than comparing contents
+func (b *Buffer) Version() int {
+	return b.version
+}
+
+func (b *Buffer) notify(off, removed, inserted int) {
+
+	b.version++
+	for _, l := range b.listeners {
+		l.fn(off, removed, inserted)
+	}
+}
+
+// Slice returns a copy of count bytes starting at byte offset off
+func (b *Buffer) Slice(off, count int) []byte {
+
+	off = clampInt(off, 0, b.Len())
+	count = clampInt(count, 0, b.Len()-off)
+
+	out := make([]byte, 0, count)
+	b.Walk(off, off+count, func(chunk []byte) bool {
+		out = append(out, chunk...)
+		return true
+	})
+
+	return out
+}
+
+// Bytes returns a copy of the whole document
+func (b *Buffer) Bytes() []byte {
+	return b.Slice(0, b.Len())
+}
+
+func (b *Buffer) String() string {
+	return string(b.Bytes())
+}
+
+// Snapshot is the contents of a buffer at one point in time. It stays the same as the buffer is edited afterwards,
+// and unlike the buffer it can be read from other goroutines.
+type Snapshot struct {
+	chunks [][]byte
+	size   int
+}
+
+// Snapshot returns the current contents without copying them, which only takes a walk over the pieces.
+// This works because the bytes pieces point to are never changed: orig is read only and add is only appended to.
+func (b *Buffer) Snapshot() *Snapshot {
+
+	s := &Snapshot{size: b.Len()}
+	b.Walk(0, b.Len(), func(chunk []byte) bool {
+		//Capping the capacity makes sure nothing can ever append into the add buffer through the snapshot
+		s.chunks = append(s.chunks, chunk[:len(chunk):len(chunk)])
+		return true
+	})
+
+	return s
+}
+
+func (s *Snapshot) Len() int {
+	return s.size
+}
+
+// Bytes returns a copy of the contents
+func (s *Snapshot) Bytes() []byte {
+
+	out := make([]byte, 0, s.size)
+	for _, chunk := range s.chunks {
+		out = append(out, chunk...)
+	}
+
+	return out
+}
+
+func (s *Snapshot) String() string {
+
+	sb := strings.Builder{}
+	sb.Grow(s.size)
+	for _, chunk := range s.chunks {
+		sb.Write(chunk)
+	}
+
+	return sb.String()
+}
+
+// Walk calls fn with consecutive chunks of the document between byte offsets [from, to) without copying them.
+// The chunks must not be modified or kept after fn returns. Returning false from fn stops the walk.
+func (b *Buffer) Walk(from, to int, fn func(chunk []byte) bool) {
+
+	from = clampInt(from, 0, b.Len())
+	to = clampInt(to, from, b.Len())
+	b.walk(b.root, from, to, fn)
+}
+
+func (b *Buffer) walk(n *node, from, to int, fn func(chunk []byte) bool) bool {
+
+	if n == nil || from >= to {
+		return true
+	}
+
+	leftSize := n.left.Size()
+	if from < leftSize {
+		if !b.walk(n.left, from, minInt(to, leftSize), fn) {
+			return false
+		}
+	}
+
+	pieceStart := leftSize
+	pieceEnd := leftSize + n.p.len
+	if from < pieceEnd && to > pieceStart {
+
+		s := maxInt(from, pieceStart) - pieceStart
+		e := minInt(to, pieceEnd) - pieceStart
+		if !fn(b.pieceBytes(n.p)[s:e]) {
+			return false
+		}
+	}
+
+	if to > pieceEnd {
+		return b.walk(n.right, maxInt(from, pieceEnd)-pieceEnd, to-pieceEnd, fn)
+	}
+
+	return true
+}
+
+// Index returns the byte offset of the first occurrence of pattern at or after from, or -1 if there is none
+func (b *Buffer) Index(from int, pattern []byte) int {
+
+	from = clampInt(from, 0, b.Len())
+	if len(pattern) == 0 {
+		return from
+	}
+
+	//Every chunk is searched with the end of the previous chunk in front of it, so matches that span pieces are found too
+	found := -1
+	window := make([]byte, 0, 2*len(pattern))
+	windowStart := from
+	b.Walk(from, b.Len(), func(chunk []byte) bool {
+
+		window = append(window, chunk...)
+		if i := bytes.Index(window, pattern); i != -1 {
+			found = windowStart + i
+			return false
+		}
+
+		keep := minInt(len(pattern)-1, len(window))
+		windowStart += len(window) - keep
+		window = append(window[:0], window[len(window)-keep:]...)
+		return true
+	})
+
+	return found
+}
+
+// WriteTo writes the whole document to w piece by piece, without building the full document in memory
+func (b *Buffer) WriteTo(w io.Writer) (written int64, err error) {
+
+	b.Walk(0, b.Len(), func(chunk []byte) bool {
+		var n int
+		n, err = w.Write(chunk)
+		written += int64(n)
+		return err == nil
+	})
+
+	return written, err
+}
+
+// LineStart returns the byte offset of the first byte of the given line.
+// Lines past the end of the document return Len().
+func (b *Buffer) LineStart(line int) int {
+
+	if line <= 0 {
+		return 0
+	}
+
+	if line > b.root.Lines() {
+		return b.Len()
+	}
+
+	//We are looking for the byte right after the line-th newline
+	off := 0
+	n := b.root
+	for n != nil {
+
+		leftLines := n.left.Lines()
+		if line <= leftLines {
+			n = n.left
+			continue
+		}
+
+		line -= leftLines
+		off += n.left.Size()
+		if line <= n.p.newlines {
+			return off + nthIndexByte(b.pieceBytes(n.p), '\n', line) + 1
+		}
+
+		line -= n.p.newlines
+		off += n.p.len
+		n = n.right
+	}
+
+	return b.Len()
+}
+
+// LineEnd returns the byte offset right after the last char of the given line, not including the newline
+func (b *Buffer) LineEnd(line int) int {
+
+	if line+1 >= b.LineCount() {
+		return b.Len()
+	}
+
+	return b.LineStart(line+1) - 1
+}
+
+// Line returns a copy of the given line without its newline
+func (b *Buffer) Line(line int) []byte {
+	start := b.LineStart(line)
+	return b.Slice(start, b.LineEnd(line)-start)
+}
+
+// OffsetToLine returns the line the byte at off belongs to
+func (b *Buffer) OffsetToLine(off int) int {
+	return b.countsBefore(off).newlines
+}
+
+// RuneOffset returns how many runes come before byte offset off
+func (b *Buffer) RuneOffset(off int) int {
+	return b.countsBefore(off).runes
+}
+
+// TabCount returns how many tabs are between the byte offsets [from, to)
+func (b *Buffer) TabCount(from, to int) int {
+
+	if to <= from {
+		return 0
+	}
+
+	return b.countsBefore(to).tabs - b.countsBefore(from).tabs
+}
+
+// ByteOffset returns the byte offset of the rune at rune offset runeOff.
+// Rune offsets past the end of the document return Len().
+func (b *Buffer) ByteOffset(runeOff int) int {
+
+	if runeOff <= 0 {
+		return 0
+	}
+
+	if runeOff >= b.root.Runes() {
+		return b.Len()
+	}
+
+	off := 0
+	n := b.root
+	for n != nil {
+
+		leftRunes := n.left.Runes()
+		if runeOff < leftRunes {
+			n = n.left
+			continue
+		}
+
+		runeOff -= leftRunes
+		off += n.left.Size()
+		if runeOff < n.p.runes {
+			return off + runeIndexToByte(b.pieceBytes(n.p), runeOff)
+		}
+
+		runeOff -= n.p.runes
+		off += n.p.len
+		n = n.right
+	}
+
+	return b.Len()
+}
+
+// countsBefore returns the totals of everything between the document start and byte offset off
+func (b *Buffer) countsBefore(off int) counts {
+
+	off = clampInt(off, 0, b.Len())
+
+	c := counts{}
+	n := b.root
+	for n != nil {
+
+		leftSize := n.left.Size()
+		if off < leftSize {
+			n = n.left
+			continue
+		}
+
+		c.add(n.left.Counts())
+		off -= leftSize
+		if off < n.p.len {
+			c.add(countBytes(b.pieceBytes(n.p)[:off]))
+			return c
+		}
+
+		c.add(n.p.counts)
+		off -= n.p.len
+		n = n.right
+	}
+
+	return c
+}
+
+func (b *Buffer) pieceBytes(p piece) []byte {
+
+	if p.isAdd {
+		return b.add[p.start : p.start+p.len]
+	}
+
+	return b.orig[p.start : p.start+p.len]
+}
+
+func (b *Buffer) newPiece(isAdd bool, start, length int) piece {
+
+	p := piece{
+		isAdd: isAdd,
+		start: start,
+		len:   length,
+	}
+	p.counts = countBytes(b.pieceBytes(p))
+
+	return p
+}
+
+func (b *Buffer) newNode(p piece) *node {
+
+	n := &node{
+		p:    p,
+		prio: b.nextRand(),
+	}
+	n.update()
+
+	return n
+}
+
+// split divides the tree into two trees holding the first off bytes and the rest.
+// If off falls inside a piece that piece is cut in two.
+func (b *Buffer) split(n *node, off int) (left, right *node) {
+
+	if n == nil {
+		return nil, nil
+	}
+
+	leftSize := n.left.Size()
+	if off <= leftSize {
+		left, n.left = b.split(n.left, off)
+		n.update()
+		return left, n
+	}
+
+	if off >= leftSize+n.p.len {
+		n.right, right = b.split(n.right, off-leftSize-n.p.len)
+		n.update()
+		return n, right
+	}
+
+	inner := off - leftSize
+	rightNode := b.newNode(b.newPiece(n.p.isAdd, n.p.start+inner, n.p.len-inner))
+	n.p = b.newPiece(n.p.isAdd, n.p.start, inner)
+
+	right = b.merge(rightNode, n.right)
+	n.right = nil
+	n.update()
+
+	return n, right
+}
+
+// merge joins two trees where every piece in left comes before every piece in right
+func (b *Buffer) merge(left, right *node) *node {
+
+	if left == nil {
+		return right
+	}
+
+	if right == nil {
+		return left
+	}
+
+	if left.prio > right.prio {
+		left.right = b.merge(left.right, right)
+		left.update()
+		return left
+	}
+
+	right.left = b.merge(left, right.left)
+	right.update()
+	return right
+}
+
+// extendLast grows the last piece of the tree by text if that piece ends exactly at the end of the add buffer.
+// The caller is responsible for appending text to the add buffer when this returns true.
+func (b *Buffer) extendLast(n *node, text []byte) bool {
+
+	if n == nil {
+		return false
+	}
+
+	if n.right != nil {
+
+		if !b.extendLast(n.right, text) {
+			return false
+		}
+
+		n.update()
+		return true
+	}
+
+	if !n.p.isAdd || n.p.start+n.p.len != len(b.add) || n.p.len+len(text) > maxPieceSize {
+		return false
+	}
+
+	n.p.len += len(text)
+	n.p.counts.add(countBytes(text))
+	n.update()
+	return true
+}
+
+// nextRand is a xorshift generator used for treap priorities
+func (b *Buffer) nextRand() uint32 {
+	x := b.randState
+	x ^= x << 13
+	x ^= x >> 17
+	x ^= x << 5
+	b.randState = x
+	return x
+}
+
+// chunkLen returns how many bytes of text can go in one piece without cutting a rune in half
+func chunkLen(text []byte, max int) int {
+
+	if len(text) <= max {
+		return len(text)
+	}
+
+	n := max
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+
+	if n == 0 {
+		return max
+	}
+
+	return n
+}
+
+// runeIndexToByte returns the byte index of the n-th (0-based) rune in s
+func runeIndexToByte(s []byte, n int) int {
+
+	i := 0
+	for n > 0 && i < len(s) {
+		_, size := utf8.DecodeRune(s[i:])
+		i += size
+		n--
+	}
+
+	return i
+}
+
+// nthIndexByte returns the index of the n-th (1-based) occurrence of c in s, or -1
+func nthIndexByte(s []byte, c byte, n int) int {
+
+	off := 0
+	for {
+
+		i := bytes.IndexByte(s[off:], c)
+		if i == -1 {
+			return -1
+		}
+
+		n--
+		if n == 0 {
+			return off + i
+		}
+
+		off += i + 1
+	}
+}
+
+func clampInt(x, min, max int) int {
+
+	if x > max {
+		return max
+	}
+
+	if x < min {
+		return min
+	}
+
+	return x
+}
+
+func minInt(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+func maxInt(x, y int) int {
+	if x > y {
+		return x
+	}
+	return y
+}
+
+// New creates a buffer that uses orig as the original document. orig is not copied and must not be modified afterwards.
+func New(orig []byte) *Buffer {
+
+	b := &Buffer{
+		orig:      orig,
+		randState: 2463534242,
+	}
+
+	for start := 0; start < len(orig); {
+		n := chunkLen(orig[start:], maxPieceSize)
+		b.root = b.merge(b.root, b.newNode(b.newPiece(false, start, n)))
+		start += n
+	}
+
+	return b
+}
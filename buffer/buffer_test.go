@@ -0,0 +1,419 @@
+package buffer
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestBufferInsertDelete(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		orig   string
+		edit   func(b *Buffer)
+		expect string
+	}{
+		{"insert into empty", "", func(b *Buffer) { b.InsertString(0, "abc") }, "abc"},
+		{"insert at start", "world", func(b *Buffer) { b.InsertString(0, "hello ") }, "hello world"},
+		{"insert in middle", "helo", func(b *Buffer) { b.InsertString(2, "l") }, "hello"},
+		{"insert at end", "hello", func(b *Buffer) { b.InsertString(5, "!\n") }, "hello!\n"},
+		{"insert past end is clamped", "ab", func(b *Buffer) { b.InsertString(10, "c") }, "abc"},
+		{"insert before start is clamped", "bc", func(b *Buffer) { b.InsertString(-3, "a") }, "abc"},
+		{"insert multi-byte", "añb", func(b *Buffer) { b.InsertString(3, "日本") }, "añ日本b"},
+		{"delete at start", "hello", func(b *Buffer) { b.Delete(0, 2) }, "llo"},
+		{"delete in middle", "hello", func(b *Buffer) { b.Delete(1, 3) }, "ho"},
+		{"delete at end", "hello", func(b *Buffer) { b.Delete(3, 2) }, "hel"},
+		{"delete past end is clamped", "hello", func(b *Buffer) { b.Delete(3, 100) }, "hel"},
+		{"delete everything", "a\nb\n", func(b *Buffer) { b.Delete(0, 4) }, ""},
+		{"delete multi-byte", "a日b", func(b *Buffer) { b.Delete(1, 3) }, "ab"},
+		{"delete across pieces", "abcdef", func(b *Buffer) {
+			b.InsertString(3, "XYZ")
+			b.Delete(2, 5)
+		}, "abef"},
+		{"typing extends the last piece", "", func(b *Buffer) {
+			for i, c := range "typing" {
+				b.InsertString(i, string(c))
+			}
+		}, "typing"},
+	}
+
+	for _, tt := range tests {
+
+		b := New([]byte(tt.orig))
+		tt.edit(b)
+		if b.String() != tt.expect {
+			t.Errorf("%s: expected %q but got %q", tt.name, tt.expect, b.String())
+		}
+
+		if b.RuneCount() != utf8.RuneCountInString(tt.expect) {
+			t.Errorf("%s: expected %d runes but got %d", tt.name, utf8.RuneCountInString(tt.expect), b.RuneCount())
+		}
+
+		if b.LineCount() != strings.Count(tt.expect, "\n")+1 {
+			t.Errorf("%s: expected %d lines but got %d", tt.name, strings.Count(tt.expect, "\n")+1, b.LineCount())
+		}
+	}
+}
+
+func TestBufferLines(t *testing.T) {
+
+	b := New([]byte("ab\n\tñ\n\nlast"))
+
+	lines := []struct {
+		start, end int
+		text       string
+	}{
+		{0, 2, "ab"},
+		{3, 6, "\tñ"},
+		{7, 7, ""},
+		{8, 12, "last"},
+	}
+
+	if b.LineCount() != len(lines) {
+		t.Fatalf("expected %d lines but got %d", len(lines), b.LineCount())
+	}
+
+	for i, l := range lines {
+
+		if b.LineStart(i) != l.start || b.LineEnd(i) != l.end {
+			t.Errorf("line %d: expected [%d, %d) but got [%d, %d)", i, l.start, l.end, b.LineStart(i), b.LineEnd(i))
+		}
+
+		if string(b.Line(i)) != l.text {
+			t.Errorf("line %d: expected %q but got %q", i, l.text, b.Line(i))
+		}
+
+		if b.OffsetToLine(l.end) != i {
+			t.Errorf("line %d: offset %d is on line %d", i, l.end, b.OffsetToLine(l.end))
+		}
+	}
+
+	if b.LineStart(100) != b.Len() {
+		t.Errorf("lines past the end should start at the end, but got %d", b.LineStart(100))
+	}
+
+	//'ñ' takes two bytes, so the byte and rune offsets of everything after it differ by one
+	offsets := []struct{ byteOff, runeOff int }{{0, 0}, {4, 4}, {6, 5}, {12, 11}}
+	for _, o := range offsets {
+
+		if b.RuneOffset(o.byteOff) != o.runeOff {
+			t.Errorf("expected byte offset %d to be rune %d but got %d", o.byteOff, o.runeOff, b.RuneOffset(o.byteOff))
+		}
+
+		if b.ByteOffset(o.runeOff) != o.byteOff {
+			t.Errorf("expected rune offset %d to be byte %d but got %d", o.runeOff, o.byteOff, b.ByteOffset(o.runeOff))
+		}
+	}
+
+	if b.TabCount(0, b.Len()) != 1 || b.TabCount(4, b.Len()) != 0 {
+		t.Errorf("wrong tab counts %d and %d", b.TabCount(0, b.Len()), b.TabCount(4, b.Len()))
+	}
+}
+
+// TestBufferRandomEdits compares random edits against the same edits done on a plain string.
+// Edits at random offsets cut the document into lots of small pieces.
+func TestBufferRandomEdits(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", "\n", "\t", "ñ", "日", "🙂"}
+
+	b := New([]byte{})
+	expect := ""
+	for i := 0; i < 2000; i++ {
+
+		//Offsets are picked at rune starts, since the buffer is never edited in the middle of a rune
+		runes := []rune(expect)
+		at := r.Intn(len(runes) + 1)
+		off := len(string(runes[:at]))
+
+		if r.Intn(3) > 0 || len(runes) == 0 {
+
+			text := ""
+			for n := r.Intn(5) + 1; n > 0; n-- {
+				text += alphabet[r.Intn(len(alphabet))]
+			}
+
+			b.InsertString(off, text)
+			expect = expect[:off] + text + expect[off:]
+		} else {
+
+			count := len(string(runes[at:minInt(len(runes), at+r.Intn(4)+1)]))
+			b.Delete(off, count)
+			expect = expect[:off] + expect[off+count:]
+		}
+
+		if b.String() != expect {
+			t.Fatalf("edit %d: expected %q but got %q", i, expect, b.String())
+		}
+	}
+
+	for i := 0; i < b.LineCount(); i++ {
+
+		expectLine := strings.Split(expect, "\n")[i]
+		if string(b.Line(i)) != expectLine {
+			t.Fatalf("line %d: expected %q but got %q", i, expectLine, b.Line(i))
+		}
+	}
+
+	if idx := b.Index(0, []byte("ñ日")); idx != strings.Index(expect, "ñ日") {
+		t.Errorf("expected index %d but got %d", strings.Index(expect, "ñ日"), idx)
+	}
+}
+
+func TestBufferListeners(t *testing.T) {
+
+	b := New([]byte("abc"))
+
+	var first, second []int
+	firstID := b.Listen(func(off, removed, inserted int) { first = append(first, off, removed, inserted) })
+	b.Listen(func(off, removed, inserted int) { second = append(second, off, removed, inserted) })
+
+	b.InsertString(1, "xy")
+	b.Unlisten(firstID)
+	b.Delete(0, 2)
+
+	if !reflect.DeepEqual(first, []int{1, 0, 2}) {
+		t.Errorf("expected the first listener to only see the insert but got %v", first)
+	}
+
+	if !reflect.DeepEqual(second, []int{1, 0, 2, 0, 2, 0}) {
+		t.Errorf("expected the second listener to see both edits but got %v", second)
+	}
+}
+
+func TestBufferSnapshot(t *testing.T) {
+
+	b := New([]byte("hello world"))
+	b.InsertString(5, ",")
+	snap := b.Snapshot()
+
+	//Typing at the end of the add buffer and deleting pieces must not change what the snapshot has
+	b.InsertString(6, " big")
+	b.Delete(0, 7)
+	b.InsertString(0, strings.Repeat("x", maxPieceSize))
+
+	if snap.String() != "hello, world" || snap.Len() != len("hello, world") {
+		t.Errorf("expected the snapshot to stay 'hello, world' but got %q", snap.String())
+	}
+}
+
+// largeFile is a generated document of about 64MB, which is the size the piece table is meant to stay fast at
+var (
+	largeFileOnce sync.Once
+	largeFile     []byte
+)
+
+const largeFileLines = 1 << 20
+
+func getLargeFile() []byte {
+
+	largeFileOnce.Do(func() {
+
+		sb := strings.Builder{}
+		for i := 0; i < largeFileLines; i++ {
+			sb.WriteString("2022-05-14 12:00:00 INFO\tsome log line with a few words in it: ")
+			sb.WriteString(strings.Repeat("é", i%7))
+			sb.WriteByte('\n')
+		}
+
+		largeFile = []byte(sb.String())
+	})
+
+	return largeFile
+}
+
+func BenchmarkLoad(b *testing.B) {
+
+	data := getLargeFile()
+
+	b.Run("pieceTable", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			buf := New(data)
+			if buf.LineCount() != largeFileLines+1 {
+				b.Fatal("wrong line count", buf.LineCount())
+			}
+		}
+	})
+
+	b.Run("parseLines", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			if _, lineCount := legacyParseLines(string(data)); lineCount != largeFileLines {
+				b.Fatal("wrong line count", lineCount)
+			}
+		}
+	})
+}
+
+func BenchmarkInsert(b *testing.B) {
+
+	data := getLargeFile()
+
+	b.Run("pieceTable", func(b *testing.B) {
+
+		buf := New(data)
+		r := rand.New(rand.NewSource(1))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			line := buf.LineStart(r.Intn(largeFileLines))
+			buf.InsertString(line+10, "x")
+		}
+	})
+
+	b.Run("parseLines", func(b *testing.B) {
+
+		head, _ := legacyParseLines(string(data))
+		r := rand.New(rand.NewSource(1))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			legacyInsert(legacyGetLine(head, r.Intn(largeFileLines)), 10, []rune{'x'})
+		}
+	})
+}
+
+func BenchmarkDelete(b *testing.B) {
+
+	data := getLargeFile()
+
+	b.Run("pieceTable", func(b *testing.B) {
+
+		buf := New(data)
+		r := rand.New(rand.NewSource(1))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+
+			//Lines get shorter as the benchmark deletes from them, so the column is kept inside the line
+			line := r.Intn(largeFileLines)
+			if lineLen := buf.LineEnd(line) - buf.LineStart(line); lineLen > 0 {
+				buf.Delete(buf.LineStart(line)+minInt(10, lineLen-1), 1)
+			}
+		}
+	})
+
+	b.Run("parseLines", func(b *testing.B) {
+
+		head, _ := legacyParseLines(string(data))
+		r := rand.New(rand.NewSource(1))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+
+			l := legacyGetLine(head, r.Intn(largeFileLines))
+			if len(l.chars) > 0 {
+				legacyDelete(l, minInt(10, len(l.chars)-1))
+			}
+		}
+	})
+}
+
+func BenchmarkLineLookup(b *testing.B) {
+
+	data := getLargeFile()
+
+	b.Run("pieceTable", func(b *testing.B) {
+
+		buf := New(data)
+		r := rand.New(rand.NewSource(1))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			line := r.Intn(largeFileLines)
+			start := buf.LineStart(line)
+			if buf.OffsetToLine(start) != line {
+				b.Fatal("wrong line for offset", start)
+			}
+		}
+	})
+
+	b.Run("parseLines", func(b *testing.B) {
+
+		head, _ := legacyParseLines(string(data))
+		r := rand.New(rand.NewSource(1))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if legacyGetLine(head, r.Intn(largeFileLines)) == nil {
+				b.Fatal("missing line")
+			}
+		}
+	})
+}
+
+// The legacy* code is the LinesNode chain the piece table replaced, kept only as a baseline for the benchmarks
+
+const legacyLinesPerNode = 100
+
+type legacyLine struct {
+	chars []rune
+}
+
+type legacyLinesNode struct {
+	Lines [legacyLinesPerNode]legacyLine
+	Next  *legacyLinesNode
+}
+
+func legacyParseLines(fileContents string) (*legacyLinesNode, int) {
+
+	head := &legacyLinesNode{}
+	if len(fileContents) == 0 {
+		return head, 0
+	}
+
+	lineCount := 0
+	start := 0
+	end := 0
+	currLine := 0
+	currNode := head
+
+	for i := 0; i < len(fileContents); i++ {
+
+		if fileContents[i] != '\n' {
+			end++
+			continue
+		}
+
+		lineCount++
+		currNode.Lines[currLine].chars = []rune(fileContents[start:end])
+
+		end++
+		start = end
+		currLine++
+		if currLine == legacyLinesPerNode {
+			currLine = 0
+			currNode.Next = &legacyLinesNode{}
+			currNode = currNode.Next
+		}
+	}
+
+	if fileContents[len(fileContents)-1] != '\n' {
+		lineCount++
+		currNode.Lines[currLine].chars = []rune(fileContents[start:end])
+	}
+
+	return head, lineCount
+}
+
+func legacyGetLine(head *legacyLinesNode, lineNum int) *legacyLine {
+
+	curr := head
+	for nodeNum := lineNum / legacyLinesPerNode; nodeNum > 0; nodeNum-- {
+		curr = curr.Next
+	}
+
+	return &curr.Lines[lineNum%legacyLinesPerNode]
+}
+
+func legacyInsert(l *legacyLine, charIndex int, rs []rune) {
+
+	c := l.chars
+	l.chars = make([]rune, len(c)+len(rs))
+	copy(l.chars, c[:charIndex])
+	copy(l.chars[charIndex:], rs)
+	copy(l.chars[charIndex+len(rs):], c[charIndex:])
+}
+
+func legacyDelete(l *legacyLine, charIndex int) {
+	l.chars = append(l.chars[:charIndex], l.chars[charIndex+1:]...)
+}
@@ -0,0 +1,152 @@
+package buffer
+
+import "strings"
+
+// Clipboard is where copied text goes. The app uses the system clipboard, while anything without one
+// (e.g. tests and tools) can use a MemClipboard.
+type Clipboard interface {
+	SetText(text string) error
+	Text() (string, error)
+}
+
+// MemClipboard is a Clipboard that only lives in memory
+type MemClipboard struct {
+	text string
+}
+
+func (c *MemClipboard) SetText(text string) error {
+	c.text = text
+	return nil
+}
+
+func (c *MemClipboard) Text() (string, error) {
+	return c.text, nil
+}
+
+// ClipboardHistory is a Clipboard that remembers the last few texts copied through it, newest first
+type ClipboardHistory struct {
+	Clipboard
+
+	Entries    []string
+	MaxEntries int
+}
+
+func (c *ClipboardHistory) SetText(text string) error {
+
+	if err := c.Clipboard.SetText(text); err != nil {
+		return err
+	}
+
+	//Copying something already in the history moves it to the front instead of having it twice
+	for i := 0; i < len(c.Entries); i++ {
+		if c.Entries[i] == text {
+			c.Entries = append(c.Entries[:i], c.Entries[i+1:]...)
+			break
+		}
+	}
+
+	c.Entries = append([]string{text}, c.Entries...)
+	if len(c.Entries) > c.MaxEntries {
+		c.Entries = c.Entries[:c.MaxEntries]
+	}
+
+	return nil
+}
+
+func NewClipboardHistory(cb Clipboard, maxEntries int) *ClipboardHistory {
+	return &ClipboardHistory{
+		Clipboard:  cb,
+		MaxEntries: maxEntries,
+	}
+}
+
+// Copy puts the selected text of every cursor on the clipboard, one selection per line.
+// Nothing is copied if nothing is selected.
+func (d *Document) Copy(cb Clipboard) error {
+
+	text, ok := d.selectionsText()
+	if !ok {
+		return nil
+	}
+
+	return cb.SetText(text)
+}
+
+// Cut is like Copy, but the copied text is also removed from the document
+func (d *Document) Cut(cb Clipboard) error {
+
+	text, ok := d.selectionsText()
+	if !ok {
+		return nil
+	}
+
+	if err := cb.SetText(text); err != nil {
+		return err
+	}
+
+	d.DeleteSelection()
+	return nil
+}
+
+// Paste inserts the clipboard text at every cursor (see PasteText)
+func (d *Document) Paste(cb Clipboard) error {
+
+	text, err := cb.Text()
+	if err != nil {
+		return err
+	}
+
+	d.PasteText(text)
+	return nil
+}
+
+// PasteText inserts text at every cursor. If the text has as many lines as there are cursors,
+// then each cursor gets one line instead, which undoes copying with multiple cursors.
+func (d *Document) PasteText(text string) {
+
+	if len(text) == 0 {
+		return
+	}
+
+	d.History.Break()
+	defer d.History.Break()
+
+	text = normalizeLineEndings(text)
+	lines := strings.Split(text, "\n")
+	if len(d.extraCursors) == 0 || len(lines) != len(d.extraCursors)+1 {
+		d.History.BeginGroup()
+		d.Insert(text)
+		d.History.EndGroup()
+		return
+	}
+
+	//Cursors are edited in document order, which is also the order they were copied in
+	i := 0
+	d.editAtCursors(func() {
+		d.insert(lines[i])
+		i++
+	})
+}
+
+// selectionsText returns the selections of all cursors in document order joined by newlines.
+// ok is false if nothing is selected.
+func (d *Document) selectionsText() (text string, ok bool) {
+
+	ranges := d.cursorRanges()
+	parts := make([]string, 0, len(ranges))
+	for i := 0; i < len(ranges); i++ {
+
+		r := &ranges[i]
+		if r.start() == r.end() {
+			continue
+		}
+
+		parts = append(parts, string(d.Buf.Slice(r.start(), r.end()-r.start())))
+	}
+
+	if len(parts) == 0 {
+		return "", false
+	}
+
+	return strings.Join(parts, "\n"), true
+}
@@ -0,0 +1,269 @@
+package buffer
+
+import "sort"
+
+// cursorRange is a cursor expressed as byte offsets, which unlike lines and columns can be shifted and compared easily
+type cursorRange struct {
+	off       int
+	anchorOff int
+	primary   bool
+}
+
+func (r *cursorRange) start() int {
+	return minInt(r.off, r.anchorOff)
+}
+
+func (r *cursorRange) end() int {
+	return maxInt(r.off, r.anchorOff)
+}
+
+// Cursors returns every cursor, with the primary cursor last
+func (d *Document) Cursors() []Cursor {
+
+	cs := make([]Cursor, 0, len(d.extraCursors)+1)
+	cs = append(cs, d.extraCursors...)
+	return append(cs, d.Cursor)
+}
+
+func (d *Document) HasExtraCursors() bool {
+	return len(d.extraCursors) > 0
+}
+
+func (d *Document) ClearExtraCursors() {
+	d.extraCursors = d.extraCursors[:0]
+}
+
+// AddCursor adds a cursor at the given position and makes it the primary cursor
+func (d *Document) AddCursor(lineNum, col int) {
+
+	d.extraCursors = append(d.extraCursors, d.Cursor)
+	d.setCursor(lineNum, col, false)
+	d.mergeCursors()
+	d.History.Break()
+}
+
+// SelectColumns creates one cursor per line between anchorLine and lineNum (inclusive), each selecting the
+// grid columns between anchorGridX and gridX. The cursor on lineNum becomes the primary cursor.
+func (d *Document) SelectColumns(anchorLine, anchorGridX, lineNum, gridX int) {
+
+	anchorLine = clampInt(anchorLine, 0, d.Buf.LineCount()-1)
+	lineNum = clampInt(lineNum, 0, d.Buf.LineCount()-1)
+
+	dir := 1
+	if lineNum < anchorLine {
+		dir = -1
+	}
+
+	d.ClearExtraCursors()
+	for i := anchorLine; ; i += dir {
+
+		l := d.Line(i)
+		d.setCursor(i, l.ColFromGridX(anchorGridX), false)
+		d.setCursor(i, l.ColFromGridX(gridX), true)
+		d.Cursor.desiredGridX = gridX
+
+		if i == lineNum {
+			break
+		}
+
+		d.extraCursors = append(d.extraCursors, d.Cursor)
+	}
+
+	d.History.Break()
+}
+
+// SelectNextOccurrence selects the word at the primary cursor if nothing is selected. Otherwise it adds a cursor that selects
+// the next occurrence of the selected text after the primary cursor, wrapping around at the end of the document.
+// It returns false if nothing new was selected.
+func (d *Document) SelectNextOccurrence() bool {
+
+	d.History.Break()
+
+	if !d.HasSelection() {
+
+		start, end := d.wordRange(d.Cursor.Line, d.Cursor.Col)
+		if start == end {
+			return false
+		}
+
+		d.setCursorFromOffset(start, false)
+		d.setCursorFromOffset(end, true)
+		d.mergeCursors()
+		return true
+	}
+
+	selStart, selEnd := d.Selection()
+	text := d.Buf.Slice(selStart, selEnd-selStart)
+
+	ranges := d.cursorRanges()
+	from := selEnd
+	for tries := 0; tries <= len(ranges); tries++ {
+
+		found := d.Buf.Index(from, text)
+		if found == -1 {
+			found = d.Buf.Index(0, text)
+		}
+
+		if found == -1 {
+			return false
+		}
+
+		if !isRangeSelected(ranges, found, found+len(text)) {
+			d.extraCursors = append(d.extraCursors, d.Cursor)
+			d.setCursorFromOffset(found, false)
+			d.setCursorFromOffset(found+len(text), true)
+			d.mergeCursors()
+			return true
+		}
+
+		from = found + len(text)
+	}
+
+	return false
+}
+
+func isRangeSelected(ranges []cursorRange, start, end int) bool {
+
+	for i := 0; i < len(ranges); i++ {
+		if ranges[i].start() == start && ranges[i].end() == end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// moveCursors runs move once for every cursor, each time with that cursor as d.Cursor
+func (d *Document) moveCursors(move func()) {
+
+	primary := d.Cursor
+	for i := 0; i < len(d.extraCursors); i++ {
+		d.Cursor = d.extraCursors[i]
+		move()
+		d.extraCursors[i] = d.Cursor
+	}
+
+	d.Cursor = primary
+	move()
+
+	d.mergeCursors()
+}
+
+// editAtCursors runs edit once for every cursor, each time with that cursor as d.Cursor.
+//
+// Cursors are handled from the start of the document to its end, and each cursor is first shifted by how much
+// the edits before it changed the document size. With multiple cursors all the edits are a single undo step.
+func (d *Document) editAtCursors(edit func()) {
+
+	if len(d.extraCursors) == 0 {
+		edit()
+		return
+	}
+
+	d.History.BeginGroup()
+	defer d.History.EndGroup()
+
+	ranges := d.cursorRanges()
+	delta := 0
+	for i := 0; i < len(ranges); i++ {
+
+		r := &ranges[i]
+		d.setCursorFromOffset(r.anchorOff+delta, false)
+		d.setCursorFromOffset(r.off+delta, true)
+
+		lenBefore := d.Buf.Len()
+		edit()
+		delta += d.Buf.Len() - lenBefore
+
+		r.off = d.CursorOffset()
+		r.anchorOff = d.PosToOffset(d.Cursor.AnchorLine, d.Cursor.AnchorCol)
+	}
+
+	d.setCursorsFromRanges(ranges)
+	d.mergeCursors()
+}
+
+// cursorRanges returns all cursors as ranges sorted by where they start
+func (d *Document) cursorRanges() []cursorRange {
+
+	cursors := d.Cursors()
+	ranges := make([]cursorRange, len(cursors))
+	for i := 0; i < len(cursors); i++ {
+		ranges[i] = cursorRange{
+			off:       d.PosToOffset(cursors[i].Line, cursors[i].Col),
+			anchorOff: d.PosToOffset(cursors[i].AnchorLine, cursors[i].AnchorCol),
+			primary:   i == len(cursors)-1,
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].start() < ranges[j].start()
+	})
+
+	return ranges
+}
+
+func (d *Document) setCursorsFromRanges(ranges []cursorRange) {
+
+	d.ClearExtraCursors()
+
+	primary := Cursor{}
+	hasPrimary := false
+	for i := 0; i < len(ranges); i++ {
+
+		d.setCursorFromOffset(ranges[i].anchorOff, false)
+		d.setCursorFromOffset(ranges[i].off, true)
+
+		if ranges[i].primary && !hasPrimary {
+			primary = d.Cursor
+			hasPrimary = true
+			continue
+		}
+
+		d.extraCursors = append(d.extraCursors, d.Cursor)
+	}
+
+	if !hasPrimary {
+		primary = d.extraCursors[len(d.extraCursors)-1]
+		d.extraCursors = d.extraCursors[:len(d.extraCursors)-1]
+	}
+
+	d.Cursor = primary
+}
+
+// mergeCursors joins cursors that are at the same position or whose selections overlap
+func (d *Document) mergeCursors() {
+
+	if len(d.extraCursors) == 0 {
+		return
+	}
+
+	ranges := d.cursorRanges()
+	merged := ranges[:1]
+	for i := 1; i < len(ranges); i++ {
+
+		r := ranges[i]
+		last := &merged[len(merged)-1]
+		if r.start() >= last.end() && r.off != last.off {
+			merged = append(merged, r)
+			continue
+		}
+
+		//Keep the cursor on the same side of the joined selection as it was on the first one
+		start := last.start()
+		end := maxInt(last.end(), r.end())
+		if last.off >= last.anchorOff {
+			last.anchorOff, last.off = start, end
+		} else {
+			last.anchorOff, last.off = end, start
+		}
+
+		last.primary = last.primary || r.primary
+	}
+
+	if len(merged) == len(ranges) {
+		return
+	}
+
+	d.setCursorsFromRanges(merged)
+}
@@ -0,0 +1,169 @@
+package buffer
+
+import "testing"
+
+func TestMultiCursorEdits(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		text   string
+		edit   func(d *Document)
+		expect string
+
+		//cursors are the expected "line:col" of every cursor after the edit, sorted by position
+		cursors []Cursor
+	}{
+		{"insert at cursors on every line", "ab\ncd\nef", func(d *Document) {
+			d.SetCursor(0, 1)
+			d.AddCursor(1, 1)
+			d.AddCursor(2, 1)
+			d.Insert("x")
+		}, "axb\ncxd\nexf", []Cursor{{Line: 0, Col: 2}, {Line: 1, Col: 2}, {Line: 2, Col: 2}}},
+		{"insert at cursors on one line", "abc", func(d *Document) {
+			d.SetCursor(0, 0)
+			d.AddCursor(0, 3)
+			d.Insert("日")
+		}, "日abc日", []Cursor{{Line: 0, Col: 1}, {Line: 0, Col: 5}}},
+		{"insert newline at cursors", "ab\ncd", func(d *Document) {
+			d.SetCursor(0, 1)
+			d.AddCursor(1, 1)
+			d.Insert("\n")
+		}, "a\nb\nc\nd", []Cursor{{Line: 1, Col: 0}, {Line: 3, Col: 0}}},
+		{"backspace at cursors", "ab\ncd", func(d *Document) {
+			d.SetCursor(0, 2)
+			d.AddCursor(1, 2)
+			d.DeleteBackward(1)
+		}, "a\nc", []Cursor{{Line: 0, Col: 1}, {Line: 1, Col: 1}}},
+		{"cursors that meet merge", "abc", func(d *Document) {
+			d.SetCursor(0, 1)
+			d.AddCursor(0, 2)
+			d.DeleteBackward(1)
+			d.DeleteBackward(1)
+		}, "c", []Cursor{{Line: 0, Col: 0}}},
+		{"adding a cursor on another merges them", "abc", func(d *Document) {
+			d.SetCursor(0, 1)
+			d.AddCursor(0, 1)
+		}, "abc", []Cursor{{Line: 0, Col: 1}}},
+		{"moving moves every cursor", "ab\ncd", func(d *Document) {
+			d.SetCursor(0, 0)
+			d.AddCursor(1, 0)
+			d.MoveCursorToLineEnd(false)
+		}, "ab\ncd", []Cursor{{Line: 0, Col: 2}, {Line: 1, Col: 2}}},
+		{"column selection is replaced", "abcd\nab\nabcd", func(d *Document) {
+			d.SelectColumns(0, 1, 2, 3)
+			d.Insert("-")
+		}, "a-d\na-\na-d", []Cursor{{Line: 0, Col: 2}, {Line: 1, Col: 2}, {Line: 2, Col: 2}}},
+		{"paste one line per cursor", "a\nb", func(d *Document) {
+			d.SetCursor(0, 1)
+			d.AddCursor(1, 1)
+			d.PasteText("1\n2")
+		}, "a1\nb2", []Cursor{{Line: 0, Col: 2}, {Line: 1, Col: 2}}},
+		{"paste the whole text at every cursor", "a\nb", func(d *Document) {
+			d.SetCursor(0, 1)
+			d.AddCursor(1, 1)
+			d.PasteText("xy")
+		}, "axy\nbxy", []Cursor{{Line: 0, Col: 3}, {Line: 1, Col: 3}}},
+	}
+
+	for _, tt := range tests {
+
+		d := NewDocument([]byte(tt.text), 4)
+		tt.edit(d)
+
+		if d.Buf.String() != tt.expect {
+			t.Errorf("%s: expected %q but got %q", tt.name, tt.expect, d.Buf.String())
+		}
+
+		ranges := d.cursorRanges()
+		if len(ranges) != len(tt.cursors) {
+			t.Errorf("%s: expected %d cursors but got %d", tt.name, len(tt.cursors), len(ranges))
+			continue
+		}
+
+		for i, c := range tt.cursors {
+			line, col := d.OffsetToPos(ranges[i].off)
+			if line != c.Line || col != c.Col {
+				t.Errorf("%s: expected cursor %d at %d:%d but got %d:%d", tt.name, i, c.Line, c.Col, line, col)
+			}
+		}
+	}
+}
+
+func TestMultiCursorEditsAreOneUndoStep(t *testing.T) {
+
+	d := NewDocument([]byte("a\nb\nc"), 4)
+	d.SetCursor(0, 1)
+	d.AddCursor(1, 1)
+	d.AddCursor(2, 1)
+	d.Insert("x")
+	d.Insert("y")
+	d.DeleteBackward(1)
+
+	expect := []string{"axy\nbxy\ncxy", "ax\nbx\ncx", "a\nb\nc"}
+	for _, e := range expect {
+
+		d.Undo()
+		if d.Buf.String() != e {
+			t.Fatalf("expected %q but got %q", e, d.Buf.String())
+		}
+	}
+}
+
+func TestSelectNextOccurrence(t *testing.T) {
+
+	d := NewDocument([]byte("foo bar foo\nbaz foo"), 4)
+	d.SetCursor(0, 1)
+
+	//The first call selects the word, and each one after it adds the next occurrence
+	for i := 1; i <= 3; i++ {
+
+		if !d.SelectNextOccurrence() {
+			t.Fatalf("call %d selected nothing", i)
+		}
+
+		if len(d.Cursors()) != i || d.SelectedText() != "foo" {
+			t.Fatalf("call %d: expected %d cursors on 'foo' but got %d on %q", i, i, len(d.Cursors()), d.SelectedText())
+		}
+	}
+
+	if d.SelectNextOccurrence() {
+		t.Fatal("expected nothing new to select once every occurrence is selected")
+	}
+
+	for _, c := range d.Cursors() {
+		if start, end := d.SelectionOf(c); string(d.Buf.Slice(start, end-start)) != "foo" {
+			t.Errorf("expected every cursor to select 'foo' but got %q", d.Buf.Slice(start, end-start))
+		}
+	}
+
+	d.Insert("qux")
+	if d.Buf.String() != "qux bar qux\nbaz qux" {
+		t.Fatalf("expected every occurrence replaced but got %q", d.Buf.String())
+	}
+
+	//The primary cursor is always the last one in the list
+	if cs := d.Cursors(); cs[len(cs)-1] != d.Cursor {
+		t.Error("expected the primary cursor last")
+	}
+}
+
+func TestCopyWithMultipleCursors(t *testing.T) {
+
+	//The second line is shorter than the columns, so only its last char is selected
+	d := NewDocument([]byte("one two\nthree"), 4)
+	d.SelectColumns(0, 4, 1, 7)
+
+	cb := &MemClipboard{}
+	if err := d.Cut(cb); err != nil {
+		t.Fatal(err)
+	}
+
+	text, _ := cb.Text()
+	if text != "two\ne" {
+		t.Fatalf("expected the selections joined by newlines but got %q", text)
+	}
+
+	if d.Buf.String() != "one \nthre" {
+		t.Fatalf("expected the selections removed but got %q", d.Buf.String())
+	}
+}
@@ -0,0 +1,107 @@
+package buffer
+
+// DiffOp says which of the two texts of a diff a line is in
+type DiffOp uint8
+
+const (
+	DiffSame DiffOp = iota
+	DiffRemoved
+	DiffAdded
+)
+
+// DiffLine is one line of a diff
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+}
+
+// maxDiffCells limits the size of the table used to diff the changed lines. Bigger changes are shown as
+// all the old lines removed then all the new lines added, which is correct but not minimal.
+const maxDiffCells = 4 * 1024 * 1024
+
+// DiffLines returns the lines to remove from oldLines and add to get newLines, along with the lines they share
+func DiffLines(oldLines, newLines []string) []DiffLine {
+
+	//Lines the texts start and end with are the same, which is usually most of them
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	diff := make([]DiffLine, 0, len(oldLines)+len(newLines)-prefix-suffix)
+	for _, l := range oldLines[:prefix] {
+		diff = append(diff, DiffLine{Op: DiffSame, Text: l})
+	}
+
+	diff = diffMiddle(diff, oldLines[prefix:len(oldLines)-suffix], newLines[prefix:len(newLines)-suffix])
+
+	for _, l := range oldLines[len(oldLines)-suffix:] {
+		diff = append(diff, DiffLine{Op: DiffSame, Text: l})
+	}
+
+	return diff
+}
+
+// diffMiddle appends the diff of a and b to diff using the longest common subsequence of their lines
+func diffMiddle(diff []DiffLine, a, b []string) []DiffLine {
+
+	if len(a)*len(b) > maxDiffCells || len(a) == 0 || len(b) == 0 {
+
+		for _, l := range a {
+			diff = append(diff, DiffLine{Op: DiffRemoved, Text: l})
+		}
+		for _, l := range b {
+			diff = append(diff, DiffLine{Op: DiffAdded, Text: l})
+		}
+		return diff
+	}
+
+	//lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	cols := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*cols)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+
+			switch {
+			case a[i] == b[j]:
+				lcs[i*cols+j] = lcs[(i+1)*cols+j+1] + 1
+			case lcs[(i+1)*cols+j] >= lcs[i*cols+j+1]:
+				lcs[i*cols+j] = lcs[(i+1)*cols+j]
+			default:
+				lcs[i*cols+j] = lcs[i*cols+j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, DiffLine{Op: DiffSame, Text: a[i]})
+			i++
+			j++
+		case lcs[(i+1)*cols+j] >= lcs[i*cols+j+1]:
+			diff = append(diff, DiffLine{Op: DiffRemoved, Text: a[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: DiffAdded, Text: b[j]})
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		diff = append(diff, DiffLine{Op: DiffRemoved, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, DiffLine{Op: DiffAdded, Text: b[j]})
+	}
+
+	return diff
+}
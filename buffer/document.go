@@ -0,0 +1,391 @@
+package buffer
+
+import "unicode/utf8"
+
+// Cursor is a position in a document as a line number and the index of the char the cursor is before.
+//
+// The anchor is where a selection started, and the selection is everything between the anchor and the cursor.
+// When they are the same nothing is selected.
+type Cursor struct {
+	Line int
+	Col  int
+
+	AnchorLine int
+	AnchorCol  int
+
+	//desiredGridX is the grid column the cursor tries to stay on when moving up and down through lines of different lengths
+	desiredGridX int
+}
+
+// Document is a buffer together with its edit history and cursors. All editing goes through
+// the document so that every change is recorded and the cursors are kept valid.
+//
+// Cursor is the primary cursor, and any extra cursors (see cursors.go) get the same edits and moves applied to them.
+//
+// It has no knowledge of how (or if) it is displayed, so it can be used without any UI.
+type Document struct {
+	Buf     *Buffer
+	History *History
+	Cursor  Cursor
+
+	TabSize int
+
+	//Endings are the line endings the file had, since the buffer only has '\n'.
+	//endingsChanged is true if they were converted since the last save.
+	Endings        *LineEndings
+	endingsChanged bool
+
+	extraCursors []Cursor
+}
+
+// Line returns a view of the given line. Lines past the end return an empty line at the end of the document.
+func (d *Document) Line(lineNum int) *Line {
+
+	l := &Line{
+		buf:     d.Buf,
+		tabSize: d.TabSize,
+	}
+
+	if lineNum >= d.Buf.LineCount() {
+		l.start = d.Buf.Len()
+		l.end = l.start
+		l.runeStart = d.Buf.RuneCount()
+		return l
+	}
+
+	l.start = d.Buf.LineStart(lineNum)
+	l.end = d.Buf.LineEnd(lineNum)
+	l.runeStart = d.Buf.RuneOffset(l.start)
+	l.runeCount = d.Buf.RuneOffset(l.end) - l.runeStart
+	return l
+}
+
+func (d *Document) LineCount() int {
+	return d.Buf.LineCount()
+}
+
+// IsModified returns true if the document differs from the last saved state
+func (d *Document) IsModified() bool {
+	return !d.History.IsSaved() || d.endingsChanged
+}
+
+// MarkSaved records the current state as the one saved on disk
+func (d *Document) MarkSaved() {
+	d.History.MarkSaved()
+	d.endingsChanged = false
+}
+
+// SetLineEnding makes every line end with ending when saved
+func (d *Document) SetLineEnding(ending LineEnding) {
+
+	if !d.Endings.Mixed() && d.Endings.Default == ending {
+		return
+	}
+
+	d.Endings.Set(ending)
+	d.endingsChanged = true
+}
+
+// SetText replaces the contents of the document with text as a single undo step. Only the part that differs
+// is replaced, so the rest keeps its line endings. The cursor stays where it was as far as the new text allows.
+func (d *Document) SetText(text string) {
+
+	text = normalizeLineEndings(text)
+	old := d.Buf.String()
+
+	prefix := 0
+	for prefix < len(old) && prefix < len(text) && old[prefix] == text[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(text)-prefix && old[len(old)-1-suffix] == text[len(text)-1-suffix] {
+		suffix++
+	}
+
+	//Edits must not split a char
+	for prefix > 0 && prefix < len(old) && !utf8.RuneStart(old[prefix]) {
+		prefix--
+	}
+	for suffix > 0 && !utf8.RuneStart(old[len(old)-suffix]) {
+		suffix--
+	}
+
+	removed := len(old) - prefix - suffix
+	inserted := text[prefix : len(text)-suffix]
+	if removed == 0 && len(inserted) == 0 {
+		return
+	}
+
+	cursorOff := d.CursorOffset()
+	d.History.Break()
+	d.History.BeginGroup()
+	if removed > 0 {
+		d.History.Delete(d.Buf, prefix, removed)
+	}
+	if len(inserted) > 0 {
+		d.History.Insert(d.Buf, prefix, inserted)
+	}
+	d.History.EndGroup()
+	d.History.Break()
+
+	d.SetCursorFromOffset(clampInt(cursorOff, 0, d.Buf.Len()))
+}
+
+// Insert places text before every cursor and moves the cursors after it. Selections are replaced by text.
+func (d *Document) Insert(text string) {
+
+	if len(text) == 0 {
+		return
+	}
+
+	text = normalizeLineEndings(text)
+	d.editAtCursors(func() { d.insert(text) })
+}
+
+// DeleteBackward removes count chars before every cursor (i.e. backspace), or the selections if there are any.
+// Deleting at the start of a line joins it with the previous one.
+func (d *Document) DeleteBackward(count int) {
+	d.editAtCursors(func() { d.deleteBackward(count) })
+}
+
+// DeleteForward removes count chars after every cursor (i.e. the delete key), or the selections if there are any
+func (d *Document) DeleteForward(count int) {
+	d.editAtCursors(func() { d.deleteForward(count) })
+}
+
+// DeleteSelection removes the selected text of every cursor and returns true if there was anything selected
+func (d *Document) DeleteSelection() bool {
+
+	deleted := false
+	d.editAtCursors(func() {
+		if d.deleteSelection() {
+			deleted = true
+		}
+	})
+
+	return deleted
+}
+
+func (d *Document) insert(text string) {
+
+	//Replacing the selection is a single undo step
+	if d.HasSelection() {
+		d.History.BeginGroup()
+		defer d.History.EndGroup()
+		d.deleteSelection()
+	}
+
+	off := d.CursorOffset()
+	d.History.Insert(d.Buf, off, text)
+	d.setCursorFromOffset(off+len(text), false)
+}
+
+func (d *Document) deleteBackward(count int) {
+
+	if d.deleteSelection() {
+		return
+	}
+
+	off := d.CursorOffset()
+	start := d.Buf.ByteOffset(d.Buf.RuneOffset(off) - count)
+	if start == off {
+		return
+	}
+
+	d.History.Delete(d.Buf, start, off-start)
+	d.setCursorFromOffset(start, false)
+}
+
+func (d *Document) deleteForward(count int) {
+
+	if d.deleteSelection() {
+		return
+	}
+
+	off := d.CursorOffset()
+	end := d.Buf.ByteOffset(d.Buf.RuneOffset(off) + count)
+	if end == off {
+		return
+	}
+
+	d.History.Delete(d.Buf, off, end-off)
+	d.setCursorFromOffset(off, false)
+}
+
+func (d *Document) deleteSelection() bool {
+
+	start, end := d.Selection()
+	if start == end {
+		return false
+	}
+
+	d.History.Delete(d.Buf, start, end-start)
+	d.setCursorFromOffset(start, false)
+	return true
+}
+
+func (d *Document) Undo() {
+
+	if caretOff, ok := d.History.Undo(d.Buf); ok {
+		d.SetCursorFromOffset(caretOff)
+	}
+}
+
+func (d *Document) Redo() {
+
+	if caretOff, ok := d.History.Redo(d.Buf); ok {
+		d.SetCursorFromOffset(caretOff)
+	}
+}
+
+// MoveCursorX moves every cursor by charCount chars, wrapping to the previous/next line at line edges.
+// If extendSelection is false and there is a selection, the cursor goes to the selection edge in the direction of the move instead.
+func (d *Document) MoveCursorX(charCount int, extendSelection bool) {
+
+	d.History.Break()
+	d.moveCursors(func() {
+
+		if !extendSelection && d.HasSelection() {
+
+			start, end := d.Selection()
+			if charCount < 0 {
+				d.setCursorFromOffset(start, false)
+			} else {
+				d.setCursorFromOffset(end, false)
+			}
+
+			return
+		}
+
+		runeOff := clampInt(d.Buf.RuneOffset(d.CursorOffset())+charCount, 0, d.Buf.RuneCount())
+		d.setCursorFromOffset(d.Buf.ByteOffset(runeOff), extendSelection)
+	})
+}
+
+// MoveCursorY moves every cursor by lineCount lines while trying to stay on the same grid column
+func (d *Document) MoveCursorY(lineCount int, extendSelection bool) {
+
+	d.History.Break()
+	d.moveCursors(func() {
+
+		lineNum := clampInt(d.Cursor.Line+lineCount, 0, d.Buf.LineCount()-1)
+
+		desiredGridX := d.Cursor.desiredGridX
+		d.setCursor(lineNum, d.Line(lineNum).ColFromGridX(desiredGridX), extendSelection)
+		d.Cursor.desiredGridX = desiredGridX
+	})
+}
+
+func (d *Document) MoveCursorToLineStart(extendSelection bool) {
+
+	d.History.Break()
+	d.moveCursors(func() {
+		d.setCursor(d.Cursor.Line, 0, extendSelection)
+	})
+}
+
+func (d *Document) MoveCursorToLineEnd(extendSelection bool) {
+
+	d.History.Break()
+	d.moveCursors(func() {
+		d.setCursor(d.Cursor.Line, d.Line(d.Cursor.Line).RuneCount(), extendSelection)
+	})
+}
+
+// MoveCursorToStart moves the primary cursor to the start of the document, removing any extra cursors
+func (d *Document) MoveCursorToStart(extendSelection bool) {
+	d.ClearExtraCursors()
+	d.setCursor(0, 0, extendSelection)
+	d.History.Break()
+}
+
+// MoveCursorToEnd moves the primary cursor to the end of the document, removing any extra cursors
+func (d *Document) MoveCursorToEnd(extendSelection bool) {
+	d.ClearExtraCursors()
+	d.setCursorFromOffset(d.Buf.Len(), extendSelection)
+	d.History.Break()
+}
+
+// SetCursor places the cursor at the given line and column, clamping both to the document.
+// Any selection and extra cursors are cleared.
+func (d *Document) SetCursor(lineNum, col int) {
+	d.ClearExtraCursors()
+	d.setCursor(lineNum, col, false)
+}
+
+// SetCursorFromOffset places the cursor before the char at byte offset off. Any selection and extra cursors are cleared.
+func (d *Document) SetCursorFromOffset(off int) {
+	d.ClearExtraCursors()
+	d.setCursorFromOffset(off, false)
+}
+
+// ExtendSelection moves the primary cursor to the given line and column while keeping the anchor where it is
+func (d *Document) ExtendSelection(lineNum, col int) {
+	d.setCursor(lineNum, col, true)
+	d.mergeCursors()
+}
+
+func (d *Document) setCursor(lineNum, col int, extendSelection bool) {
+
+	d.Cursor.Line = clampInt(lineNum, 0, d.Buf.LineCount()-1)
+
+	l := d.Line(d.Cursor.Line)
+	d.Cursor.Col = clampInt(col, 0, l.RuneCount())
+	d.Cursor.desiredGridX = l.GridWidth(d.Cursor.Col)
+
+	if !extendSelection {
+		d.Cursor.AnchorLine = d.Cursor.Line
+		d.Cursor.AnchorCol = d.Cursor.Col
+	}
+}
+
+func (d *Document) setCursorFromOffset(off int, extendSelection bool) {
+	line, col := d.OffsetToPos(off)
+	d.setCursor(line, col, extendSelection)
+}
+
+// CursorOffset returns the byte offset in the buffer the cursor is at
+func (d *Document) CursorOffset() int {
+	return d.PosToOffset(d.Cursor.Line, d.Cursor.Col)
+}
+
+// CursorGridX returns the grid column the cursor is at
+func (d *Document) CursorGridX() int {
+	return d.Line(d.Cursor.Line).GridWidth(d.Cursor.Col)
+}
+
+// OffsetToPos converts a byte offset into a line number and the index of the char in that line
+func (d *Document) OffsetToPos(off int) (lineNum, col int) {
+	lineNum = d.Buf.OffsetToLine(off)
+	return lineNum, d.Line(lineNum).CharIndex(off)
+}
+
+// PosToOffset converts a line number and char index into a byte offset
+func (d *Document) PosToOffset(lineNum, col int) int {
+	return d.Line(lineNum).ByteOffset(col)
+}
+
+// NewDocument creates a document from the contents of a file. Line endings are turned into '\n' and remembered in Endings.
+func NewDocument(text []byte, tabSize int) *Document {
+
+	text, endings := splitLineEndings(text)
+	d := &Document{
+		Buf:     New(text),
+		History: NewHistory(),
+		TabSize: tabSize,
+		Endings: endings,
+	}
+
+	endings.buf = d.Buf
+	endings.lineCount = d.Buf.LineCount()
+	d.Buf.Listen(endings.edit)
+	d.History.endings = endings
+	return d
+}
+
+// SetHistory replaces the history of the document (e.g. with one restored from a journal)
+func (d *Document) SetHistory(h *History) {
+	h.endings = d.Endings
+	d.History = h
+}
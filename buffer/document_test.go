@@ -0,0 +1,214 @@
+package buffer
+
+import "testing"
+
+func TestDocumentEdits(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		text      string
+		line, col int
+		edit      func(d *Document)
+
+		expect                string
+		expectLine, expectCol int
+	}{
+		{"insert at start", "abc", 0, 0, func(d *Document) { d.Insert("x") }, "xabc", 0, 1},
+		{"insert in middle", "abc", 0, 1, func(d *Document) { d.Insert("ñ") }, "añbc", 0, 2},
+		{"insert at end", "abc", 0, 3, func(d *Document) { d.Insert("\n") }, "abc\n", 1, 0},
+		{"insert lines", "ab", 0, 1, func(d *Document) { d.Insert("x\ny") }, "ax\nyb", 1, 1},
+		{"insert normalizes line endings", "", 0, 0, func(d *Document) { d.Insert("a\r\nb\rc") }, "a\nb\nc", 2, 1},
+		{"insert after tab", "\tx", 0, 1, func(d *Document) { d.Insert("\t") }, "\t\tx", 0, 2},
+		{"insert after multi-byte", "日本", 0, 1, func(d *Document) { d.Insert("🙂") }, "日🙂本", 0, 2},
+
+		{"backspace at start of document", "abc", 0, 0, func(d *Document) { d.DeleteBackward(1) }, "abc", 0, 0},
+		{"backspace in middle", "abc", 0, 2, func(d *Document) { d.DeleteBackward(1) }, "ac", 0, 1},
+		{"backspace at end", "abc", 0, 3, func(d *Document) { d.DeleteBackward(2) }, "a", 0, 1},
+		{"backspace joins lines", "ab\ncd", 1, 0, func(d *Document) { d.DeleteBackward(1) }, "abcd", 0, 2},
+		{"backspace multi-byte", "a日b", 0, 2, func(d *Document) { d.DeleteBackward(1) }, "ab", 0, 1},
+		{"backspace 4 byte rune", "a🙂", 0, 2, func(d *Document) { d.DeleteBackward(1) }, "a", 0, 1},
+		{"backspace tab", "\tx", 0, 1, func(d *Document) { d.DeleteBackward(1) }, "x", 0, 0},
+
+		{"delete at start", "abc", 0, 0, func(d *Document) { d.DeleteForward(1) }, "bc", 0, 0},
+		{"delete in middle", "añc", 0, 1, func(d *Document) { d.DeleteForward(1) }, "ac", 0, 1},
+		{"delete at end of document", "abc", 0, 3, func(d *Document) { d.DeleteForward(1) }, "abc", 0, 3},
+		{"delete joins lines", "ab\ncd", 0, 2, func(d *Document) { d.DeleteForward(1) }, "abcd", 0, 2},
+
+		{"insert replaces selection", "abcd", 0, 1, func(d *Document) {
+			d.ExtendSelection(0, 3)
+			d.Insert("X")
+		}, "aXd", 0, 2},
+		{"backspace deletes selection", "ab\ncd", 0, 1, func(d *Document) {
+			d.ExtendSelection(1, 1)
+			d.DeleteBackward(1)
+		}, "ad", 0, 1},
+		{"delete deletes backwards selection", "abcd", 0, 3, func(d *Document) {
+			d.ExtendSelection(0, 1)
+			d.DeleteForward(1)
+		}, "ad", 0, 1},
+		{"set text keeps cursor", "hello world", 0, 8, func(d *Document) { d.SetText("hello there world") }, "hello there world", 0, 8},
+	}
+
+	for _, tt := range tests {
+
+		d := NewDocument([]byte(tt.text), 4)
+		d.SetCursor(tt.line, tt.col)
+		tt.edit(d)
+
+		if d.Buf.String() != tt.expect {
+			t.Errorf("%s: expected %q but got %q", tt.name, tt.expect, d.Buf.String())
+		}
+
+		if d.Cursor.Line != tt.expectLine || d.Cursor.Col != tt.expectCol {
+			t.Errorf("%s: expected cursor at %d:%d but got %d:%d", tt.name, tt.expectLine, tt.expectCol, d.Cursor.Line, d.Cursor.Col)
+		}
+
+		if d.HasSelection() {
+			t.Errorf("%s: expected no selection after editing", tt.name)
+		}
+	}
+}
+
+func TestDocumentUndoRestoresCursor(t *testing.T) {
+
+	d := NewDocument([]byte("ab\ncd"), 4)
+	d.SetCursor(1, 1)
+	d.Insert("xyz")
+	d.SetCursor(0, 0)
+
+	d.Undo()
+	if d.Buf.String() != "ab\ncd" || d.Cursor.Line != 1 || d.Cursor.Col != 1 {
+		t.Errorf("expected 'ab\\ncd' with cursor at 1:1 but got %q at %d:%d", d.Buf.String(), d.Cursor.Line, d.Cursor.Col)
+	}
+
+	d.Redo()
+	if d.Buf.String() != "ab\ncxyzd" || d.Cursor.Line != 1 || d.Cursor.Col != 4 {
+		t.Errorf("expected 'ab\\ncxyzd' with cursor at 1:4 but got %q at %d:%d", d.Buf.String(), d.Cursor.Line, d.Cursor.Col)
+	}
+}
+
+func TestDocumentCursorMovement(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		text      string
+		line, col int
+		move      func(d *Document)
+
+		expectLine, expectCol int
+	}{
+		{"right", "abc", 0, 0, func(d *Document) { d.MoveCursorX(2, false) }, 0, 2},
+		{"right wraps to next line", "ab\ncd", 0, 2, func(d *Document) { d.MoveCursorX(1, false) }, 1, 0},
+		{"left wraps to previous line", "ab\ncd", 1, 0, func(d *Document) { d.MoveCursorX(-1, false) }, 0, 2},
+		{"left stops at start", "ab", 0, 1, func(d *Document) { d.MoveCursorX(-5, false) }, 0, 0},
+		{"right stops at end", "ab\ncd", 1, 1, func(d *Document) { d.MoveCursorX(5, false) }, 1, 2},
+		{"right over multi-byte", "日本語", 0, 0, func(d *Document) { d.MoveCursorX(2, false) }, 0, 2},
+
+		{"down", "abc\ndef", 0, 2, func(d *Document) { d.MoveCursorY(1, false) }, 1, 2},
+		{"down clamps to shorter line", "abcdef\nab", 0, 5, func(d *Document) { d.MoveCursorY(1, false) }, 1, 2},
+		{"down remembers column past shorter line", "abcdef\nab\nabcdef", 0, 5, func(d *Document) {
+			d.MoveCursorY(1, false)
+			d.MoveCursorY(1, false)
+		}, 2, 5},
+		{"down keeps grid column after tab", "\tx\nabcdefgh", 0, 1, func(d *Document) { d.MoveCursorY(1, false) }, 1, 4},
+		{"up keeps grid column before tab", "a\tb\nabcdefgh", 1, 5, func(d *Document) { d.MoveCursorY(-1, false) }, 0, 2},
+		{"up stops at first line", "abc\ndef", 1, 1, func(d *Document) { d.MoveCursorY(-3, false) }, 0, 1},
+		{"down stops at last line", "abc\ndef", 0, 1, func(d *Document) { d.MoveCursorY(3, false) }, 1, 1},
+
+		{"line start", "abc", 0, 2, func(d *Document) { d.MoveCursorToLineStart(false) }, 0, 0},
+		{"line end", "abc\nd", 0, 1, func(d *Document) { d.MoveCursorToLineEnd(false) }, 0, 3},
+		{"document start", "abc\nd", 1, 1, func(d *Document) { d.MoveCursorToStart(false) }, 0, 0},
+		{"document end", "abc\nd", 0, 1, func(d *Document) { d.MoveCursorToEnd(false) }, 1, 1},
+
+		{"left collapses selection to its start", "abcd", 0, 1, func(d *Document) {
+			d.ExtendSelection(0, 3)
+			d.MoveCursorX(-1, false)
+		}, 0, 1},
+		{"right collapses selection to its end", "abcd", 0, 3, func(d *Document) {
+			d.ExtendSelection(0, 1)
+			d.MoveCursorX(1, false)
+		}, 0, 3},
+		{"set cursor clamps", "ab\ncd", 0, 0, func(d *Document) { d.SetCursor(10, 10) }, 1, 2},
+	}
+
+	for _, tt := range tests {
+
+		d := NewDocument([]byte(tt.text), 4)
+		d.SetCursor(tt.line, tt.col)
+		tt.move(d)
+
+		if d.Cursor.Line != tt.expectLine || d.Cursor.Col != tt.expectCol {
+			t.Errorf("%s: expected cursor at %d:%d but got %d:%d", tt.name, tt.expectLine, tt.expectCol, d.Cursor.Line, d.Cursor.Col)
+		}
+	}
+}
+
+func TestDocumentSelection(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		text      string
+		line, col int
+		sel       func(d *Document)
+
+		expect string
+	}{
+		{"extend right", "abcd", 0, 1, func(d *Document) { d.MoveCursorX(2, true) }, "bc"},
+		{"extend left", "abcd", 0, 3, func(d *Document) { d.MoveCursorX(-2, true) }, "bc"},
+		{"extend down", "abc\ndef", 0, 1, func(d *Document) { d.MoveCursorY(1, true) }, "bc\nd"},
+		{"extend to line end", "abc\ndef", 0, 1, func(d *Document) { d.MoveCursorToLineEnd(true) }, "bc"},
+		{"extend to document end", "abc\ndef", 0, 1, func(d *Document) { d.MoveCursorToEnd(true) }, "bc\ndef"},
+		{"word", "foo_bar  baz", 0, 0, func(d *Document) { d.SelectWord(0, 2) }, "foo_bar"},
+		{"word at end of line", "foo bar", 0, 0, func(d *Document) { d.SelectWord(0, 7) }, "bar"},
+		{"spaces", "foo_bar  baz", 0, 0, func(d *Document) { d.SelectWord(0, 8) }, "  "},
+		{"punctuation on its own", "a..b", 0, 0, func(d *Document) { d.SelectWord(0, 1) }, "."},
+		{"multi-byte word", "hé日本 x", 0, 0, func(d *Document) { d.SelectWord(0, 3) }, "hé日本"},
+		{"line", "ab\ncd", 0, 0, func(d *Document) { d.SelectLine(0) }, "ab\n"},
+		{"last line", "ab\ncd", 0, 0, func(d *Document) { d.SelectLine(1) }, "cd"},
+		{"all", "ab\ncd", 0, 0, func(d *Document) { d.SelectAll() }, "ab\ncd"},
+		{"cleared", "abcd", 0, 1, func(d *Document) {
+			d.MoveCursorX(2, true)
+			d.ClearSelection()
+		}, ""},
+	}
+
+	for _, tt := range tests {
+
+		d := NewDocument([]byte(tt.text), 4)
+		d.SetCursor(tt.line, tt.col)
+		tt.sel(d)
+
+		if d.SelectedText() != tt.expect {
+			t.Errorf("%s: expected %q to be selected but got %q", tt.name, tt.expect, d.SelectedText())
+		}
+
+		if d.HasSelection() != (tt.expect != "") {
+			t.Errorf("%s: HasSelection is %v", tt.name, d.HasSelection())
+		}
+	}
+}
+
+func TestLineGrid(t *testing.T) {
+
+	d := NewDocument([]byte("a\tb"), 4)
+	l := d.Line(0)
+
+	widths := []int{0, 1, 5, 6}
+	for n, w := range widths {
+		if l.GridWidth(n) != w {
+			t.Errorf("expected the first %d chars to be %d columns wide but got %d", n, w, l.GridWidth(n))
+		}
+	}
+
+	//The tab covers columns 2 to 5
+	charIndexes := []struct{ gridX, index int }{{0, -1}, {1, 0}, {2, 1}, {5, 1}, {6, 2}, {100, 2}}
+	for _, c := range charIndexes {
+		if l.CharIndexFromGridX(c.gridX) != c.index {
+			t.Errorf("expected grid column %d to be char %d but got %d", c.gridX, c.index, l.CharIndexFromGridX(c.gridX))
+		}
+	}
+
+	if string(l.Chars(1, 2)) != "\tb" || l.CharAt(2) != 'b' || l.CharAt(5) != 0 {
+		t.Errorf("wrong chars %q", string(l.Chars(1, 2)))
+	}
+}
@@ -0,0 +1,265 @@
+package buffer
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// FindOptions describes what to search for
+type FindOptions struct {
+	Query string
+
+	CaseSensitive bool
+	//WholeWord only accepts matches that don't have word chars right before or after them
+	WholeWord bool
+	//Regexp treats the query as a Go regexp, and allows replacements to use its capture groups (e.g. $1 or ${name})
+	Regexp bool
+}
+
+// Match is the byte offsets [Start, End) of a piece of text that matched a search
+type Match struct {
+	Start int
+	End   int
+}
+
+// Finder searches documents line by line, so a match never spans multiple lines
+type Finder struct {
+	Opts FindOptions
+
+	re *regexp.Regexp
+}
+
+// FindInLine returns the matches in line, which starts at byte offset lineStart in its document.
+// Matches are appended to dst, and empty matches are skipped.
+func (f *Finder) FindInLine(line []byte, lineStart int, dst []Match) []Match {
+
+	for _, loc := range f.findSubmatches(line) {
+		dst = append(dst, Match{Start: lineStart + loc[0], End: lineStart + loc[1]})
+	}
+
+	return dst
+}
+
+// ReplaceLine returns line with its matches replaced by template, and how many were replaced.
+// If keep isn't nil then only the matches it returns true for are replaced, where i is the index
+// of the match in the line (i.e. its index in the result of FindInLine).
+func (f *Finder) ReplaceLine(line []byte, template string, keep func(i int) bool) (out []byte, count int) {
+
+	out = make([]byte, 0, len(line))
+	last := 0
+	for i, loc := range f.findSubmatches(line) {
+
+		if keep != nil && !keep(i) {
+			continue
+		}
+
+		out = append(out, line[last:loc[0]]...)
+		out = append(out, f.replacement(line, loc, template)...)
+		last = loc[1]
+		count++
+	}
+
+	return append(out, line[last:]...), count
+}
+
+// findSubmatches returns the submatch indices of the matches in line that aren't empty and satisfy the options
+func (f *Finder) findSubmatches(line []byte) [][]int {
+
+	locs := f.re.FindAllSubmatchIndex(line, -1)
+	accepted := locs[:0]
+	for _, loc := range locs {
+
+		if loc[0] == loc[1] || !f.isWholeWord(line, loc) {
+			continue
+		}
+
+		accepted = append(accepted, loc)
+	}
+
+	return accepted
+}
+
+// isWholeWord returns true if the match at loc is acceptable according to the WholeWord option
+func (f *Finder) isWholeWord(line []byte, loc []int) bool {
+
+	if !f.Opts.WholeWord {
+		return true
+	}
+
+	if r, _ := utf8.DecodeLastRune(line[:loc[0]]); loc[0] > 0 && charClass(r) == charClassWord {
+		return false
+	}
+
+	if r, _ := utf8.DecodeRune(line[loc[1]:]); loc[1] < len(line) && charClass(r) == charClassWord {
+		return false
+	}
+
+	return true
+}
+
+// replacement returns what the match at loc in line should be replaced with. In regexp mode
+// the capture groups are expanded, otherwise the template is used as is.
+func (f *Finder) replacement(line []byte, loc []int, template string) []byte {
+
+	if !f.Opts.Regexp {
+		return []byte(template)
+	}
+
+	return f.re.Expand(nil, []byte(template), line, loc)
+}
+
+func NewFinder(opts FindOptions) (*Finder, error) {
+
+	pattern := opts.Query
+	if !opts.Regexp {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+
+	if !opts.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Finder{Opts: opts, re: re}, nil
+}
+
+// FindInLines returns the matches in the lines [startLine, endLine)
+func (d *Document) FindInLines(f *Finder, startLine, endLine int) []Match {
+
+	endLine = minInt(endLine, d.Buf.LineCount())
+
+	var matches []Match
+	for i := maxInt(startLine, 0); i < endLine; i++ {
+		matches = f.FindInLine(d.Buf.Line(i), d.Buf.LineStart(i), matches)
+	}
+
+	return matches
+}
+
+// FindAll returns every match in the document
+func (d *Document) FindAll(f *Finder) []Match {
+	return d.FindInLines(f, 0, d.Buf.LineCount())
+}
+
+// FindNext returns the first match that starts after byte offset from (or the last one that starts before it if backwards is true),
+// wrapping around the document edges. ok is false if there are no matches at all.
+func (d *Document) FindNext(f *Finder, from int, backwards bool) (m Match, ok bool) {
+
+	lineCount := d.Buf.LineCount()
+	fromLine := d.Buf.OffsetToLine(from)
+
+	//Going through lineCount+1 lines visits the starting line twice, which finds matches on
+	//the other side of from after wrapping around
+	var matches []Match
+	for i := 0; i <= lineCount; i++ {
+
+		lineNum := (fromLine + i) % lineCount
+		if backwards {
+			lineNum = ((fromLine-i)%lineCount + lineCount) % lineCount
+		}
+
+		matches = d.FindInLines(f, lineNum, lineNum+1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		if backwards {
+			for j := len(matches) - 1; j >= 0; j-- {
+				if i > 0 || matches[j].Start < from {
+					return matches[j], true
+				}
+			}
+			continue
+		}
+
+		for j := 0; j < len(matches); j++ {
+			if i > 0 || matches[j].Start > from {
+				return matches[j], true
+			}
+		}
+	}
+
+	return Match{}, false
+}
+
+// ReplaceSelection replaces the primary selection with template if the selection is a match (e.g. one selected by FindNext),
+// then selects the next match. It returns false if the selection wasn't a match.
+func (d *Document) ReplaceSelection(f *Finder, template string) bool {
+
+	start, end := d.Selection()
+	if start == end {
+		return false
+	}
+
+	lineNum := d.Buf.OffsetToLine(start)
+	line := d.Buf.Line(lineNum)
+	lineStart := d.Buf.LineStart(lineNum)
+
+	for _, loc := range f.findSubmatches(line) {
+
+		if lineStart+loc[0] != start || lineStart+loc[1] != end {
+			continue
+		}
+
+		repl := string(f.replacement(line, loc, template))
+
+		d.History.Break()
+		d.ClearExtraCursors()
+		d.Select(start, end)
+		d.Insert(repl)
+		d.History.Break()
+
+		if m, ok := d.FindNext(f, start+len(repl)-1, false); ok {
+			d.Select(m.Start, m.End)
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// ReplaceAll replaces every match with template as a single undo step, and returns how many matches were replaced
+func (d *Document) ReplaceAll(f *Finder, template string) int {
+	return d.ReplaceMatches(f, template, nil)
+}
+
+// ReplaceMatches is like ReplaceAll, but if keep isn't nil only the matches it returns true for are replaced.
+// lineNum is the line of the match and i is the index of the match in that line.
+func (d *Document) ReplaceMatches(f *Finder, template string, keep func(lineNum, i int) bool) int {
+
+	d.History.Break()
+	d.History.BeginGroup()
+	defer d.History.EndGroup()
+
+	//Going from the end means earlier offsets aren't moved by the replacements
+	count := 0
+	for i := d.Buf.LineCount() - 1; i >= 0; i-- {
+
+		line := d.Buf.Line(i)
+		lineStart := d.Buf.LineStart(i)
+		locs := f.findSubmatches(line)
+		for j := len(locs) - 1; j >= 0; j-- {
+
+			if keep != nil && !keep(i, j) {
+				continue
+			}
+
+			loc := locs[j]
+			repl := string(f.replacement(line, loc, template))
+			d.History.Delete(d.Buf, lineStart+loc[0], loc[1]-loc[0])
+			d.History.Insert(d.Buf, lineStart+loc[0], repl)
+			count++
+		}
+	}
+
+	if count > 0 {
+		d.SetCursorFromOffset(d.CursorOffset())
+	}
+
+	return count
+}
@@ -0,0 +1,93 @@
+package buffer
+
+import "bytes"
+
+// SaveOptions are the changes made to a document right before it is saved (e.g. from an .editorconfig)
+type SaveOptions struct {
+	TrimTrailingWhitespace bool
+
+	//LineEnding, if set, replaces every line ending in the document
+	LineEnding LineEnding
+
+	//FinalNewline is 1 to make sure the document ends with a line ending, -1 to remove the line endings it ends with,
+	//and 0 to leave it as it is
+	FinalNewline int
+}
+
+// PrepareSave applies opts to the document as a single undo step, and returns true if that changed the document
+func (d *Document) PrepareSave(opts SaveOptions) (changed bool) {
+
+	d.History.Break()
+	d.History.BeginGroup()
+	defer d.History.EndGroup()
+
+	//Cursors are only moved if something changed, so a save doesn't lose the selection
+	stepBefore := d.History.cur
+	cursorOff := d.CursorOffset()
+	if opts.TrimTrailingWhitespace {
+		cursorOff = d.trimTrailingWhitespace(cursorOff)
+	}
+
+	if opts.LineEnding != 0 {
+		d.SetLineEnding(opts.LineEnding)
+	}
+
+	switch opts.FinalNewline {
+
+	case 1:
+		if d.Buf.Len() > 0 && d.Buf.Slice(d.Buf.Len()-1, 1)[0] != '\n' {
+			d.History.Insert(d.Buf, d.Buf.Len(), "\n")
+		}
+
+	case -1:
+
+		end := d.Buf.Len()
+		for end > 0 && d.Buf.Slice(end-1, 1)[0] == '\n' {
+			end--
+		}
+
+		d.History.Delete(d.Buf, end, d.Buf.Len()-end)
+	}
+
+	if d.History.cur == stepBefore {
+		return false
+	}
+
+	d.SetCursorFromOffset(clampInt(cursorOff, 0, d.Buf.Len()))
+	return true
+}
+
+// trimTrailingWhitespace removes the spaces and tabs at the end of every line, and returns where off moves to
+func (d *Document) trimTrailingWhitespace(off int) int {
+
+	//Going from the end means earlier offsets aren't moved by the edits
+	for i := d.Buf.LineCount() - 1; i >= 0; i-- {
+
+		line := d.Buf.Line(i)
+		trimmed := bytes.TrimRight(line, " \t")
+		if len(trimmed) == len(line) {
+			continue
+		}
+
+		start := d.Buf.LineStart(i) + len(trimmed)
+		count := len(line) - len(trimmed)
+		d.History.Delete(d.Buf, start, count)
+		off = offsetAfterDelete(off, start, count)
+	}
+
+	return off
+}
+
+// offsetAfterDelete returns where off moves to after count bytes are deleted at start
+func offsetAfterDelete(off, start, count int) int {
+
+	if off <= start {
+		return off
+	}
+
+	if off < start+count {
+		return start
+	}
+
+	return off - count
+}
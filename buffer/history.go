@@ -0,0 +1,407 @@
+package buffer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Edit is a single change made to a buffer. If Insert is false then Text was deleted from Off.
+type Edit struct {
+	Off    int
+	Text   string
+	Insert bool
+
+	//Endings are the line endings of the lines a deletion joined, so undoing it can give them back (see LineEndings).
+	//It's nil when every line had the same ending.
+	Endings []LineEnding `json:",omitempty"`
+}
+
+func (ed *Edit) apply(b *Buffer) {
+
+	if ed.Insert {
+		b.InsertString(ed.Off, ed.Text)
+	} else {
+		b.Delete(ed.Off, len(ed.Text))
+	}
+}
+
+func (ed *Edit) revert(b *Buffer, endings *LineEndings) {
+
+	if ed.Insert {
+		b.Delete(ed.Off, len(ed.Text))
+		return
+	}
+
+	b.InsertString(ed.Off, ed.Text)
+	if endings != nil {
+		endings.restore(ed.Off, ed.Endings)
+	}
+}
+
+// step is one undo/redo unit, made of one or more edits that are undone together
+type step struct {
+	edits []Edit
+
+	//parent is the step that was current when this one was made, which undoing goes back to.
+	//redoChild is the child redoing goes to, which is the one made or undone from most recently, or -1 if there is none.
+	parent    int
+	redoChild int
+
+	//open is true while typing can still be merged into this step
+	open bool
+}
+
+// History records the edits made to a buffer so they can be undone and redone.
+//
+// Steps form a tree: a new edit after undoing starts a new branch instead of throwing away the steps
+// that were undone, and redoing follows the most recent branch (see NextRedoBranch to pick another).
+//
+// Consecutive typing (or backspacing) is merged into a single step a word at a time,
+// and everything done between BeginGroup and EndGroup is always a single step.
+type History struct {
+	//steps[0] is the root, which is the state before any edit and has no edits of its own
+	steps []step
+
+	//cur is the step whose edits were applied last
+	cur int
+
+	//savedStep is the step at which the buffer matched the file on disk
+	savedStep int
+
+	groupDepth   int
+	groupStarted bool
+
+	//endings are the line endings of the buffer, whose entries for deleted lines are recorded with the deletion
+	endings *LineEndings
+}
+
+// Insert inserts text into b and records it
+func (h *History) Insert(b *Buffer, off int, text string) {
+
+	if len(text) == 0 {
+		return
+	}
+
+	off = clampInt(off, 0, b.Len())
+	b.InsertString(off, text)
+	h.record(Edit{Off: off, Text: text, Insert: true})
+}
+
+// Delete deletes count bytes from b starting at off and records it. The deleted text is returned.
+func (h *History) Delete(b *Buffer, off, count int) string {
+
+	off = clampInt(off, 0, b.Len())
+	count = clampInt(count, 0, b.Len()-off)
+	if count == 0 {
+		return ""
+	}
+
+	ed := Edit{Off: off, Text: string(b.Slice(off, count))}
+	if h.endings != nil {
+		ed.Endings = h.endings.joined(off, count)
+	}
+
+	b.Delete(off, count)
+	h.record(ed)
+
+	return ed.Text
+}
+
+// BeginGroup starts a group of edits that will be undone and redone as one step. Groups can be nested,
+// in which case only the outermost group counts.
+func (h *History) BeginGroup() {
+
+	if h.groupDepth == 0 {
+		h.groupStarted = false
+	}
+
+	h.groupDepth++
+}
+
+func (h *History) EndGroup() {
+
+	if h.groupDepth > 0 {
+		h.groupDepth--
+	}
+}
+
+// Break stops the next edit from being merged into the current step (e.g. because the cursor moved)
+func (h *History) Break() {
+	h.steps[h.cur].open = false
+}
+
+func (h *History) record(ed Edit) {
+
+	if h.groupDepth > 0 && h.groupStarted {
+		s := &h.steps[h.cur]
+		s.edits = append(s.edits, ed)
+		return
+	}
+
+	if h.groupDepth == 0 && h.tryMerge(ed) {
+		return
+	}
+
+	//The steps that could be redone stay in the tree as another branch
+	h.steps = append(h.steps, step{
+		edits:     []Edit{ed},
+		parent:    h.cur,
+		redoChild: -1,
+		open:      h.groupDepth == 0 && isTyping(ed.Text),
+	})
+	h.steps[h.cur].redoChild = len(h.steps) - 1
+	h.cur = len(h.steps) - 1
+
+	if h.groupDepth > 0 {
+		h.groupStarted = true
+	}
+}
+
+// tryMerge adds ed to the last step if both are part of the same run of typing
+func (h *History) tryMerge(ed Edit) bool {
+
+	//Steps with children can't change, since their children were made on top of them
+	s := &h.steps[h.cur]
+	if h.cur == 0 || s.redoChild != -1 || h.savedStep == h.cur || !isTyping(ed.Text) {
+		return false
+	}
+
+	if !s.open || len(s.edits) != 1 || s.edits[0].Insert != ed.Insert {
+		return false
+	}
+
+	last := &s.edits[0]
+	if ed.Insert {
+
+		if ed.Off != last.Off+len(last.Text) {
+			return false
+		}
+
+		//Each word (and the spaces after it) is its own step
+		lastRune, _ := utf8.DecodeLastRuneInString(last.Text)
+		newRune, _ := utf8.DecodeRuneInString(ed.Text)
+		if unicode.IsSpace(lastRune) && !unicode.IsSpace(newRune) {
+			return false
+		}
+
+		last.Text += ed.Text
+		return true
+	}
+
+	//Backspace
+	if ed.Off+len(ed.Text) == last.Off {
+		last.Off = ed.Off
+		last.Text = ed.Text + last.Text
+		return true
+	}
+
+	//Delete key
+	if ed.Off == last.Off {
+		last.Text += ed.Text
+		return true
+	}
+
+	return false
+}
+
+// Undo reverts the last step applied to b. The returned offset is where the caret should be placed.
+func (h *History) Undo(b *Buffer) (caretOff int, ok bool) {
+
+	if !h.CanUndo() {
+		return 0, false
+	}
+
+	s := &h.steps[h.cur]
+	s.open = false
+
+	for i := len(s.edits) - 1; i >= 0; i-- {
+
+		ed := &s.edits[i]
+		ed.revert(b, h.endings)
+
+		if ed.Insert {
+			caretOff = ed.Off
+		} else {
+			caretOff = ed.Off + len(ed.Text)
+		}
+	}
+
+	//Redoing goes back down the branch we came from
+	h.steps[s.parent].redoChild = h.cur
+	h.cur = s.parent
+	return caretOff, true
+}
+
+// Redo re-applies the step of the current redo branch to b. The returned offset is where the caret should be placed.
+func (h *History) Redo(b *Buffer) (caretOff int, ok bool) {
+
+	if !h.CanRedo() {
+		return 0, false
+	}
+
+	h.steps[h.cur].open = false
+	h.cur = h.steps[h.cur].redoChild
+	s := &h.steps[h.cur]
+
+	for i := 0; i < len(s.edits); i++ {
+
+		ed := &s.edits[i]
+		ed.apply(b)
+
+		if ed.Insert {
+			caretOff = ed.Off + len(ed.Text)
+		} else {
+			caretOff = ed.Off
+		}
+	}
+
+	return caretOff, true
+}
+
+func (h *History) CanUndo() bool {
+	return h.cur != 0
+}
+
+func (h *History) CanRedo() bool {
+	return h.steps[h.cur].redoChild != -1
+}
+
+// RedoBranches returns how many branches can be redone from the current step, and the (0-based) index of the one Redo follows
+func (h *History) RedoBranches() (index, count int) {
+
+	children := h.children(h.cur)
+	for i, c := range children {
+		if c == h.steps[h.cur].redoChild {
+			index = i
+		}
+	}
+
+	return index, len(children)
+}
+
+// NextRedoBranch makes Redo follow the next branch from the current step, wrapping around after the newest one
+func (h *History) NextRedoBranch() {
+
+	index, count := h.RedoBranches()
+	if count < 2 {
+		return
+	}
+
+	h.steps[h.cur].redoChild = h.children(h.cur)[(index+1)%count]
+}
+
+// children returns the children of step i, oldest first
+func (h *History) children(i int) []int {
+
+	children := []int{}
+	for c := i + 1; c < len(h.steps); c++ {
+		if h.steps[c].parent == i {
+			children = append(children, c)
+		}
+	}
+
+	return children
+}
+
+// MarkSaved remembers the current state as the one matching the file on disk
+func (h *History) MarkSaved() {
+	h.savedStep = h.cur
+	h.Break()
+}
+
+// IsSaved returns true if the buffer is currently in the state last marked as saved
+func (h *History) IsSaved() bool {
+	return h.cur == h.savedStep
+}
+
+func isTyping(text string) bool {
+	return utf8.RuneCountInString(text) == 1 && !strings.ContainsAny(text, "\r\n")
+}
+
+func NewHistory() *History {
+	return &History{steps: []step{{parent: -1, redoChild: -1}}}
+}
+
+// JournalStep is a step of a journal, with Parent being the index of the step it was made on top of
+type JournalStep struct {
+	Parent int
+	Edits  []Edit
+}
+
+// Journal is a serializable copy of a history's tree of steps. Steps[0] is the root, which has no edits and a Parent of -1.
+// Saved is the step the file on disk matches, and Current the step the buffer was at when the journal was taken.
+type Journal struct {
+	Steps   []JournalStep
+	Saved   int
+	Current int
+}
+
+// Journal returns a copy of the history that can be persisted
+func (h *History) Journal() Journal {
+
+	j := Journal{
+		Steps:   make([]JournalStep, len(h.steps)),
+		Saved:   h.savedStep,
+		Current: h.cur,
+	}
+
+	for i := 0; i < len(h.steps); i++ {
+		j.Steps[i] = JournalStep{
+			Parent: h.steps[i].parent,
+			Edits:  append([]Edit{}, h.steps[i].edits...),
+		}
+	}
+
+	return j
+}
+
+// NewHistoryFromJournal restores a history from j. The buffer the history is used with must be in the saved state
+// of the journal (i.e. have the contents the file had when it was saved), so the history starts at the saved step.
+// Redoing leads towards the step that was current when the journal was taken.
+func NewHistoryFromJournal(j Journal) (*History, error) {
+
+	if len(j.Steps) == 0 || j.Steps[0].Parent != -1 || len(j.Steps[0].Edits) != 0 {
+		return nil, fmt.Errorf("journal has no root step")
+	}
+
+	if j.Saved < 0 || j.Saved >= len(j.Steps) || j.Current < 0 || j.Current >= len(j.Steps) {
+		return nil, fmt.Errorf("invalid journal saved step %d and current step %d with %d steps", j.Saved, j.Current, len(j.Steps))
+	}
+
+	h := &History{
+		steps:     make([]step, len(j.Steps)),
+		cur:       j.Saved,
+		savedStep: j.Saved,
+	}
+
+	h.steps[0] = step{parent: -1, redoChild: -1}
+	for i := 1; i < len(j.Steps); i++ {
+
+		js := j.Steps[i]
+		if len(js.Edits) == 0 {
+			return nil, fmt.Errorf("journal step %d has no edits", i)
+		}
+
+		//Parents always come before their children, which also rules out cycles
+		if js.Parent < 0 || js.Parent >= i {
+			return nil, fmt.Errorf("journal step %d has invalid parent %d", i, js.Parent)
+		}
+
+		h.steps[i] = step{
+			edits:     append([]Edit{}, js.Edits...),
+			parent:    js.Parent,
+			redoChild: -1,
+		}
+
+		//Newest children win, like when they were made
+		h.steps[js.Parent].redoChild = i
+	}
+
+	for i := j.Current; i > 0; i = h.steps[i].parent {
+		h.steps[h.steps[i].parent].redoChild = i
+	}
+
+	return h, nil
+}
@@ -0,0 +1,260 @@
+package buffer
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// typeText inserts text one char at a time like typing does
+func typeText(d *Document, text string) {
+	for _, c := range text {
+		d.Insert(string(c))
+	}
+}
+
+func TestHistoryUndoSteps(t *testing.T) {
+
+	tests := []struct {
+		name string
+		text string
+		edit func(d *Document)
+
+		//undone is the text after each undo, in order
+		undone []string
+	}{
+		{"typing is undone a word at a time", "", func(d *Document) { typeText(d, "hello big world") },
+			[]string{"hello big ", "hello ", ""}},
+		{"multi-byte typing merges", "", func(d *Document) { typeText(d, "日本 語") },
+			[]string{"日本 ", ""}},
+		{"backspacing merges", "abc", func(d *Document) {
+			d.MoveCursorToEnd(false)
+			d.DeleteBackward(1)
+			d.DeleteBackward(1)
+			d.DeleteBackward(1)
+		}, []string{"abc"}},
+		{"deleting forward merges", "abc", func(d *Document) {
+			d.DeleteForward(1)
+			d.DeleteForward(1)
+		}, []string{"abc"}},
+		{"moving the cursor breaks typing", "", func(d *Document) {
+			typeText(d, "ab")
+			d.MoveCursorX(-1, false)
+			d.MoveCursorX(1, false)
+			typeText(d, "c")
+		}, []string{"ab", ""}},
+		{"newlines are their own step", "", func(d *Document) { typeText(d, "a\nb") },
+			[]string{"a\n", "a", ""}},
+		{"typing then backspacing are different steps", "", func(d *Document) {
+			typeText(d, "ab")
+			d.DeleteBackward(1)
+		}, []string{"ab", ""}},
+		{"pasting is one step", "", func(d *Document) { d.PasteText("some words\nand lines") },
+			[]string{""}},
+		{"replacing a selection is one step", "abcd", func(d *Document) {
+			d.Select(1, 3)
+			d.Insert("X")
+		}, []string{"abcd"}},
+		{"groups are one step", "", func(d *Document) {
+			d.History.BeginGroup()
+			d.Insert("a")
+			d.Insert("\n")
+			d.History.BeginGroup()
+			d.Insert("b")
+			d.History.EndGroup()
+			d.DeleteBackward(2)
+			d.History.EndGroup()
+			typeText(d, "c")
+		}, []string{"a", ""}},
+		{"set text is one step", "one two", func(d *Document) { d.SetText("three") },
+			[]string{"one two"}},
+	}
+
+	for _, tt := range tests {
+
+		d := NewDocument([]byte(tt.text), 4)
+		tt.edit(d)
+
+		for i, expect := range tt.undone {
+
+			d.Undo()
+			if d.Buf.String() != expect {
+				t.Errorf("%s: expected %q after undo %d but got %q", tt.name, expect, i+1, d.Buf.String())
+				break
+			}
+		}
+
+		if d.History.CanUndo() {
+			t.Errorf("%s: expected nothing more to undo", tt.name)
+		}
+	}
+}
+
+func TestHistoryRedo(t *testing.T) {
+
+	d := NewDocument([]byte{}, 4)
+	typeText(d, "one two")
+	d.Undo()
+	d.Undo()
+
+	d.Redo()
+	d.Redo()
+	if d.Buf.String() != "one two" || d.History.CanRedo() {
+		t.Fatalf("expected everything redone but got %q", d.Buf.String())
+	}
+
+	//Typing after undoing means nothing can be redone from the new state
+	d.Undo()
+	typeText(d, "three")
+	if d.Buf.String() != "one three" || d.History.CanRedo() {
+		t.Fatalf("expected 'one three' with nothing to redo but got %q", d.Buf.String())
+	}
+}
+
+func TestHistorySavedState(t *testing.T) {
+
+	d := NewDocument([]byte("a"), 4)
+	if d.IsModified() {
+		t.Fatal("a new document shouldn't be modified")
+	}
+
+	d.MoveCursorToEnd(false)
+	typeText(d, "b")
+	d.MarkSaved()
+	typeText(d, "c")
+	if !d.IsModified() {
+		t.Fatal("expected typing after saving to modify the document")
+	}
+
+	//Typing right after saving isn't merged into the saved step, so undoing gets back to exactly the saved state
+	d.Undo()
+	if d.Buf.String() != "ab" || d.IsModified() {
+		t.Fatalf("expected the saved state 'ab' but got %q (modified %v)", d.Buf.String(), d.IsModified())
+	}
+
+	d.Undo()
+	if d.Buf.String() != "a" || !d.IsModified() {
+		t.Fatalf("expected 'a' to differ from the saved state but got %q (modified %v)", d.Buf.String(), d.IsModified())
+	}
+
+	d.Redo()
+	if d.IsModified() {
+		t.Fatal("expected redoing to the saved state to not be modified")
+	}
+}
+
+// branchedHistory types "a b" then undoes "b" and types "c", leaving "a c" with the "a b" branch still in the tree
+func branchedHistory(t *testing.T) (*Buffer, *History) {
+
+	t.Helper()
+	b := New([]byte{})
+	h := NewHistory()
+
+	h.Insert(b, 0, "a")
+	h.Insert(b, 1, " ")
+	h.Break()
+	h.Insert(b, 2, "b")
+	h.Undo(b)
+	h.Insert(b, 2, "c")
+
+	if b.String() != "a c" {
+		t.Fatalf("expected 'a c' but got %q", b.String())
+	}
+
+	return b, h
+}
+
+func TestHistoryBranches(t *testing.T) {
+
+	b, h := branchedHistory(t)
+
+	h.Undo(b)
+	if index, count := h.RedoBranches(); index != 1 || count != 2 {
+		t.Fatalf("expected redo branch 1 of 2 but got %d of %d", index, count)
+	}
+
+	h.Redo(b)
+	if b.String() != "a c" {
+		t.Fatalf("redo should follow the newest branch, but got %q", b.String())
+	}
+
+	h.Undo(b)
+	h.NextRedoBranch()
+	h.Redo(b)
+	if b.String() != "a b" {
+		t.Fatalf("redo should follow the picked branch, but got %q", b.String())
+	}
+}
+
+func TestHistoryJournalKeepsTree(t *testing.T) {
+
+	b, h := branchedHistory(t)
+	h.Undo(b)
+	h.MarkSaved()
+	h.Redo(b)
+
+	//The journal goes through JSON like when it is persisted
+	data, err := json.Marshal(h.Journal())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := Journal{}
+	if err := json.Unmarshal(data, &j); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewHistoryFromJournal(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//The restored history starts at the saved state, and redo leads back to where the journal was taken
+	b = New([]byte("a "))
+	if !restored.IsSaved() {
+		t.Fatal("restored history should be at the saved step")
+	}
+
+	restored.Redo(b)
+	if b.String() != "a c" {
+		t.Fatalf("expected redo to the current step 'a c' but got %q", b.String())
+	}
+
+	restored.Undo(b)
+	restored.NextRedoBranch()
+	restored.Redo(b)
+	if b.String() != "a b" {
+		t.Fatalf("expected the other branch 'a b' but got %q", b.String())
+	}
+
+	for restored.CanUndo() {
+		restored.Undo(b)
+	}
+
+	if b.String() != "" {
+		t.Fatalf("expected undoing everything to empty the buffer but got %q", b.String())
+	}
+}
+
+func TestHistoryFromInvalidJournal(t *testing.T) {
+
+	root := JournalStep{Parent: -1}
+	edit := []Edit{{Off: 0, Text: "a", Insert: true}}
+
+	tests := []struct {
+		name string
+		j    Journal
+	}{
+		{"no steps", Journal{}},
+		{"root with edits", Journal{Steps: []JournalStep{{Parent: -1, Edits: edit}}}},
+		{"saved out of range", Journal{Steps: []JournalStep{root}, Saved: 1}},
+		{"current out of range", Journal{Steps: []JournalStep{root}, Current: -1}},
+		{"step without edits", Journal{Steps: []JournalStep{root, {Parent: 0}}}},
+		{"parent after child", Journal{Steps: []JournalStep{root, {Parent: 1, Edits: edit}}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewHistoryFromJournal(tt.j); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package buffer
+
+// Line is a view of one line of a buffer. It doesn't hold any chars itself,
+// so lines of any length are cheap to get and only the requested range of chars is ever decoded.
+//
+// Lines are laid out on a grid where every char takes one column, except tabs which take TabSize columns.
+type Line struct {
+	buf     *Buffer
+	tabSize int
+
+	//Byte offsets of the line in buf, not including the newline
+	start int
+	end   int
+
+	runeStart int
+	runeCount int
+}
+
+func (l *Line) RuneCount() int {
+	return l.runeCount
+}
+
+// Start returns the byte offset of the line in the buffer
+func (l *Line) Start() int {
+	return l.start
+}
+
+// End returns the byte offset of the end of the line in the buffer, not including the newline
+func (l *Line) End() int {
+	return l.end
+}
+
+// Chars returns the runes in [from, from+count), clamped to the line
+func (l *Line) Chars(from, count int) []rune {
+
+	from = clampInt(from, 0, l.runeCount)
+	count = clampInt(count, 0, l.runeCount-from)
+	if count == 0 {
+		return []rune{}
+	}
+
+	start := l.ByteOffset(from)
+	return []rune(string(l.buf.Slice(start, l.ByteOffset(from+count)-start)))
+}
+
+// String returns the whole line. Prefer Chars for lines that might be long.
+func (l *Line) String() string {
+	return string(l.buf.Slice(l.start, l.end-l.start))
+}
+
+func (l *Line) CharAt(i int) rune {
+
+	c := l.Chars(i, 1)
+	if len(c) == 0 {
+		return 0
+	}
+
+	return c[0]
+}
+
+// ByteOffset returns the offset in the buffer of the char at index i of this line
+func (l *Line) ByteOffset(i int) int {
+
+	if i <= 0 {
+		return l.start
+	}
+
+	if i >= l.runeCount {
+		return l.end
+	}
+
+	return l.buf.ByteOffset(l.runeStart + i)
+}
+
+// CharIndex returns the index in this line of the char at byte offset off of the buffer
+func (l *Line) CharIndex(off int) int {
+	off = clampInt(off, l.start, l.end)
+	return l.buf.RuneOffset(off) - l.runeStart
+}
+
+// TabCount returns the number of tabs in chars [from, to)
+func (l *Line) TabCount(from, to int) int {
+	return l.buf.TabCount(l.ByteOffset(from), l.ByteOffset(to))
+}
+
+// GridWidth returns how many grid columns the first n chars of the line take
+func (l *Line) GridWidth(n int) int {
+	n = clampInt(n, 0, l.runeCount)
+	return n + l.TabCount(0, n)*(l.tabSize-1)
+}
+
+// CharIndexFromGridX returns the index of the char that covers grid column gridX,
+// or -1 if gridX is before the first char
+func (l *Line) CharIndexFromGridX(gridX int) int {
+
+	if gridX <= 0 || l.RuneCount() == 0 {
+		return -1
+	}
+
+	if gridX >= l.GridWidth(l.RuneCount()) {
+		return l.RuneCount() - 1
+	}
+
+	//Grid width only grows as we add chars, so we binary search for the first char whose
+	//right edge reaches gridX. This avoids decoding everything before gridX on long lines.
+	lo, hi := 0, l.RuneCount()-1
+	for lo < hi {
+
+		mid := (lo + hi) / 2
+		if l.GridWidth(mid+1) < gridX {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	return lo
+}
+
+// ColFromGridX returns the cursor column (i.e. the index of the char the cursor would be before)
+// for a cursor placed at gridX
+func (l *Line) ColFromGridX(gridX int) int {
+	return l.CharIndexFromGridX(gridX) + 1
+}
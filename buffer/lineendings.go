@@ -0,0 +1,280 @@
+package buffer
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"strings"
+)
+
+// LineEnding is the byte sequence that ends a line in a file. The zero value means no line ending was chosen.
+type LineEnding uint8
+
+const (
+	LF LineEnding = iota + 1
+	CRLF
+	CR
+)
+
+func (le LineEnding) String() string {
+
+	switch le {
+	case LF:
+		return "LF"
+	case CRLF:
+		return "CRLF"
+	case CR:
+		return "CR"
+	}
+
+	return ""
+}
+
+// Text returns the bytes of the line ending
+func (le LineEnding) Text() string {
+
+	switch le {
+	case CRLF:
+		return "\r\n"
+	case CR:
+		return "\r"
+	}
+
+	return "\n"
+}
+
+// PlatformLineEnding is the line ending of files that don't have any lines yet
+func PlatformLineEnding() LineEnding {
+
+	if runtime.GOOS == "windows" {
+		return CRLF
+	}
+
+	return LF
+}
+
+// LineEndings remembers the line ending of every line of a document. Documents only have '\n' line endings
+// in their buffer, so every file looks the same while editing and is written back with the endings it had.
+type LineEndings struct {
+	//Default is the ending new lines get, which is the most common one in the file
+	Default LineEnding
+
+	//perLine is the ending of every line when the file has more than one kind, and nil otherwise.
+	//The entry of the last line is unused since the last line has no ending.
+	perLine []LineEnding
+	counts  [CR + 1]int
+
+	buf       *Buffer
+	lineCount int
+}
+
+// Mixed returns true if the document has more than one kind of line ending
+func (le *LineEndings) Mixed() bool {
+
+	if le.perLine == nil {
+		return false
+	}
+
+	counts := le.counts
+	counts[le.perLine[len(le.perLine)-1]]--
+
+	kinds := 0
+	for _, c := range counts {
+		if c > 0 {
+			kinds++
+		}
+	}
+
+	return kinds > 1
+}
+
+// Set makes every line end with ending
+func (le *LineEndings) Set(ending LineEnding) {
+	le.Default = ending
+	le.perLine = nil
+	le.counts = [CR + 1]int{}
+}
+
+// Ending returns the line ending of the given line
+func (le *LineEndings) Ending(lineNum int) LineEnding {
+
+	if le.perLine == nil || lineNum < 0 || lineNum >= len(le.perLine) {
+		return le.Default
+	}
+
+	return le.perLine[lineNum]
+}
+
+func (le *LineEndings) edit(off, removed, inserted int) {
+
+	lineCount := le.buf.LineCount()
+	delta := lineCount - le.lineCount
+	le.lineCount = lineCount
+	if le.perLine == nil || delta == 0 {
+		return
+	}
+
+	//A line that is split keeps its ending on its last part, and lines that are joined take the ending of the last one
+	lineNum := le.buf.OffsetToLine(off)
+	if delta > 0 {
+
+		added := make([]LineEnding, delta)
+		for i := range added {
+			added[i] = le.Default
+		}
+
+		le.perLine = append(le.perLine[:lineNum], append(added, le.perLine[lineNum:]...)...)
+		le.counts[le.Default] += delta
+		return
+	}
+
+	for _, e := range le.perLine[lineNum : lineNum-delta] {
+		le.counts[e]--
+	}
+
+	le.perLine = append(le.perLine[:lineNum], le.perLine[lineNum-delta:]...)
+}
+
+// joined returns the endings of the lines that deleting count bytes at off would join, or nil if there are none
+// or every line has the same ending
+func (le *LineEndings) joined(off, count int) []LineEnding {
+
+	if le.perLine == nil {
+		return nil
+	}
+
+	first := le.buf.OffsetToLine(off)
+	last := le.buf.OffsetToLine(off + count)
+	if first == last {
+		return nil
+	}
+
+	return append([]LineEnding{}, le.perLine[first:last]...)
+}
+
+// restore gives the lines starting at the line of off the given endings, which undoes a deletion
+// whose joined lines were re-inserted (and so got the default ending)
+func (le *LineEndings) restore(off int, endings []LineEnding) {
+
+	if le.perLine == nil || len(endings) == 0 {
+		return
+	}
+
+	lineNum := le.buf.OffsetToLine(off)
+	for i, e := range endings {
+
+		if lineNum+i >= len(le.perLine) {
+			break
+		}
+
+		le.counts[le.perLine[lineNum+i]]--
+		le.perLine[lineNum+i] = e
+		le.counts[e]++
+	}
+}
+
+// WriteTo writes the buffer starting at byte offset from, with each '\n' replaced by the ending of its line
+func (le *LineEndings) WriteTo(w io.Writer, from int) (written int64, err error) {
+
+	lineNum := le.buf.OffsetToLine(from)
+	le.buf.Walk(from, le.buf.Len(), func(chunk []byte) bool {
+
+		for len(chunk) > 0 {
+
+			nl := bytes.IndexByte(chunk, '\n')
+			if nl == -1 {
+				nl = len(chunk)
+			}
+
+			n, wErr := w.Write(chunk[:nl])
+			written += int64(n)
+			if wErr != nil {
+				err = wErr
+				return false
+			}
+
+			if nl == len(chunk) {
+				break
+			}
+
+			n, wErr = io.WriteString(w, le.Ending(lineNum).Text())
+			written += int64(n)
+			if wErr != nil {
+				err = wErr
+				return false
+			}
+
+			lineNum++
+			chunk = chunk[nl+1:]
+		}
+
+		return true
+	})
+
+	return written, err
+}
+
+// splitLineEndings returns text with all line endings turned into '\n', along with the ending of each line
+func splitLineEndings(text []byte) (normalized []byte, endings *LineEndings) {
+
+	endings = &LineEndings{}
+	if bytes.IndexByte(text, '\r') == -1 {
+
+		endings.Default = LF
+		if bytes.IndexByte(text, '\n') == -1 {
+			endings.Default = PlatformLineEnding()
+		}
+
+		return text, endings
+	}
+
+	normalized = make([]byte, 0, len(text))
+	perLine := make([]LineEnding, 0, bytes.Count(text, []byte{'\n'})+1)
+	for i := 0; i < len(text); i++ {
+
+		switch c := text[i]; {
+		case c == '\r' && i+1 < len(text) && text[i+1] == '\n':
+			perLine = append(perLine, CRLF)
+			i++
+		case c == '\r':
+			perLine = append(perLine, CR)
+		case c == '\n':
+			perLine = append(perLine, LF)
+		default:
+			normalized = append(normalized, c)
+			continue
+		}
+
+		normalized = append(normalized, '\n')
+		endings.counts[perLine[len(perLine)-1]]++
+	}
+
+	//The most common ending wins, with ties going to LF then CRLF
+	endings.Default = LF
+	for _, e := range [...]LineEnding{CRLF, CR} {
+		if endings.counts[e] > endings.counts[endings.Default] {
+			endings.Default = e
+		}
+	}
+
+	//The last line has no ending, but still gets an entry
+	perLine = append(perLine, endings.Default)
+	endings.counts[endings.Default]++
+
+	endings.perLine = perLine
+	if !endings.Mixed() {
+		endings.Set(endings.Default)
+	}
+
+	return normalized, endings
+}
+
+// normalizeLineEndings turns all line endings in text into '\n'
+func normalizeLineEndings(text string) string {
+
+	if !strings.Contains(text, "\r") {
+		return text
+	}
+
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+}
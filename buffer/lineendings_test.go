@@ -0,0 +1,105 @@
+package buffer
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// fileText returns the text of d as it would be written to its file
+func fileText(t *testing.T, d *Document) string {
+
+	t.Helper()
+	sb := bytes.Buffer{}
+	if _, err := d.Endings.WriteTo(&sb, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	return sb.String()
+}
+
+func TestLineEndingsUndo(t *testing.T) {
+
+	const mixed = "a\r\nb\nc\rd\r\ne"
+
+	tests := []struct {
+		name   string
+		edit   func(d *Document)
+		expect string
+	}{
+		{"delete lines", func(d *Document) { d.Select(1, 7) }, "a\r\ne"},
+		{"delete line ending", func(d *Document) {
+			d.SetCursor(1, 1)
+			d.DeleteForward(1)
+		}, "a\r\nbc\rd\r\ne"},
+		{"delete everything", func(d *Document) { d.SelectAll() }, ""},
+		{"replace lines", func(d *Document) {
+			d.Select(2, 7)
+			d.Insert("x\ny")
+		}, "a\r\nx\r\ny\r\ne"},
+		{"set text", func(d *Document) { d.SetText("a\nd\ne") }, "a\r\nd\r\ne"},
+	}
+
+	for _, tt := range tests {
+
+		d := NewDocument([]byte(mixed), 4)
+		tt.edit(d)
+		if d.HasSelection() {
+			d.DeleteBackward(1)
+		}
+
+		if fileText(t, d) != tt.expect {
+			t.Errorf("%s: expected %q after editing but got %q", tt.name, tt.expect, fileText(t, d))
+		}
+
+		for d.History.CanUndo() {
+			d.Undo()
+		}
+
+		if fileText(t, d) != mixed || !d.Endings.Mixed() {
+			t.Errorf("%s: expected undoing to restore %q but got %q", tt.name, mixed, fileText(t, d))
+		}
+
+		for d.History.CanRedo() {
+			d.Redo()
+		}
+
+		if fileText(t, d) != tt.expect {
+			t.Errorf("%s: expected %q after redoing but got %q", tt.name, tt.expect, fileText(t, d))
+		}
+	}
+}
+
+func TestLineEndingsUndoFromJournal(t *testing.T) {
+
+	const mixed = "a\r\nb\nc"
+
+	d := NewDocument([]byte(mixed), 4)
+	d.MarkSaved()
+	d.SelectAll()
+	d.DeleteBackward(1)
+
+	//Like reopening a file whose history was persisted
+	data, err := json.Marshal(d.History.Journal())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := Journal{}
+	if err := json.Unmarshal(data, &j); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewHistoryFromJournal(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d = NewDocument([]byte(mixed), 4)
+	d.SetHistory(h)
+	d.Redo()
+	d.Undo()
+	if fileText(t, d) != mixed {
+		t.Errorf("expected %q but got %q", mixed, fileText(t, d))
+	}
+}
@@ -0,0 +1,107 @@
+package buffer
+
+import "unicode"
+
+// HasSelection returns true if the anchor and the cursor are at different positions
+func (d *Document) HasSelection() bool {
+	return d.Cursor.Line != d.Cursor.AnchorLine || d.Cursor.Col != d.Cursor.AnchorCol
+}
+
+// Selection returns the byte offsets [start, end) of the selection of the primary cursor. start == end if nothing is selected.
+func (d *Document) Selection() (start, end int) {
+	return d.SelectionOf(d.Cursor)
+}
+
+// SelectionOf returns the byte offsets [start, end) of the selection of c
+func (d *Document) SelectionOf(c Cursor) (start, end int) {
+
+	start = d.PosToOffset(c.Line, c.Col)
+	end = d.PosToOffset(c.AnchorLine, c.AnchorCol)
+	if start > end {
+		start, end = end, start
+	}
+
+	return start, end
+}
+
+func (d *Document) SelectedText() string {
+	start, end := d.Selection()
+	return string(d.Buf.Slice(start, end-start))
+}
+
+func (d *Document) ClearSelection() {
+	d.Cursor.AnchorLine = d.Cursor.Line
+	d.Cursor.AnchorCol = d.Cursor.Col
+}
+
+// Select selects the byte offsets [start, end) with the cursor placed at end. Extra cursors are cleared.
+func (d *Document) Select(start, end int) {
+	d.SetCursorFromOffset(start)
+	d.setCursorFromOffset(end, true)
+}
+
+func (d *Document) SelectAll() {
+	d.Select(0, d.Buf.Len())
+	d.History.Break()
+}
+
+// SelectWord selects the word at the given position. If the position is on whitespace then the
+// whitespace run is selected instead, and any other char is selected on its own.
+func (d *Document) SelectWord(lineNum, col int) {
+
+	start, end := d.wordRange(lineNum, col)
+	d.Select(start, end)
+	d.History.Break()
+}
+
+// wordRange returns the byte offsets of the word at the given position (see SelectWord)
+func (d *Document) wordRange(lineNum, col int) (start, end int) {
+
+	l := d.Line(lineNum)
+	if l.RuneCount() == 0 {
+		return l.Start(), l.Start()
+	}
+
+	chars := l.Chars(0, l.RuneCount())
+
+	//Prefer the char after the position, unless we are at the end of the line
+	col = clampInt(col, 0, len(chars)-1)
+	class := charClass(chars[col])
+
+	startCol := col
+	for startCol > 0 && charClass(chars[startCol-1]) == class && class != charClassOther {
+		startCol--
+	}
+
+	endCol := col + 1
+	for endCol < len(chars) && charClass(chars[endCol]) == class && class != charClassOther {
+		endCol++
+	}
+
+	return l.ByteOffset(startCol), l.ByteOffset(endCol)
+}
+
+// SelectLine selects the whole line including its newline
+func (d *Document) SelectLine(lineNum int) {
+	d.Select(d.Buf.LineStart(lineNum), d.Buf.LineStart(lineNum+1))
+	d.History.Break()
+}
+
+const (
+	charClassOther = iota
+	charClassWord
+	charClassSpace
+)
+
+func charClass(r rune) int {
+
+	if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+		return charClassWord
+	}
+
+	if unicode.IsSpace(r) {
+		return charClassSpace
+	}
+
+	return charClassOther
+}
@@ -0,0 +1,395 @@
+// Package charset detects the text encoding of files and converts them to and from UTF-8
+package charset
+
+import (
+	"bytes"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+type Encoding int
+
+const (
+	UTF8 Encoding = iota
+	UTF8BOM
+	UTF16LE
+	UTF16LEBOM
+	UTF16BE
+	UTF16BEBOM
+	Latin1
+	Windows1252
+	ShiftJIS
+)
+
+// All lists the supported encodings in the order they are shown
+var All = []Encoding{UTF8, UTF8BOM, UTF16LE, UTF16LEBOM, UTF16BE, UTF16BEBOM, Latin1, Windows1252, ShiftJIS}
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+func (e Encoding) String() string {
+
+	switch e {
+	case UTF8:
+		return "UTF-8"
+	case UTF8BOM:
+		return "UTF-8 with BOM"
+	case UTF16LE:
+		return "UTF-16 LE"
+	case UTF16LEBOM:
+		return "UTF-16 LE with BOM"
+	case UTF16BE:
+		return "UTF-16 BE"
+	case UTF16BEBOM:
+		return "UTF-16 BE with BOM"
+	case Latin1:
+		return "ISO-8859-1"
+	case Windows1252:
+		return "Windows-1252"
+	case ShiftJIS:
+		return "Shift-JIS"
+	}
+
+	return "Unknown"
+}
+
+// ByName returns the encoding whose String() is name
+func ByName(name string) (Encoding, bool) {
+
+	for _, e := range All {
+		if e.String() == name {
+			return e, true
+		}
+	}
+
+	return UTF8, false
+}
+
+func (e Encoding) bom() []byte {
+
+	switch e {
+	case UTF8BOM:
+		return bomUTF8
+	case UTF16LEBOM:
+		return bomUTF16LE
+	case UTF16BEBOM:
+		return bomUTF16BE
+	}
+
+	return nil
+}
+
+// HasBOM returns true if the encoding starts files with a byte order mark
+func (e Encoding) HasBOM() bool {
+	return e.bom() != nil
+}
+
+// FromEditorConfig returns the encoding of an .editorconfig charset. Only utf-8-bom has a byte order mark.
+func FromEditorConfig(charset string) (Encoding, bool) {
+
+	switch charset {
+	case "utf-8":
+		return UTF8, true
+	case "utf-8-bom":
+		return UTF8BOM, true
+	case "utf-16le":
+		return UTF16LE, true
+	case "utf-16be":
+		return UTF16BE, true
+	case "latin1":
+		return Latin1, true
+	}
+
+	return UTF8, false
+}
+
+// DetectBOM returns the encoding of data if it starts with a byte order mark
+func DetectBOM(data []byte) (Encoding, bool) {
+
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return UTF8BOM, true
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return UTF16LEBOM, true
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return UTF16BEBOM, true
+	}
+
+	return UTF8, false
+}
+
+// Detect guesses the encoding of data. A byte order mark is trusted, then valid UTF-8 is taken as UTF-8,
+// then text with many zero bytes on one side of each pair is taken as UTF-16. Anything else is a single byte encoding.
+//
+// Shift-JIS is never guessed since its bytes are just as likely to be Windows-1252, so it has to be picked by the user.
+func Detect(data []byte) Encoding {
+
+	if enc, ok := DetectBOM(data); ok {
+		return enc
+	}
+
+	if utf8.Valid(data) && !looksLikeUTF16(data) {
+		return UTF8
+	}
+
+	if len(data)%2 == 0 && len(data) > 0 {
+
+		zerosEven, zerosOdd := 0, 0
+		for i := 0; i < len(data); i += 2 {
+			if data[i] == 0 {
+				zerosEven++
+			}
+			if data[i+1] == 0 {
+				zerosOdd++
+			}
+		}
+
+		//Mostly ASCII text in UTF-16 has a zero in almost every pair
+		pairs := len(data) / 2
+		if zerosOdd*10 >= pairs*3 && zerosEven*10 < pairs {
+			return UTF16LE
+		}
+
+		if zerosEven*10 >= pairs*3 && zerosOdd*10 < pairs {
+			return UTF16BE
+		}
+	}
+
+	//Windows-1252 uses 0x80-0x9f for printable chars where ISO-8859-1 has control codes that are rare in text
+	for _, b := range data {
+		if b >= 0x80 && b <= 0x9f {
+			return Windows1252
+		}
+	}
+
+	return Latin1
+}
+
+// looksLikeUTF16 returns true if valid UTF-8 is more likely UTF-16 text, which is valid UTF-8 when it is mostly ASCII
+// since the zero bytes are NUL chars
+func looksLikeUTF16(data []byte) bool {
+
+	if len(data) < 4 || len(data)%2 != 0 {
+		return false
+	}
+
+	zeros := bytes.Count(data, []byte{0})
+	return zeros*10 >= len(data)*3
+}
+
+// Decode converts data from enc to UTF-8, dropping the byte order mark if there is one.
+// lossless is false if data has byte sequences that aren't valid in enc, which were replaced with U+FFFD and
+// so won't be written back as they were.
+//
+// UTF-8 is kept as it is, so invalid UTF-8 is still written back unchanged.
+func Decode(data []byte, enc Encoding) (text []byte, lossless bool) {
+
+	data = bytes.TrimPrefix(data, enc.bom())
+	switch enc {
+
+	case UTF8, UTF8BOM:
+		return data, true
+
+	case UTF16LE, UTF16LEBOM, UTF16BE, UTF16BEBOM:
+
+		lossless = len(data)%2 == 0
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if enc == UTF16LE || enc == UTF16LEBOM {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+
+		text = make([]byte, 0, len(units))
+		for i := 0; i < len(units); i++ {
+
+			r := rune(units[i])
+			switch {
+			case utf16.IsSurrogate(r) && i+1 < len(units):
+
+				r = utf16.DecodeRune(r, rune(units[i+1]))
+				if r == utf8.RuneError {
+					lossless = false
+				} else {
+					i++
+				}
+
+			case utf16.IsSurrogate(r):
+				r = utf8.RuneError
+				lossless = false
+			}
+
+			text = utf8.AppendRune(text, r)
+		}
+
+		return text, lossless
+
+	case Latin1:
+
+		text = make([]byte, 0, len(data))
+		for _, b := range data {
+			text = utf8.AppendRune(text, rune(b))
+		}
+		return text, true
+
+	case Windows1252:
+
+		text = make([]byte, 0, len(data))
+		for _, b := range data {
+			text = utf8.AppendRune(text, windows1252Rune(b))
+		}
+		return text, true
+
+	case ShiftJIS:
+
+		//Invalid sequences decode as U+FFFD, which Shift-JIS can't hold itself
+		text, err := japanese.ShiftJIS.NewDecoder().Bytes(data)
+		if err != nil {
+			return bytes.ToValidUTF8(data, []byte("\uFFFD")), false
+		}
+
+		return text, !bytes.ContainsRune(text, utf8.RuneError)
+	}
+
+	return data, true
+}
+
+// Encode converts UTF-8 text to enc, adding the byte order mark if the encoding has one.
+// Chars that enc can't represent are written as '?' and counted in unencodable.
+func Encode(text []byte, enc Encoding) (data []byte, unencodable int) {
+
+	switch enc {
+
+	case UTF8:
+		return text, 0
+
+	case UTF8BOM:
+		return append(append(make([]byte, 0, len(text)+3), bomUTF8...), text...), 0
+
+	case UTF16LE, UTF16LEBOM, UTF16BE, UTF16BEBOM:
+
+		data = make([]byte, 0, len(text)*2+2)
+		data = append(data, enc.bom()...)
+		bigEndian := enc == UTF16BE || enc == UTF16BEBOM
+		appendUnit := func(u uint16) {
+			if bigEndian {
+				data = append(data, byte(u>>8), byte(u))
+			} else {
+				data = append(data, byte(u), byte(u>>8))
+			}
+		}
+
+		for len(text) > 0 {
+
+			r, size := utf8.DecodeRune(text)
+			text = text[size:]
+			if r == utf8.RuneError && size == 1 {
+				unencodable++
+				r = '?'
+			}
+
+			if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+				appendUnit(uint16(r1))
+				appendUnit(uint16(r2))
+			} else {
+				appendUnit(uint16(r))
+			}
+		}
+
+		return data, unencodable
+
+	case Latin1, Windows1252:
+
+		data = make([]byte, 0, len(text))
+		for len(text) > 0 {
+
+			r, size := utf8.DecodeRune(text)
+			text = text[size:]
+
+			b, ok := byte(r), r < 0x100
+			if enc == Windows1252 {
+				b, ok = windows1252Byte(r)
+			}
+
+			//Invalid UTF-8 decodes as U+FFFD, which neither encoding has
+			if !ok {
+				unencodable++
+				b = '?'
+			}
+
+			data = append(data, b)
+		}
+
+		return data, unencodable
+
+	case ShiftJIS:
+
+		if data, err := japanese.ShiftJIS.NewEncoder().Bytes(text); err == nil {
+			return data, 0
+		}
+
+		//The encoder stops at the first char it can't encode, so the text is encoded a char at a time to replace them all
+		enc := japanese.ShiftJIS.NewEncoder()
+		data = make([]byte, 0, len(text))
+		for len(text) > 0 {
+
+			r, size := utf8.DecodeRune(text)
+			if r < utf8.RuneSelf {
+				data = append(data, byte(r))
+				text = text[size:]
+				continue
+			}
+
+			b, err := enc.Bytes(text[:size])
+			if err != nil || r == utf8.RuneError {
+				unencodable++
+				b = []byte{'?'}
+			}
+
+			data = append(data, b...)
+			text = text[size:]
+		}
+
+		return data, unencodable
+	}
+
+	return text, 0
+}
+
+// windows1252High are the chars of bytes 0x80-0x9f. Bytes Windows-1252 doesn't define map to the control codes
+// of the same value, like ISO-8859-1, so that every byte round-trips.
+var windows1252High = [32]rune{
+	0x20ac, 0x81, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021, 0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0x8d, 0x017d, 0x8f,
+	0x90, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014, 0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0x9d, 0x017e, 0x0178,
+}
+
+func windows1252Rune(b byte) rune {
+
+	if b >= 0x80 && b <= 0x9f {
+		return windows1252High[b-0x80]
+	}
+
+	return rune(b)
+}
+
+func windows1252Byte(r rune) (byte, bool) {
+
+	if r < 0x80 || (r >= 0xa0 && r <= 0xff) {
+		return byte(r), true
+	}
+
+	for i, hr := range windows1252High {
+		if hr == r {
+			return byte(0x80 + i), true
+		}
+	}
+
+	return 0, false
+}
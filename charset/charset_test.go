@@ -0,0 +1,73 @@
+package charset
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestShiftJIS(t *testing.T) {
+
+	tests := []struct {
+		name string
+		text string
+		data []byte
+
+		unencodable int
+	}{
+		{"ascii", "a\\b\n", []byte("a\\b\n"), 0},
+		{"kana and kanji", "日本語 ｶﾀｶﾅ", []byte{0x93, 0xfa, 0x96, 0x7b, 0x8c, 0xea, ' ', 0xb6, 0xc0, 0xb6, 0xc5}, 0},
+		{"unencodable chars become '?'", "é日🙂", []byte{'?', 0x93, 0xfa, '?'}, 2},
+		{"invalid utf-8 becomes '?'", "a\xffb", []byte("a?b"), 1},
+	}
+
+	for _, tt := range tests {
+
+		data, unencodable := Encode([]byte(tt.text), ShiftJIS)
+		if !bytes.Equal(data, tt.data) || unencodable != tt.unencodable {
+			t.Errorf("%s: expected %x with %d unencodable but got %x with %d", tt.name, tt.data, tt.unencodable, data, unencodable)
+		}
+
+		if tt.unencodable > 0 {
+			continue
+		}
+
+		text, lossless := Decode(tt.data, ShiftJIS)
+		if string(text) != tt.text || !lossless {
+			t.Errorf("%s: expected %q to decode losslessly but got %q (lossless %v)", tt.name, tt.text, text, lossless)
+		}
+	}
+
+	//A lead byte without its trail byte isn't valid
+	if text, lossless := Decode([]byte{'a', 0x93}, ShiftJIS); lossless || string(text) != "a�" {
+		t.Errorf("expected a lossy decode to 'a\\uFFFD' but got %q (lossless %v)", text, lossless)
+	}
+
+	if enc, ok := ByName(ShiftJIS.String()); !ok || enc != ShiftJIS {
+		t.Errorf("expected ByName to find Shift-JIS")
+	}
+}
+
+func TestFromEditorConfig(t *testing.T) {
+
+	tests := []struct {
+		charset string
+		enc     Encoding
+		ok      bool
+	}{
+		{"utf-8", UTF8, true},
+		{"utf-8-bom", UTF8BOM, true},
+		{"utf-16le", UTF16LE, true},
+		{"utf-16be", UTF16BE, true},
+		{"latin1", Latin1, true},
+		{"", UTF8, false},
+		{"utf-32", UTF8, false},
+	}
+
+	for _, tt := range tests {
+
+		enc, ok := FromEditorConfig(tt.charset)
+		if enc != tt.enc || ok != tt.ok {
+			t.Errorf("%q: expected %v, %v but got %v, %v", tt.charset, tt.enc, tt.ok, enc, ok)
+		}
+	}
+}
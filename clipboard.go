@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const clipboardPreviewLen = 60
+
+// sdlClipboard is the system clipboard
+type sdlClipboard struct{}
+
+func (sdlClipboard) SetText(text string) error {
+	return sdl.SetClipboardText(text)
+}
+
+func (sdlClipboard) Text() (string, error) {
+	return sdl.GetClipboardText()
+}
+
+func (g *Gopad) copy(e *Editor, cut bool) {
+
+	var err error
+	if cut {
+		err = e.Cut(g.clipboard)
+	} else {
+		err = e.Copy(g.clipboard)
+	}
+
+	if err != nil {
+		g.triggerError("Failed to copy to clipboard. Error: " + err.Error())
+	}
+}
+
+func (g *Gopad) paste(e *Editor) {
+
+	err := e.Paste(g.clipboard)
+	if err != nil {
+		g.triggerError("Failed to paste from clipboard. Error: " + err.Error())
+	}
+}
+
+// drawClipboardHistory shows the texts copied recently, and pastes the one clicked into the active editor
+func (g *Gopad) drawClipboardHistory() {
+
+	imgui.SetNextWindowPos(imgui.Vec2{X: g.winWidth * 0.5, Y: g.winHeight * 0.5})
+	if !imgui.BeginPopup("clipboardHistory") {
+		return
+	}
+
+	if len(g.clipboard.Entries) == 0 {
+		imgui.Text("Nothing copied yet")
+	}
+
+	for i := 0; i < len(g.clipboard.Entries); i++ {
+
+		text := g.clipboard.Entries[i]
+
+		//The index keeps the imgui IDs unique
+		if imgui.Selectable(clipboardPreview(text) + "##" + strconv.Itoa(i)) {
+			g.getActiveEditor().PasteText(text)
+		}
+	}
+
+	imgui.EndPopup()
+}
+
+// clipboardPreview returns the first line of text, shortened to fit in a menu
+func clipboardPreview(text string) string {
+
+	lineCount := strings.Count(text, "\n") + 1
+	if i := strings.IndexByte(text, '\n'); i != -1 {
+		text = text[:i]
+	}
+
+	text = strings.ReplaceAll(text, "\t", " ")
+	if runes := []rune(text); len(runes) > clipboardPreviewLen {
+		text = string(runes[:clipboardPreviewLen]) + "..."
+	}
+
+	if lineCount > 1 {
+		text += " (+" + strconv.Itoa(lineCount-1) + " lines)"
+	}
+
+	return text
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"github.com/bloeys/nmage/engine"
+	"github.com/bloeys/nmage/logging"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// closeGuard is a close of editors with unsaved changes that waits for the user to save or discard them
+type closeGuard struct {
+	//editorIDs are the modified editors being closed, and quitting is true if Gopad exits once they are dealt with
+	editorIDs []int
+	quitting  bool
+}
+
+// requestClose closes the editor at index i at the end of the frame, first asking what to do with its unsaved changes if it has any
+func (g *Gopad) requestClose(i int) {
+
+	if len(g.closeGuard.editorIDs) > 0 {
+		return
+	}
+
+	e := &g.editors[i]
+	if !e.IsModified() {
+		g.editorsToClose = append(g.editorsToClose, e.id)
+		return
+	}
+
+	g.closeGuard = closeGuard{editorIDs: []int{e.id}}
+}
+
+// requestQuit exits, first asking what to do with the unsaved changes of all modified editors if there are any
+func (g *Gopad) requestQuit() {
+
+	ids := []int{}
+	for i := 0; i < len(g.editors); i++ {
+		if g.editors[i].IsModified() {
+			ids = append(ids, g.editors[i].id)
+		}
+	}
+
+	if len(ids) == 0 {
+		g.quit(true)
+		return
+	}
+
+	g.closeGuard = closeGuard{editorIDs: ids, quitting: true}
+}
+
+// quit exits Gopad. With keepUnsaved the unsaved changes that are left (i.e. of scratch editors) come back on the next start.
+func (g *Gopad) quit(keepUnsaved bool) {
+
+	for i := 0; i < len(g.editors); i++ {
+		g.saveHistoryJournal(&g.editors[i])
+	}
+
+	if err := g.writeSession(true, keepUnsaved); err != nil {
+		logging.ErrLog.Println("Failed to save session. Error: " + err.Error())
+	}
+
+	engine.Quit()
+}
+
+// editorIndexByID returns the index of the editor with the given id, or -1 if it was closed
+func (g *Gopad) editorIndexByID(id int) int {
+
+	for i := 0; i < len(g.editors); i++ {
+		if g.editors[i].id == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (g *Gopad) drawCloseGuard() {
+
+	cg := &g.closeGuard
+	if len(cg.editorIDs) == 0 {
+		return
+	}
+
+	//Editors closed some other way while asking are no longer asked about
+	editors := make([]*Editor, 0, len(cg.editorIDs))
+	for _, id := range cg.editorIDs {
+		if i := g.editorIndexByID(id); i != -1 {
+			editors = append(editors, &g.editors[i])
+		}
+	}
+
+	if len(editors) == 0 {
+
+		quitting := cg.quitting
+		*cg = closeGuard{}
+		if quitting {
+			g.quit(true)
+		}
+		return
+	}
+
+	imgui.OpenPopup("Unsaved Changes")
+	imgui.SetNextWindowPosV(imgui.Vec2{X: g.winWidth * 0.5, Y: g.winHeight * 0.5}, imgui.ConditionAlways, imgui.Vec2{X: 0.5, Y: 0.5})
+	if !imgui.BeginPopupModalV("Unsaved Changes", nil, imgui.WindowFlagsNoCollapse|imgui.WindowFlagsAlwaysAutoResize|imgui.WindowFlagsNoMove) {
+		return
+	}
+
+	if cg.quitting {
+		g.drawQuitGuard(editors)
+	} else {
+		g.drawCloseEditorGuard(editors[0])
+	}
+
+	imgui.EndPopup()
+}
+
+// drawCloseEditorGuard asks what to do with the unsaved changes of one editor being closed
+func (g *Gopad) drawCloseEditorGuard(e *Editor) {
+
+	isScratch := e.FileName == "**scratch**"
+	if isScratch {
+		imgui.Text("The scratch buffer has text that isn't saved anywhere. Closing it throws the text away.")
+	} else {
+		imgui.Text("Save the changes to '" + e.FileName + "' before closing it?")
+	}
+
+	imgui.Separator()
+
+	shouldClose := false
+	if !isScratch && imgui.Button("Save") {
+		shouldClose = g.saveEditor(e)
+	}
+
+	//The scratch buffer is closed once it is saved to the picked file. Other editors being closed are left open, since the file dialog takes over.
+	if isScratch && imgui.Button("Save As...") {
+
+		id := e.id
+		g.closeGuard = closeGuard{}
+		imgui.CloseCurrentPopup()
+		g.saveAs(e, func() { g.editorsToClose = append(g.editorsToClose, id) })
+	}
+
+	imgui.SameLine()
+
+	label := "Don't Save"
+	if isScratch {
+		label = "Discard"
+	}
+
+	if imgui.Button(label) {
+		shouldClose = true
+	}
+
+	imgui.SameLine()
+	if imgui.Button("Cancel") {
+		g.closeGuard = closeGuard{}
+		imgui.CloseCurrentPopup()
+	}
+
+	//Closing several editors (i.e. Close All) asks about each in turn
+	if shouldClose {
+		g.editorsToClose = append(g.editorsToClose, e.id)
+		g.closeGuard.editorIDs = removeID(g.closeGuard.editorIDs, e.id)
+		imgui.CloseCurrentPopup()
+	}
+}
+
+func removeID(ids []int, id int) []int {
+
+	for i, other := range ids {
+		if other == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+
+	return ids
+}
+
+// drawQuitGuard lists every modified editor and asks whether to save them all before exiting.
+// Scratch editors have no file, so they are kept for the next start instead of saved.
+func (g *Gopad) drawQuitGuard(editors []*Editor) {
+
+	imgui.Text("These have unsaved changes:")
+	hasScratch := false
+	for _, e := range editors {
+
+		if e.FileName == "**scratch**" {
+			hasScratch = true
+			imgui.BulletText(e.FileName + " (kept for the next start when saving all)")
+			continue
+		}
+
+		imgui.BulletText(e.FilePath)
+	}
+
+	imgui.Separator()
+
+	if imgui.Button("Save All") {
+
+		saved := true
+		for _, e := range editors {
+			if e.FileName != "**scratch**" && !g.saveEditor(e) {
+				saved = false
+			}
+		}
+
+		//A file that failed to save keeps Gopad open so that nothing is lost
+		if saved {
+			g.closeGuard = closeGuard{}
+			imgui.CloseCurrentPopup()
+			g.quit(hasScratch)
+		}
+	}
+
+	imgui.SameLine()
+	if imgui.Button("Don't Save") {
+		g.closeGuard = closeGuard{}
+		imgui.CloseCurrentPopup()
+		g.quit(false)
+	}
+
+	imgui.SameLine()
+	if imgui.Button("Cancel") {
+		g.closeGuard = closeGuard{}
+		imgui.CloseCurrentPopup()
+	}
+}
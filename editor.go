@@ -4,51 +4,98 @@ import (
 	"math"
 	"os"
 	"path/filepath"
-
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bloeys/gopad/buffer"
+	"github.com/bloeys/gopad/charset"
+	"github.com/bloeys/gopad/editorconfig"
 	"github.com/bloeys/gopad/settings"
+	"github.com/bloeys/gopad/syntax"
+	"github.com/bloeys/nmage/input"
 	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/veandco/go-sdl2/sdl"
 )
 
 const (
-	linesPerNode = 100
-	textPadding  = 10
+	textPadding     = 10
+	tripleClickTime = 500 * time.Millisecond
 )
 
-type Line struct {
-	// @TODO: This will explode on long lines.
-	// We can use the same strategy of line nodes here, where a line is something like
-	// type Line struct {
-	// 	Line [RunesPerLine]rune
-	//  Next *Line
-	// }
-	chars []rune
-}
-
-type LinesNode struct {
-	Lines [linesPerNode]Line
-	Next  *LinesNode
-}
+// lastEditorID is the id of the newest editor
+var lastEditorID int
 
+// Editor displays a document and turns user input into edits on it
 type Editor struct {
-	FileName     string
-	FilePath     string
-	FileContents string
+	*buffer.Document
+
+	//id identifies the editor while it is open, since editors move around as others are closed
+	id int
+
+	FileName string
+	FilePath string
 
 	MouseX int
 	MouseY int
 
-	SelectionStart  int
-	SelectionLength int
+	//lastCursor is the cursor as of the last draw, used to know when to scroll to the cursor
+	lastCursor buffer.Cursor
+
+	//isDragging is true while the left mouse button is held after clicking in the text
+	isDragging bool
+	//isColumnDragging is true while doing a column selection with alt+drag, which started at the column anchors
+	isColumnDragging  bool
+	columnAnchorLine  int
+	columnAnchorGridX int
+	//lastDoubleClickTime is used to detect triple clicks
+	lastDoubleClickTime time.Time
 
-	IsModified bool
+	//diskHash is the hash of the file contents as they were last loaded or saved
+	diskHash string
 
-	LinesHead *LinesNode
-	LineCount int
+	//Config are the .editorconfig properties of the file, and configErr is set if reading them had problems
+	Config    editorconfig.Config
+	configErr error
+
+	//Encoding is what the file is saved in, and decodedAs is the encoding the buffer was read from or last saved in.
+	//lossyDecode is set if the file had byte sequences that aren't valid in decodedAs, which were replaced with U+FFFD.
+	Encoding    charset.Encoding
+	decodedAs   charset.Encoding
+	lossyDecode bool
+
+	//snapshotName is the file the unsaved text of the editor is kept in for crash recovery, and snapshotVersion
+	//is the buffer version it was written at
+	snapshotName    string
+	snapshotVersion int
 
 	LineHeight float32
 	CharWidth  float32
 
+	//StartPos is the first visible line, and ScrollX is the first visible grid column
 	StartPos float32
+	ScrollX  int
+
+	//How many lines/cols fit in the text area as of the last draw
+	visibleLines int
+	visibleCols  int
+
+	//Finder is used to highlight the search matches in the visible lines. It is nil when not searching.
+	Finder *buffer.Finder
+
+	//Highlighter is nil if the language of the file isn't known, in which case all text has the same colour
+	Highlighter *syntax.Highlighter
+	//Tree is nil if the language has no parser, in which case there is no bracket matching, folding or structural selection
+	Tree *syntax.Tree
+
+	folds *foldSet
+	//rows are the line numbers shown on each row of the text area as of the last draw, which skip folded lines
+	rows []int
+
+	//expandHistory are the selections before each ExpandSelection, and expandedTo is the selection the last one made
+	expandHistory [][2]int
+	expandedTo    [2]int
 }
 
 type MousePosInfo struct {
@@ -64,17 +111,43 @@ type MousePosInfo struct {
 	GridYEditor int
 
 	//Line is the currently selected line
-	Line    *Line
+	Line    *buffer.Line
 	LineNum int
 }
 
+// keyPress is a key that was pressed (or repeated because it is held) during the current frame
+type keyPress struct {
+	key sdl.Keycode
+	mod uint16
+}
+
+func (k keyPress) ctrl() bool {
+	return k.mod&sdl.KMOD_CTRL != 0
+}
+
+func (k keyPress) shift() bool {
+	return k.mod&sdl.KMOD_SHIFT != 0
+}
+
+func (k keyPress) alt() bool {
+	return k.mod&sdl.KMOD_ALT != 0
+}
+
 func (e *Editor) SetCursorPos(x, y int) {
 	e.MouseX = x
 	e.MouseY = y
 }
 
 func (e *Editor) SetStartPos(mouseDeltaNorm int32) {
-	e.StartPos = clampF32(e.StartPos+float32(-mouseDeltaNorm)*settings.ScrollSpeed, 0, float32(e.LineCount))
+
+	//Scrolling is by rows, so folded lines are skipped over
+	frac := e.StartPos - float32(int(e.StartPos))
+	rows := frac + float32(-mouseDeltaNorm)*settings.ScrollSpeed
+	startLine := e.moveRows(clampInt(int(e.StartPos), 0, e.Buf.LineCount()-1), int(math.Floor(float64(rows))))
+	e.StartPos = float32(startLine) + rows - float32(math.Floor(float64(rows)))
+	if startLine == e.Buf.LineCount()-1 {
+		e.StartPos = float32(startLine)
+	}
 }
 
 func (e *Editor) RefreshFontSettings() {
@@ -98,372 +171,478 @@ func (e *Editor) RoundToGridY(x float32) float32 {
 	return clampF32(float32(math.Round(float64(x/e.LineHeight)))*e.LineHeight, 0, math.MaxFloat32)
 }
 
-func (e *Editor) UpdateAndDraw(drawStartPos, winSize *imgui.Vec2, newRunes []rune) {
+func (e *Editor) UpdateAndDraw(drawStartPos, winSize *imgui.Vec2, newRunes []rune, keys []keyPress) {
 
 	//Draw window
+	imgui.PushStyleColor(imgui.StyleColorWindowBg, settings.EditorBgColor)
 	imgui.SetNextWindowPos(*drawStartPos)
 	imgui.SetNextWindowSize(*winSize)
-	imgui.BeginV("editorText", nil, imgui.WindowFlagsNoCollapse|imgui.WindowFlagsNoDecoration|imgui.WindowFlagsNoMove)
-
-	imgui.PushStyleColor(imgui.StyleColorFrameBg, settings.EditorBgColor)
-	imgui.PushStyleColor(imgui.StyleColorTextSelectedBg, settings.TextSelectionColor)
-
-	imgui.SetNextItemWidth(winSize.X)
-	if imgui.InputTextMultilineV("", &e.FileContents, imgui.Vec2{X: winSize.X - winSize.X*0.02, Y: winSize.Y - winSize.Y*0.02}, imgui.InputTextFlagsNone, nil) {
-		e.IsModified = true
-	}
-
-	// @NOTE: Commented out until rewrite that doesn't use imgui as an editor is complete
-	// //Draw window
-	// imgui.SetNextWindowPos(*drawStartPos)
-	// imgui.SetNextWindowSize(*winSize)
-	// imgui.BeginV("editorText", nil, imgui.WindowFlagsNoCollapse|imgui.WindowFlagsNoDecoration|imgui.WindowFlagsNoMove)
-
-	// imgui.PushStyleColor(imgui.StyleColorFrameBg, settings.EditorBgColor)
-	// imgui.PushStyleColor(imgui.StyleColorTextSelectedBg, settings.TextSelectionColor)
-
-	// //Add padding to text
-	// drawStartPos.X += textPadding
-	// drawStartPos.Y += textPadding
-	// paddedDrawStartPos := *drawStartPos
-
-	// //Make edits
-	// posInfo := e.getPositions(&paddedDrawStartPos)
-	// e.Insert(&posInfo, newRunes)
-
-	// if input.KeyClicked(sdl.K_LEFT) {
-	// 	e.MoveMouseXByChars(-1, &posInfo)
-	// } else if input.KeyClicked(sdl.K_RIGHT) {
-	// 	e.MoveMouseXByChars(1, &posInfo)
-	// }
-
-	// if input.KeyClicked(sdl.K_UP) {
-	// 	e.MoveMouseYByLines(-1, &posInfo)
-	// } else if input.KeyClicked(sdl.K_DOWN) {
-	// 	e.MoveMouseYByLines(1, &posInfo)
-	// }
-
-	// if input.KeyClicked(sdl.K_BACKSPACE) {
-	// 	e.Delete(&posInfo, 1)
-	// }
-
-	// //Draw text
-	// dl := imgui.WindowDrawList()
-	// linesToDraw := int(winSize.Y / e.LineHeight)
-	// startLine := clampInt(int(e.StartPos), 0, e.LineCount)
-	// for i := startLine; i < startLine+linesToDraw; i++ {
-	// 	dl.AddText(*drawStartPos, imgui.PackedColorFromVec4(imgui.Vec4{X: 1, Y: 1, Z: 1, W: 1}), string(e.GetLine(0+i).chars))
-	// 	drawStartPos.Y += e.LineHeight
-	// }
-
-	// tabCount, charsToOffsetBy := getTabs(posInfo.Line, posInfo.GridXEditor)
-	// textWidth := float32(len(posInfo.Line.chars)-tabCount+tabCount*settings.TabSize) * e.CharWidth
-	// lineX := clampF32(float32(posInfo.GridXGlobal)+float32(charsToOffsetBy)*e.CharWidth, 0, paddedDrawStartPos.X+textWidth)
-
-	// lineStart := imgui.Vec2{
-	// 	X: lineX,
-	// 	Y: paddedDrawStartPos.Y + float32(posInfo.GridYEditor)*e.LineHeight - e.LineHeight*0.25,
-	// }
-	// lineEnd := imgui.Vec2{
-	// 	X: lineX,
-	// 	Y: paddedDrawStartPos.Y + float32(posInfo.GridYEditor)*e.LineHeight + e.LineHeight*0.75,
-	// }
-	// dl.AddLineV(lineStart, lineEnd, imgui.PackedColorFromVec4(settings.CursorColor), settings.CursorWidthFactor*e.CharWidth)
-
-	// // charAtCursor := getCharFromCursor(clickedLine, clickedColGridXEditor)
-	// // println("Chars:", "'"+charAtCursor+"'", ";", clickedColGridXEditor)
-}
-
-func (e *Editor) Insert(posInfo *MousePosInfo, rs []rune) {
-
-	if len(rs) == 0 {
-		return
+	imgui.BeginV("editorText", nil, imgui.WindowFlagsNoCollapse|imgui.WindowFlagsNoDecoration|imgui.WindowFlagsNoMove|imgui.WindowFlagsNoScrollWithMouse)
+
+	//Add padding to text, and leave space for line numbers
+	gutterWidth := e.gutterWidth()
+	paddedDrawStartPos := imgui.Vec2{X: drawStartPos.X + gutterWidth + textPadding, Y: drawStartPos.Y + textPadding}
+	e.visibleLines = clampInt(int((winSize.Y-2*textPadding)/e.LineHeight), 1, math.MaxInt)
+	e.visibleCols = clampInt(int((winSize.X-gutterWidth-2*textPadding)/e.CharWidth), 1, math.MaxInt)
+
+	//Make edits
+	if input.MouseClicked(sdl.BUTTON_LEFT) && imgui.IsWindowHovered() {
+		e.handleMouseClick(&paddedDrawStartPos)
+	} else if e.isDragging || e.isColumnDragging {
+
+		if input.MouseDown(sdl.BUTTON_LEFT) {
+			x, y := input.GetMousePos()
+			e.SetCursorPos(int(x), int(y))
+
+			posInfo := e.getPositions(&paddedDrawStartPos)
+			if e.isColumnDragging {
+				e.SelectColumns(e.columnAnchorLine, e.columnAnchorGridX, posInfo.LineNum, posInfo.GridXEditor)
+			} else {
+				e.ExtendSelection(posInfo.LineNum, posInfo.Line.ColFromGridX(posInfo.GridXEditor))
+			}
+		} else {
+			e.isDragging = false
+			e.isColumnDragging = false
+		}
 	}
-	e.IsModified = true
 
-	l := posInfo.Line
-	if len(l.chars) == 0 {
-		l.chars = append(l.chars, rs...)
-		return
-	}
+	//Other widgets (e.g. popups) get the keyboard while they are being used
+	if !imgui.IsAnyItemActive() {
 
-	charIndex := getCharIndexFromCursor(posInfo.Line, posInfo.GridXEditor)
-	if charIndex == -1 {
-		charIndex = 0
-	} else if charIndex == len(l.chars)-1 {
-		l.chars = append(l.chars, rs...)
-		return
-	} else {
-		charIndex++
+		e.Insert(string(newRunes))
+		for i := 0; i < len(keys); i++ {
+			e.handleKeyPress(keys[i])
+		}
 	}
 
-	//Make a new array that can accomodate the changes
-	c := l.chars
-	newLength := len(c) + len(rs)
-	l.chars = make([]rune, newLength)
+	if e.Cursor != e.lastCursor {
+		e.lastCursor = e.Cursor
+		e.unfoldAtCursors()
+		e.keepCursorVisible()
+	}
 
-	//Copy the left half (before the changes), then copy the changes in the new space, and
-	//lastly copy the pushed elements to the right of the changes
-	copy(l.chars, c[:charIndex])
-	copy(l.chars[charIndex:], rs)
-	copy(l.chars[charIndex+len(rs):], c[charIndex:])
+	//Draw text
+	dl := imgui.WindowDrawList()
+	lineNumColor := imgui.PackedColorFromVec4(settings.LineNumberColor)
+	dl.AddRectFilled(*drawStartPos, imgui.Vec2{X: drawStartPos.X + gutterWidth, Y: drawStartPos.Y + winSize.Y}, imgui.PackedColorFromVec4(settings.GutterBgColor))
+	tokenColors := syntaxColors()
 
-	e.MoveMouseXByChars(len(rs), posInfo)
-}
+	e.rows = e.visibleRows(clampInt(int(e.StartPos), 0, e.Buf.LineCount()-1), e.visibleLines+1, e.rows)
+	startLine := e.rows[0]
+	endLine := e.rows[len(e.rows)-1] + 1
+	if e.Finder != nil {
 
-func (e *Editor) Delete(posInfo *MousePosInfo, count int) {
+		matchColor := imgui.PackedColorFromVec4(settings.FindMatchColor)
+		for _, m := range e.FindInLines(e.Finder, startLine, endLine) {
+			e.drawHighlight(dl, &paddedDrawStartPos, m.Start, m.End, matchColor)
+		}
+	}
 
-	l := posInfo.Line
-	if len(l.chars) == 0 {
-		return
+	selColor := imgui.PackedColorFromVec4(settings.TextSelectionColor)
+	cursors := e.Cursors()
+	for i := 0; i < len(cursors); i++ {
+		selStart, selEnd := e.SelectionOf(cursors[i])
+		e.drawHighlight(dl, &paddedDrawStartPos, selStart, selEnd, selColor)
 	}
-	e.IsModified = true
 
-	if count >= len(l.chars) {
-		l.chars = []rune{}
-		return
+	//Only the brackets next to the primary cursor are matched
+	if e.Tree != nil && !e.HasSelection() {
+		if open, close, ok := e.Tree.MatchingBracket(e.CursorOffset()); ok {
+			bracketColor := imgui.PackedColorFromVec4(settings.BracketMatchColor)
+			e.drawHighlight(dl, &paddedDrawStartPos, open, open+1, bracketColor)
+			e.drawHighlight(dl, &paddedDrawStartPos, close, close+1, bracketColor)
+		}
 	}
 
-	charIndex := getCharIndexFromCursor(l, posInfo.GridXEditor)
-	if charIndex == -1 {
-		return
+	//Ruler at the max line length
+	if e.Config.MaxLineLength > 0 && e.Config.MaxLineLength >= e.ScrollX {
+		rulerX := paddedDrawStartPos.X + float32(e.Config.MaxLineLength-e.ScrollX)*e.CharWidth
+		dl.AddLine(imgui.Vec2{X: rulerX, Y: drawStartPos.Y}, imgui.Vec2{X: rulerX, Y: drawStartPos.Y + winSize.Y}, lineNumColor)
 	}
 
-	//Count tabs that will be deleted
-	tabCount := 0
-	for i := charIndex - count + 1; i < charIndex+1; i++ {
-		if l.chars[i] == '\t' {
-			tabCount++
+	linePos := paddedDrawStartPos
+	for _, i := range e.rows {
+
+		lineNum := strconv.Itoa(i + 1)
+		dl.AddText(imgui.Vec2{X: paddedDrawStartPos.X - textPadding - float32(len(lineNum))*e.CharWidth, Y: linePos.Y}, lineNumColor, lineNum)
+		e.drawFoldMarker(dl, &paddedDrawStartPos, &linePos, i, lineNumColor)
+
+		var tokens []syntax.Token
+		if e.Highlighter != nil {
+			tokens = e.Highlighter.LineTokens(i)
 		}
+
+		e.drawLineText(dl, &linePos, e.Line(i), tokens, &tokenColors)
+		linePos.Y += e.LineHeight
 	}
 
-	l.chars = append(l.chars[:charIndex-count+1], l.chars[charIndex+1:]...)
+	//Draw cursors
+	cursorColor := imgui.PackedColorFromVec4(settings.CursorColor)
+	for _, c := range cursors {
 
-	if tabCount == 0 {
-		e.MoveMouseXByChars(-1, posInfo)
-	} else {
-		e.MoveMouseXByChars(-tabCount*settings.TabSize, posInfo)
+		row := e.rowOf(c.Line)
+		if row == -1 {
+			continue
+		}
+
+		cursorX := paddedDrawStartPos.X + float32(e.Line(c.Line).GridWidth(c.Col)-e.ScrollX)*e.CharWidth
+		cursorY := paddedDrawStartPos.Y + float32(row)*e.LineHeight
+		if cursorX >= paddedDrawStartPos.X {
+			dl.AddLineV(
+				imgui.Vec2{X: cursorX, Y: cursorY},
+				imgui.Vec2{X: cursorX, Y: cursorY + e.LineHeight},
+				cursorColor,
+				settings.CursorWidthFactor*e.CharWidth,
+			)
+		}
 	}
+
+	imgui.End()
+	imgui.PopStyleColor()
 }
 
-func (e *Editor) MoveMouseXByChars(charCount int, posInfo *MousePosInfo) {
+func (e *Editor) handleMouseClick(paddedDrawStartPos *imgui.Vec2) {
 
-	if posInfo.GridXEditor == 0 && charCount < 0 {
+	posInfo := e.getPositions(paddedDrawStartPos)
+	col := posInfo.Line.ColFromGridX(posInfo.GridXEditor)
+
+	//Clicking the gutter folds and unfolds
+	if e.Tree != nil && float32(e.MouseX) < paddedDrawStartPos.X-textPadding {
+		e.ToggleFold(posInfo.LineNum)
 		return
 	}
 
-	delta := float32(charCount) * e.CharWidth
-	e.MouseX = int(e.RoundToGridX(float32(e.MouseX) + delta))
-	posInfo.GridXGlobal = int(e.RoundToGridX(float32(posInfo.GridXGlobal) + delta))
-	posInfo.GridXEditor = int(e.RoundToGridX(float32(posInfo.GridXEditor) + delta))
-}
+	//A click soon after a double click on the same line is a triple click
+	if time.Since(e.lastDoubleClickTime) < tripleClickTime && posInfo.LineNum == e.Cursor.Line {
+		e.SelectLine(posInfo.LineNum)
+		e.lastDoubleClickTime = time.Time{}
+		return
+	}
 
-func (e *Editor) MoveMouseYByLines(lineCount int, posInfo *MousePosInfo) {
+	if imgui.IsMouseDoubleClicked(0) {
+		e.SelectWord(posInfo.LineNum, col)
+		e.lastDoubleClickTime = time.Now()
+		return
+	}
 
-	if lineCount < 0 && posInfo.LineNum == 0 {
+	//Alt+drag selects a column (i.e. a rectangle) of text, with one cursor per line
+	if input.KeyDown(sdl.K_LALT) || input.KeyDown(sdl.K_RALT) {
+		e.columnAnchorLine = posInfo.LineNum
+		e.columnAnchorGridX = posInfo.GridXEditor
+		e.SelectColumns(posInfo.LineNum, posInfo.GridXEditor, posInfo.LineNum, posInfo.GridXEditor)
+		e.isColumnDragging = true
 		return
 	}
 
-	if lineCount > 0 && posInfo.LineNum == e.LineCount {
+	if input.KeyDown(sdl.K_LCTRL) || input.KeyDown(sdl.K_RCTRL) {
+		e.AddCursor(posInfo.LineNum, col)
 		return
 	}
 
-	delta := float32(lineCount) * e.LineHeight
-	e.MouseY = int(e.RoundToGridY(float32(e.MouseY) + delta))
-	posInfo.GridYGlobal = int(e.RoundToGridY(float32(posInfo.GridYGlobal) + delta))
-	posInfo.GridYEditor = int(e.RoundToGridY(float32(posInfo.GridYEditor) + delta))
+	if input.KeyDown(sdl.K_LSHIFT) || input.KeyDown(sdl.K_RSHIFT) {
+		e.ExtendSelection(posInfo.LineNum, col)
+	} else {
+		e.SetCursor(posInfo.LineNum, col)
+	}
+
+	e.History.Break()
+	e.isDragging = true
 }
 
-func (e *Editor) getPositions(paddedDrawStartPos *imgui.Vec2) MousePosInfo {
+func (e *Editor) handleKeyPress(k keyPress) {
 
-	//Calculate position of cursor in window and grid coords.
-	//Window coords are as reported by SDL, but we correct for padding and snap to the nearest
-	//char window pos.
-	//
-	//Since Gopad only supports fixed-width fonts, we treat the text area as a grid with each
-	//cell having identical width and one char inside.
-	//
-	//'Global' suffix means the position is in window coords.
-	//'Editor' suffix means coords are within the text editor coords, where sidebar and tabs have been adjusted for
+	switch k.key {
 
-	roundedMouseX := e.RoundToGridX(float32(e.MouseX))
-	gridXGlobal := clampInt(int(roundedMouseX), 0, math.MaxInt)
+	case sdl.K_LEFT:
+		if k.alt() && k.shift() {
+			e.ShrinkSelection()
+		} else {
+			e.MoveCursorX(-1, k.shift())
+		}
+	case sdl.K_RIGHT:
+		if k.alt() && k.shift() {
+			e.ExpandSelection()
+		} else {
+			e.MoveCursorX(1, k.shift())
+		}
 
-	roundedMouseY := e.RoundToGridY(float32(e.MouseY))
-	gridYGlobal := clampInt(int(roundedMouseY), 0, math.MaxInt)
+	//Moving up and down is by rows so folded lines are skipped
+	case sdl.K_UP:
+		e.MoveCursorY(e.moveRows(e.Cursor.Line, -1)-e.Cursor.Line, k.shift())
+	case sdl.K_DOWN:
+		e.MoveCursorY(e.moveRows(e.Cursor.Line, 1)-e.Cursor.Line, k.shift())
+	case sdl.K_PAGEUP:
+		e.MoveCursorY(e.moveRows(e.Cursor.Line, -e.visibleLines)-e.Cursor.Line, k.shift())
+	case sdl.K_PAGEDOWN:
+		e.MoveCursorY(e.moveRows(e.Cursor.Line, e.visibleLines)-e.Cursor.Line, k.shift())
+
+	case sdl.K_HOME:
+		if k.ctrl() {
+			e.MoveCursorToStart(k.shift())
+		} else {
+			e.MoveCursorToLineStart(k.shift())
+		}
+
+	case sdl.K_END:
+		if k.ctrl() {
+			e.MoveCursorToEnd(k.shift())
+		} else {
+			e.MoveCursorToLineEnd(k.shift())
+		}
 
-	gridXEditor := int(
-		roundF32(
-			(float32(gridXGlobal) - paddedDrawStartPos.X) / e.CharWidth,
-		),
-	)
+	case sdl.K_a:
+		if k.ctrl() {
+			e.SelectAll()
+		}
 
-	windowYEditor := clampInt(e.MouseY-int(paddedDrawStartPos.Y), 0, math.MaxInt)
-	gridYEditor := clampInt(windowYEditor/int(e.LineHeight), 0, e.LineCount)
+	case sdl.K_d:
+		if k.ctrl() {
+			e.SelectNextOccurrence()
+		}
 
-	startLineIndex := clampInt(int(e.StartPos), 0, e.LineCount)
-	return MousePosInfo{
+	case sdl.K_BACKSLASH:
+		if k.ctrl() && k.shift() {
+			e.GoToMatchingBracket()
+		}
 
-		GridXGlobal: gridXGlobal,
-		GridYGlobal: gridYGlobal,
+	case sdl.K_LEFTBRACKET:
+		if k.ctrl() && k.shift() {
+			e.FoldEnclosing()
+		}
 
-		GridXEditor: gridXEditor,
-		GridYEditor: gridYEditor,
+	case sdl.K_RIGHTBRACKET:
+		if k.ctrl() && k.shift() {
+			e.Unfold(e.Cursor.Line)
+		}
 
-		Line:    e.GetLine(startLineIndex + gridYEditor),
-		LineNum: startLineIndex + gridYEditor,
-	}
-}
+	case sdl.K_ESCAPE:
+		e.ClearExtraCursors()
 
-func getCharFromCursor(l *Line, cursorGridX int) string {
-	i := getCharIndexFromCursor(l, cursorGridX)
-	if i == -1 {
-		return ""
+	case sdl.K_BACKSPACE:
+		e.DeleteBackward(1)
+	case sdl.K_DELETE:
+		e.DeleteForward(1)
+	case sdl.K_RETURN, sdl.K_KP_ENTER:
+		e.Insert("\n")
+	case sdl.K_TAB:
+		e.Insert(e.indentText())
 	}
-	return string(l.chars[i])
 }
 
-func getCharIndexFromCursor(l *Line, cursorGridX int) int {
+// drawHighlight draws a background behind the bytes [selStart, selEnd) that are in the visible rows
+func (e *Editor) drawHighlight(dl imgui.DrawList, paddedDrawStartPos *imgui.Vec2, selStart, selEnd int, color imgui.PackedColor) {
 
-	if cursorGridX <= 0 || len(l.chars) == 0 {
-		return -1
+	if selStart == selEnd {
+		return
 	}
 
-	gridSize := 0
-	for i := 0; i < len(l.chars) && i <= cursorGridX; i++ {
+	for row, i := range e.rows {
 
-		if l.chars[i] == '\t' {
-			gridSize += settings.TabSize
-		} else {
-			gridSize++
+		l := e.Line(i)
+		if selEnd < l.Start() || selStart > l.End() {
+			continue
 		}
 
-		if gridSize < cursorGridX {
+		startGridX := l.GridWidth(l.CharIndex(selStart)) - e.ScrollX
+		endGridX := l.GridWidth(l.CharIndex(selEnd)) - e.ScrollX
+
+		//Show the newline as selected by selecting one extra cell
+		if selEnd > l.End() {
+			endGridX++
+		}
+
+		if endGridX <= startGridX {
 			continue
 		}
 
-		return i
+		y := paddedDrawStartPos.Y + float32(row)*e.LineHeight
+		dl.AddRectFilled(
+			imgui.Vec2{X: paddedDrawStartPos.X + float32(clampInt(startGridX, 0, math.MaxInt))*e.CharWidth, Y: y},
+			imgui.Vec2{X: paddedDrawStartPos.X + float32(endGridX)*e.CharWidth, Y: y + e.LineHeight},
+			color,
+		)
 	}
+}
 
-	if cursorGridX >= gridSize {
-		return len(l.chars) - 1
+// drawLineText draws the part of the line that fits in the visible grid columns (with tabs expanded to spaces),
+// with each token in the colour of its kind
+func (e *Editor) drawLineText(dl imgui.DrawList, linePos *imgui.Vec2, l *buffer.Line, tokens []syntax.Token, colors *[syntax.TokenKindCount]imgui.PackedColor) {
+
+	first := l.CharIndexFromGridX(e.ScrollX + 1)
+	if first == -1 || l.GridWidth(first+1) <= e.ScrollX {
+		return
 	}
 
-	return -1
-}
+	chars := l.Chars(first, e.visibleCols+1)
+
+	//Text is drawn in runs of chars that have the same colour
+	sb := strings.Builder{}
+	sb.Grow(len(chars))
+	runGridX := l.GridWidth(first) - e.ScrollX
+	gridX := runGridX
+	runKind := syntax.TokenText
+	flush := func() {
 
-// TODO: The offset chars must be how many grid cols between cursor col and the nearest non-tab char.
-func getTabs(l *Line, gridPosX int) (tabCount, charsToOffsetBy int) {
+		if sb.Len() > 0 {
+			dl.AddText(imgui.Vec2{X: linePos.X + float32(runGridX)*e.CharWidth, Y: linePos.Y}, colors[runKind], sb.String())
+			sb.Reset()
+		}
 
-	charIndex := getCharIndexFromCursor(l, gridPosX)
-	if charIndex == -1 {
-		return 0, 0
+		runGridX = gridX
 	}
 
-	//gridSize represents the visual grid (e.g. \tHi has a visual grid size of 'tabSize+2')
-	gridSize := 0
-	for i := charIndex; i >= 0; i-- {
-		if l.chars[i] == '\t' {
-			tabCount++
-			gridSize += settings.TabSize
-		} else {
-			gridSize++
+	byteOff := l.ByteOffset(first) - l.Start()
+	tokenIndex := 0
+	for _, c := range chars {
+
+		for tokenIndex < len(tokens) && tokens[tokenIndex].End <= byteOff {
+			tokenIndex++
+		}
+
+		kind := syntax.TokenText
+		if tokenIndex < len(tokens) && tokens[tokenIndex].Start <= byteOff {
+			kind = tokens[tokenIndex].Kind
+		}
+
+		if kind != runKind {
+			flush()
+			runKind = kind
 		}
-	}
 
-	if l.chars[charIndex] != '\t' {
-		return tabCount, 0
+		byteOff += utf8.RuneLen(c)
+		if c == '\t' {
+			sb.WriteString(strings.Repeat(" ", e.TabSize))
+			gridX += e.TabSize
+			continue
+		}
+
+		sb.WriteRune(c)
+		gridX++
 	}
 
-	return tabCount, gridSize - gridPosX
+	flush()
 }
 
-func (e *Editor) GetLine(lineNum int) *Line {
+// syntaxColors returns the theme colour of every token kind
+func syntaxColors() (colors [syntax.TokenKindCount]imgui.PackedColor) {
 
-	if lineNum > e.LineCount {
-		return &Line{
-			chars: []rune{},
-		}
-	}
+	for i := 0; i < len(colors); i++ {
 
-	curr := e.LinesHead
+		c, ok := settings.SyntaxColors[syntax.TokenKind(i).String()]
+		if !ok {
+			c = settings.TextColor
+		}
 
-	//Advance to correct node
-	nodeNum := lineNum / linesPerNode
-	lineNum -= nodeNum * linesPerNode
-	for nodeNum > 0 {
-		curr = curr.Next
-		nodeNum--
+		colors[i] = imgui.PackedColorFromVec4(c)
 	}
 
-	return &curr.Lines[lineNum]
+	return colors
 }
 
-func (e *Editor) GetLineCharCount(lineNum int) int {
+// drawFoldMarker draws the marker of a foldable line in the gutter, and a marker after the text of a folded line
+func (e *Editor) drawFoldMarker(dl imgui.DrawList, paddedDrawStartPos, linePos *imgui.Vec2, lineNum int, color imgui.PackedColor) {
+
+	if e.Tree == nil {
+		return
+	}
 
-	curr := e.LinesHead
+	gutterX := paddedDrawStartPos.X - textPadding - e.gutterWidth()
+	if e.isFolded(lineNum) {
+		dl.AddText(imgui.Vec2{X: gutterX, Y: linePos.Y}, color, "+")
 
-	//Advance to correct node
-	nodeNum := lineNum / linesPerNode
-	lineNum -= nodeNum * linesPerNode
-	for nodeNum > 0 {
-		curr = curr.Next
-		nodeNum--
+		textEndX := linePos.X + float32(e.Line(lineNum).GridWidth(e.Line(lineNum).RuneCount())+1-e.ScrollX)*e.CharWidth
+		dl.AddText(imgui.Vec2{X: textEndX, Y: linePos.Y}, color, "...")
+		return
 	}
 
-	return len(curr.Lines[lineNum].chars)
+	if _, ok := e.Tree.FoldAt(lineNum); ok {
+		dl.AddText(imgui.Vec2{X: gutterX, Y: linePos.Y}, color, "-")
+	}
 }
 
-func ParseLines(fileContents string) (*LinesNode, int) {
+// rowOf returns the row a line was drawn on in the last draw, or -1 if it wasn't visible
+func (e *Editor) rowOf(lineNum int) int {
 
-	head := NewLineNode()
-	if len(fileContents) == 0 {
-		return head, 0
+	for row, l := range e.rows {
+		if l == lineNum {
+			return row
+		}
 	}
 
-	lineCount := 0
-	start := 0
-	end := 0
-	currLine := 0
-	currNode := head
+	return -1
+}
 
-	// @PERF: Would be a lot faster to use something like bytes index
-	for i := 0; i < len(fileContents); i++ {
+func (e *Editor) gutterWidth() float32 {
+	return float32(len(strconv.Itoa(e.Buf.LineCount()))+1) * e.CharWidth
+}
 
-		if fileContents[i] != '\n' {
-			end++
-			continue
-		}
+// ShowMatch selects m and scrolls so that it is in the middle of the screen if it isn't visible already
+func (e *Editor) ShowMatch(m buffer.Match) {
 
-		lineCount++
-		currNode.Lines[currLine].chars = []rune(fileContents[start:end])
+	e.Select(m.Start, m.End)
+	e.History.Break()
 
-		end++
-		start = end
-		currLine++
-		if currLine == linesPerNode {
-			currLine = 0
-			currNode.Next = NewLineNode()
-			currNode = currNode.Next
-		}
+	e.unfoldAtCursors()
+	if e.Cursor.Line < int(e.StartPos) || e.rowsBetween(int(e.StartPos), e.Cursor.Line) >= e.visibleLines {
+		e.StartPos = float32(e.moveRows(e.Cursor.Line, -e.visibleLines/2))
 	}
+}
+
+func (e *Editor) keepCursorVisible() {
 
-	if fileContents[len(fileContents)-1] != '\n' {
-		lineCount++
-		currNode.Lines[currLine].chars = []rune(fileContents[start:end])
+	if float32(e.Cursor.Line) < e.StartPos {
+		e.StartPos = float32(e.Cursor.Line)
+	} else if e.rowsBetween(int(e.StartPos), e.Cursor.Line) >= e.visibleLines {
+		e.StartPos = float32(e.moveRows(e.Cursor.Line, -e.visibleLines+1))
 	}
 
-	return head, lineCount
+	cursorGridX := e.CursorGridX()
+	if cursorGridX < e.ScrollX {
+		e.ScrollX = cursorGridX
+	} else if cursorGridX >= e.ScrollX+e.visibleCols {
+		e.ScrollX = cursorGridX - e.visibleCols + 1
+	}
 }
 
-func NewLineNode() *LinesNode {
+func (e *Editor) getPositions(paddedDrawStartPos *imgui.Vec2) MousePosInfo {
+
+	//Calculate position of cursor in window and grid coords.
+	//Window coords are as reported by SDL, but we correct for padding and snap to the nearest
+	//char window pos.
+	//
+	//Since Gopad only supports fixed-width fonts, we treat the text area as a grid with each
+	//cell having identical width and one char inside.
+	//
+	//'Global' suffix means the position is in window coords.
+	//'Editor' suffix means coords are within the text editor coords, where sidebar and tabs have been adjusted for
+
+	roundedMouseX := e.RoundToGridX(float32(e.MouseX))
+	gridXGlobal := clampInt(int(roundedMouseX), 0, math.MaxInt)
 
-	n := LinesNode{}
-	for i := 0; i < len(n.Lines); i++ {
-		n.Lines[i].chars = []rune{}
+	roundedMouseY := e.RoundToGridY(float32(e.MouseY))
+	gridYGlobal := clampInt(int(roundedMouseY), 0, math.MaxInt)
+
+	gridXEditor := clampInt(int(roundF32((float32(e.MouseX)-paddedDrawStartPos.X)/e.CharWidth)), 0, math.MaxInt) + e.ScrollX
+
+	windowYEditor := clampF32(float32(e.MouseY)-paddedDrawStartPos.Y, 0, math.MaxFloat32)
+	gridYEditor := int(windowYEditor / e.LineHeight)
+
+	lineNum := e.Buf.LineCount() - 1
+	if gridYEditor < len(e.rows) {
+		lineNum = e.rows[gridYEditor]
 	}
 
-	return &n
+	return MousePosInfo{
+
+		GridXGlobal: gridXGlobal,
+		GridYGlobal: gridYGlobal,
+
+		GridXEditor: gridXEditor,
+		GridYEditor: gridYEditor,
+
+		Line:    e.Line(lineNum),
+		LineNum: lineNum,
+	}
 }
 
 func clampF32(x, min, max float32) float32 {
@@ -496,30 +675,165 @@ func roundF32(x float32) float32 {
 	return float32(math.Round(float64(x)))
 }
 
+// indentText returns what pressing tab inserts, which is spaces up to the next indent stop if the .editorconfig asks for spaces
+func (e *Editor) indentText() string {
+
+	if e.Config.IndentStyle != "space" {
+		return "\t"
+	}
+
+	size := e.Config.IndentSize
+	if size <= 0 {
+		size = e.TabSize
+	}
+
+	return strings.Repeat(" ", size-e.CursorGridX()%size)
+}
+
+// saveOptions returns the changes the .editorconfig asks for before saving
+func (e *Editor) saveOptions() buffer.SaveOptions {
+
+	opts := buffer.SaveOptions{
+		TrimTrailingWhitespace: e.Config.TrimTrailingWhitespace,
+	}
+
+	switch e.Config.EndOfLine {
+	case "lf":
+		opts.LineEnding = buffer.LF
+	case "crlf":
+		opts.LineEnding = buffer.CRLF
+	case "cr":
+		opts.LineEnding = buffer.CR
+	}
+
+	if e.Config.InsertFinalNewline != nil {
+		opts.FinalNewline = -1
+		if *e.Config.InsertFinalNewline {
+			opts.FinalNewline = 1
+		}
+	}
+
+	return opts
+}
+
 func NewScratchEditor() *Editor {
 
+	lastEditorID++
 	e := &Editor{
-		FileName:  "**scratch**",
-		LinesHead: NewLineNode(),
+		id:       lastEditorID,
+		Document: buffer.NewDocument(nil, settings.TabSize),
+		FileName: "**scratch**",
 	}
+	e.folds = newFoldSet(e.Buf)
 
 	return e
 }
 
-func NewEditor(fPath string) *Editor {
+// NewEditorWithEncoding opens fPath decoded as enc, or as its detected encoding if enc is nil
+func NewEditorWithEncoding(fPath string, enc *charset.Encoding) (*Editor, error) {
 
 	b, err := os.ReadFile(fPath)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
+	lastEditorID++
 	e := &Editor{
-		FileName:     filepath.Base(fPath),
-		FilePath:     fPath,
-		FileContents: string(b),
+		id:       lastEditorID,
+		FileName: filepath.Base(fPath),
+		FilePath: fPath,
+		diskHash: hashBytes(b),
+	}
+
+	e.Config, e.configErr = editorconfig.Resolve(fPath)
+
+	//A byte order mark is trusted over the .editorconfig charset, but the file is still saved in the charset
+	configEnc, hasConfigEnc := charset.FromEditorConfig(e.Config.Charset)
+	_, hasBOM := charset.DetectBOM(b)
+	switch {
+	case enc != nil:
+		e.decodedAs = *enc
+	case hasConfigEnc && !hasBOM:
+		e.decodedAs = configEnc
+	default:
+		e.decodedAs = charset.Detect(b)
+	}
+
+	e.Encoding = e.decodedAs
+	if enc == nil && hasConfigEnc {
+		e.Encoding = configEnc
+	}
+
+	text, lossless := charset.Decode(b, e.decodedAs)
+	e.lossyDecode = !lossless
+	e.Document = buffer.NewDocument(text, settings.TabSize)
+	if e.Config.TabWidth > 0 {
+		e.TabSize = e.Config.TabWidth
+	}
+
+	if h := loadHistoryJournal(fPath, e.diskHash, e.decodedAs); h != nil {
+		e.SetHistory(h)
 	}
 
+	if lang := syntax.ForFile(e.FileName); lang != nil {
+
+		e.Highlighter = syntax.NewHighlighter(lang, e.Buf)
+		if lang.Parser != nil {
+			e.Tree = syntax.NewTree(lang, e.Buf)
+		}
+	}
+
+	e.folds = newFoldSet(e.Buf)
+
 	e.RefreshFontSettings()
-	e.LinesHead, e.LineCount = ParseLines(e.FileContents)
-	return e
+	return e, nil
+}
+
+// setFilePath points the editor at another file (e.g. for Save As), updating what depends on the file's name and folder.
+// An empty path makes it a scratch editor again.
+func (e *Editor) setFilePath(fPath string) {
+
+	e.FilePath = fPath
+	e.FileName = "**scratch**"
+	e.Config, e.configErr = editorconfig.Config{}, nil
+	if fPath != "" {
+		e.FileName = filepath.Base(fPath)
+		e.Config, e.configErr = editorconfig.Resolve(fPath)
+	}
+
+	e.TabSize = settings.TabSize
+	if e.Config.TabWidth > 0 {
+		e.TabSize = e.Config.TabWidth
+	}
+
+	if enc, ok := charset.FromEditorConfig(e.Config.Charset); ok {
+		e.Encoding = enc
+	}
+
+	lang := syntax.ForFile(e.FileName)
+	if fPath == "" {
+		lang = nil
+	}
+
+	if (lang == nil && e.Highlighter == nil) || (lang != nil && e.Highlighter != nil && e.Highlighter.Lang == lang) {
+		return
+	}
+
+	//The old ones would otherwise keep following the edits of the buffer
+	if e.Highlighter != nil {
+		e.Highlighter.Close()
+	}
+
+	if e.Tree != nil {
+		e.Tree.Close()
+	}
+
+	e.Highlighter, e.Tree = nil, nil
+	if lang != nil {
+
+		e.Highlighter = syntax.NewHighlighter(lang, e.Buf)
+		if lang.Parser != nil {
+			e.Tree = syntax.NewTree(lang, e.Buf)
+		}
+	}
 }
@@ -0,0 +1,400 @@
+// Package editorconfig finds the EditorConfig (https://editorconfig.org) properties that apply to a file
+package editorconfig
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FileName is the name of the files properties are read from
+const FileName = ".editorconfig"
+
+// Config holds the properties that apply to a file. Properties that aren't set have their zero value.
+type Config struct {
+	//IndentStyle is "tab" or "space"
+	IndentStyle string
+	IndentSize  int
+	TabWidth    int
+
+	//EndOfLine is "lf", "crlf" or "cr"
+	EndOfLine string
+
+	//Charset is one of "utf-8", "utf-8-bom", "latin1", "utf-16be" or "utf-16le"
+	Charset string
+
+	TrimTrailingWhitespace bool
+
+	//InsertFinalNewline is nil when not set, since false means the file must not end with a newline
+	InsertFinalNewline *bool
+
+	MaxLineLength int
+}
+
+// section is a glob and the properties set under it
+type section struct {
+	glob  *regexp.Regexp
+	props [][2]string
+
+	//ranges are the number ranges of '{n1..n2}' in the glob, in the order of the regexp groups that capture them
+	ranges [][2]int
+}
+
+type file struct {
+	dir      string
+	root     bool
+	sections []section
+}
+
+// Resolve returns the properties that apply to fPath, found by reading the .editorconfig files from the folder
+// of fPath up to the file system root or to a file with 'root = true'. Closer files override farther ones.
+func Resolve(fPath string) (Config, error) {
+
+	absPath, err := filepath.Abs(fPath)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var files []*file
+	var errs []string
+	for dir := filepath.Dir(absPath); ; {
+
+		f, err := readFile(dir)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+
+		if f != nil {
+			files = append(files, f)
+			if f.root {
+				break
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	props := map[string]string{}
+	for i := len(files) - 1; i >= 0; i-- {
+
+		f := files[i]
+		rel, err := filepath.Rel(f.dir, absPath)
+		if err != nil {
+			continue
+		}
+
+		rel = filepath.ToSlash(rel)
+		for _, s := range f.sections {
+
+			if !s.matches(rel) {
+				continue
+			}
+
+			//'unset' removes what a farther file or an earlier section set
+			for _, p := range s.props {
+				if p[1] == "unset" {
+					delete(props, p[0])
+				} else {
+					props[p[0]] = p[1]
+				}
+			}
+		}
+	}
+
+	c := configFromProps(props)
+	if len(errs) > 0 {
+		return c, errors.New(strings.Join(errs, "\n"))
+	}
+
+	return c, nil
+}
+
+func configFromProps(props map[string]string) Config {
+
+	c := Config{}
+	atoi := func(name string) int {
+		n, err := strconv.Atoi(props[name])
+		if err != nil || n < 0 {
+			return 0
+		}
+		return n
+	}
+
+	switch props["indent_style"] {
+	case "tab", "space":
+		c.IndentStyle = props["indent_style"]
+	}
+
+	c.TabWidth = atoi("tab_width")
+	if props["indent_size"] == "tab" {
+		c.IndentSize = c.TabWidth
+	} else {
+		c.IndentSize = atoi("indent_size")
+	}
+
+	//Each of indent_size and tab_width defaults to the other, and indent_style=tab implies indent_size=tab
+	if c.TabWidth == 0 {
+		c.TabWidth = c.IndentSize
+	}
+	if c.IndentSize == 0 && c.IndentStyle == "tab" {
+		c.IndentSize = c.TabWidth
+	}
+
+	switch props["end_of_line"] {
+	case "lf", "crlf", "cr":
+		c.EndOfLine = props["end_of_line"]
+	}
+
+	switch props["charset"] {
+	case "utf-8", "utf-8-bom", "latin1", "utf-16be", "utf-16le":
+		c.Charset = props["charset"]
+	}
+
+	c.TrimTrailingWhitespace = props["trim_trailing_whitespace"] == "true"
+
+	switch props["insert_final_newline"] {
+	case "true", "false":
+		insert := props["insert_final_newline"] == "true"
+		c.InsertFinalNewline = &insert
+	}
+
+	//max_line_length can also be 'off'
+	c.MaxLineLength = atoi("max_line_length")
+
+	return c
+}
+
+// readFile reads the .editorconfig in dir, returning nil if there is none. Lines that can't be understood are skipped.
+func readFile(dir string) (*file, error) {
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	f := &file{dir: dir}
+	var errs []string
+	var curr *section
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' && line[len(line)-1] == ']' {
+
+			s, err := newSection(line[1 : len(line)-1])
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s:%d: %s", filepath.Join(dir, FileName), lineNum, err.Error()))
+				curr = nil
+				continue
+			}
+
+			f.sections = append(f.sections, s)
+			curr = &f.sections[len(f.sections)-1]
+			continue
+		}
+
+		eq := strings.IndexAny(line, "=:")
+		if eq == -1 {
+			errs = append(errs, fmt.Sprintf("%s:%d: expected 'key = value'", filepath.Join(dir, FileName), lineNum))
+			continue
+		}
+
+		//Keys and values are case insensitive, so both are lowercased
+		key := strings.ToLower(strings.TrimSpace(line[:eq]))
+		val := strings.ToLower(strings.TrimSpace(line[eq+1:]))
+		if curr == nil {
+			if key == "root" {
+				f.root = val == "true"
+			}
+			continue
+		}
+
+		curr.props = append(curr.props, [2]string{key, val})
+	}
+
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) > 0 {
+		return f, errors.New(strings.Join(errs, "\n"))
+	}
+
+	return f, nil
+}
+
+func (s *section) matches(relPath string) bool {
+
+	m := s.glob.FindStringSubmatch(relPath)
+	if m == nil {
+		return false
+	}
+
+	for i, r := range s.ranges {
+
+		//A range in a '{a,b}' choice that wasn't taken captures nothing, and so doesn't limit the match
+		if m[i+1] == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil || n < r[0] || n > r[1] {
+			return false
+		}
+	}
+
+	return true
+}
+
+var numRangeRegexp = regexp.MustCompile(`^([+-]?\d+)\.\.([+-]?\d+)$`)
+
+// newSection converts an EditorConfig glob into a regexp that matches paths relative to the .editorconfig folder.
+// Globs without a '/' match files in any folder.
+func newSection(glob string) (section, error) {
+
+	s := section{}
+	re := strings.Builder{}
+	if strings.Contains(glob, "/") {
+		glob = strings.TrimPrefix(glob, "/")
+		re.WriteString("^")
+	} else {
+		re.WriteString("^(?:.*/)?")
+	}
+
+	braceDepth := 0
+	for i := 0; i < len(glob); i++ {
+
+		c := glob[i]
+		switch c {
+
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				re.WriteString(".*")
+				i++
+			} else {
+				re.WriteString("[^/]*")
+			}
+
+		case '?':
+			re.WriteString("[^/]")
+
+		case '[':
+
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				re.WriteString(`\[`)
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+
+		case '{':
+
+			end := closingBrace(glob, i)
+			if end == -1 {
+				re.WriteString(`\{`)
+				continue
+			}
+
+			inner := glob[i+1 : end]
+			if m := numRangeRegexp.FindStringSubmatch(inner); m != nil {
+
+				lo, _ := strconv.Atoi(m[1])
+				hi, _ := strconv.Atoi(m[2])
+				s.ranges = append(s.ranges, [2]int{lo, hi})
+				re.WriteString(`([+-]?\d+)`)
+				i = end
+				continue
+			}
+
+			//A single choice isn't a choice, so it matches literally
+			if !strings.Contains(inner, ",") {
+				re.WriteString(regexp.QuoteMeta("{" + inner + "}"))
+				i = end
+				continue
+			}
+
+			re.WriteString("(?:")
+			braceDepth++
+
+		case ',':
+			if braceDepth > 0 {
+				re.WriteString("|")
+			} else {
+				re.WriteString(",")
+			}
+
+		case '}':
+			if braceDepth > 0 {
+				re.WriteString(")")
+				braceDepth--
+			} else {
+				re.WriteString(`\}`)
+			}
+
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	re.WriteString("$")
+
+	var err error
+	s.glob, err = regexp.Compile(re.String())
+	if err != nil {
+		return section{}, fmt.Errorf("bad glob '%s'", glob)
+	}
+
+	return s, nil
+}
+
+// closingBrace returns the index of the '}' that closes the '{' at glob[start], or -1 if there is none
+func closingBrace(glob string, start int) int {
+
+	depth := 0
+	for i := start; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,161 @@
+package editorconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSectionMatches(t *testing.T) {
+
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*", "a.txt", true},
+		{"*", "dir/a.txt", true},
+		{"*.go", "main.go", true},
+		{"*.go", "dir/sub/main.go", true},
+		{"*.go", "main.go.txt", false},
+		{"main.go", "dir/main.go", true},
+		{"a?c", "abc", true},
+		{"a?c", "a/c", false},
+
+		//A glob with a '/' is relative to the .editorconfig folder
+		{"/*.go", "main.go", true},
+		{"/*.go", "dir/main.go", false},
+		{"dir/*.go", "dir/main.go", true},
+		{"dir/*.go", "dir/sub/main.go", false},
+		{"dir/*.go", "other/dir/main.go", false},
+
+		{"**.go", "a/b/c.go", true},
+		{"dir/**", "dir/a/b.txt", true},
+		{"dir/**/b.txt", "dir/a/c/b.txt", true},
+		{"dir/**/b.txt", "other/a/b.txt", false},
+
+		{"[abc].txt", "b.txt", true},
+		{"[abc].txt", "d.txt", false},
+		{"[!abc].txt", "d.txt", true},
+		{"[!abc].txt", "a.txt", false},
+		{"[a-c]x", "bx", true},
+		{"[a-c]x", "dx", false},
+		{"[abc", "[abc", true},
+
+		{"*.{js,ts}", "a.ts", true},
+		{"*.{js,ts}", "a.go", false},
+		{"{a,{b,c}}.txt", "c.txt", true},
+		{"{a,{b,c}}.txt", "d.txt", false},
+		{"{a,b{x,y}}.txt", "by.txt", true},
+		{"{single}.txt", "{single}.txt", true},
+		{"{single}.txt", "single.txt", false},
+		{"{a,b", "{a,b", true},
+		{"{a,}.txt", ".txt", true},
+
+		{"file{1..3}.txt", "file2.txt", true},
+		{"file{1..3}.txt", "file03.txt", true},
+		{"file{1..3}.txt", "file4.txt", false},
+		{"file{-3..3}.txt", "file-2.txt", true},
+		{"file{-3..3}.txt", "file-4.txt", false},
+		{"{1..3}-{10..20}", "2-15", true},
+		{"{1..3}-{10..20}", "2-25", false},
+		{"{foo,x{1..3}}", "foo", true},
+		{"{foo,x{1..3}}", "x2", true},
+		{"{foo,x{1..3}}", "x5", false},
+
+		{`\*.txt`, "*.txt", true},
+		{`\*.txt`, "a.txt", false},
+		{"a+b(c).txt", "a+b(c).txt", true},
+	}
+
+	for _, tt := range tests {
+
+		s, err := newSection(tt.glob)
+		if err != nil {
+			t.Errorf("%s: %v", tt.glob, err)
+			continue
+		}
+
+		if s.matches(tt.path) != tt.match {
+			t.Errorf("glob '%s' on '%s': expected match to be %v", tt.glob, tt.path, tt.match)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+
+	dir := t.TempDir()
+	writeConfig := func(rel, data string) {
+
+		fPath := filepath.Join(dir, rel, FileName)
+		if err := os.MkdirAll(filepath.Dir(fPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(fPath, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//The root file stops the search, so nothing above the temp folder is read
+	writeConfig("", `root = true
+
+[*]
+indent_style = space
+indent_size = 4
+end_of_line = lf
+insert_final_newline = true
+
+[*.go]
+indent_style = tab
+indent_size = tab
+tab_width = 8
+
+[Makefile]
+indent_style = tab
+`)
+
+	writeConfig("sub", `[*]
+indent_size = 2
+end_of_line = unset
+
+[*.md]
+trim_trailing_whitespace = true
+insert_final_newline = unset
+`)
+
+	//A closer file with root = true hides the ones above it
+	writeConfig("rooted", `root = true
+
+[*]
+charset = utf-16le
+`)
+
+	final := true
+	tests := []struct {
+		path   string
+		config Config
+	}{
+		{"a.txt", Config{IndentStyle: "space", IndentSize: 4, TabWidth: 4, EndOfLine: "lf", InsertFinalNewline: &final}},
+		{"a.go", Config{IndentStyle: "tab", IndentSize: 8, TabWidth: 8, EndOfLine: "lf", InsertFinalNewline: &final}},
+		{"Makefile", Config{IndentStyle: "tab", IndentSize: 4, TabWidth: 4, EndOfLine: "lf", InsertFinalNewline: &final}},
+		{"sub/a.txt", Config{IndentStyle: "space", IndentSize: 2, TabWidth: 2, InsertFinalNewline: &final}},
+		{"sub/a.md", Config{IndentStyle: "space", IndentSize: 2, TabWidth: 2, TrimTrailingWhitespace: true}},
+		{"sub/deeper/a.go", Config{IndentStyle: "tab", IndentSize: 2, TabWidth: 8, InsertFinalNewline: &final}},
+		{"rooted/a.go", Config{Charset: "utf-16le"}},
+	}
+
+	for _, tt := range tests {
+
+		c, err := Resolve(filepath.Join(dir, tt.path))
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(c, tt.config) {
+			t.Errorf("%s: expected %+v but got %+v", tt.path, tt.config, c)
+		}
+	}
+}
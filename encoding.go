@@ -0,0 +1,85 @@
+package main
+
+import (
+	"time"
+
+	"github.com/bloeys/gopad/charset"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// reportOpenProblems shows the problems found while opening a file in e
+func (g *Gopad) reportOpenProblems(e *Editor) {
+
+	if e.configErr != nil {
+		g.triggerError("Problems in .editorconfig:\n" + e.configErr.Error())
+	}
+
+	if e.lossyDecode {
+		g.triggerError("'" + e.FileName + "' has byte sequences that aren't valid " + e.decodedAs.String() +
+			". They are shown as � and saving will replace them, so use 'Reopen with Encoding' if this is the wrong encoding.")
+	}
+}
+
+// reopenWithEncoding replaces the editor at index i with its file read again as enc
+func (g *Gopad) reopenWithEncoding(i int, enc charset.Encoding) {
+
+	old := &g.editors[i]
+	e, err := NewEditorWithEncoding(old.FilePath, &enc)
+	if err != nil {
+		g.triggerError("Failed to reopen '" + old.FileName + "'. Error: " + err.Error())
+		return
+	}
+
+	//The view stays where it was, as far as the new text allows
+	e.StartPos = old.StartPos
+	e.SetCursor(old.Cursor.Line, old.Cursor.Col)
+
+	//It is still the same tab, so it keeps its id (which pending recoveries and the close guard refer to) and its snapshot.
+	//The snapshot has the text from before reopening, so the session is written right away to stop a crash from recovering it.
+	e.id = old.id
+	e.snapshotName = old.snapshotName
+	g.recovery.lastWrite = time.Time{}
+
+	g.editors[i] = *e
+	g.reportOpenProblems(&g.editors[i])
+}
+
+// saveWithEncoding saves e in enc, keeping its old encoding if that fails
+func (g *Gopad) saveWithEncoding(e *Editor, enc charset.Encoding) {
+
+	old := e.Encoding
+	e.Encoding = enc
+	if !g.saveEditor(e) {
+		e.Encoding = old
+	}
+}
+
+// drawEncodingMenus draws the 'Reopen with Encoding' and 'Save with Encoding' sub menus for the active editor
+func (g *Gopad) drawEncodingMenus() {
+
+	e := g.getActiveEditor()
+	isFile := e.FileName != "**scratch**"
+
+	//Reopening a modified file would throw away the changes
+	if imgui.BeginMenuV("Reopen with Encoding", isFile && !e.IsModified()) {
+
+		for _, enc := range charset.All {
+			if imgui.MenuItemV(enc.String(), "", enc == e.decodedAs, true) {
+				g.reopenWithEncoding(g.activeEditor, enc)
+			}
+		}
+
+		imgui.EndMenu()
+	}
+
+	if imgui.BeginMenuV("Save with Encoding", isFile) {
+
+		for _, enc := range charset.All {
+			if imgui.MenuItemV(enc.String(), "", enc == e.Encoding, true) {
+				g.saveWithEncoding(e, enc)
+			}
+		}
+
+		imgui.EndMenu()
+	}
+}
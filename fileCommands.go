@@ -0,0 +1,255 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// drawFileMenuItems draws the items of the File menu that create, open, save and close files
+func (g *Gopad) drawFileMenuItems() {
+
+	e := g.getActiveEditor()
+	isFile := e.FileName != "**scratch**"
+
+	if imgui.MenuItemV("New", "Ctrl+N", false, true) {
+		g.newFile()
+	}
+
+	if imgui.MenuItemV("Open...", "Ctrl+O", false, true) {
+		g.openFile()
+	}
+
+	if imgui.MenuItemV("Open Folder...", "", false, true) {
+		g.openFolder()
+	}
+
+	if imgui.BeginMenuV("Open Recent Folder", len(g.recentFolders) > 0) {
+
+		for _, dir := range g.recentFolders {
+
+			if imgui.MenuItemV(filepath.Base(dir)+"##"+dir, "", dir == g.CurrDir, true) {
+				g.setCurrDir(dir)
+			}
+
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip(dir)
+			}
+		}
+
+		imgui.EndMenu()
+	}
+
+	imgui.Separator()
+
+	if imgui.MenuItemV("Save", "Ctrl+S", false, true) {
+		g.saveEditor(e)
+	}
+
+	if imgui.MenuItemV("Save As...", "Ctrl+Shift+S", false, true) {
+		g.saveAs(e, nil)
+	}
+
+	if imgui.MenuItemV("Save All", "", false, true) {
+		g.saveAll()
+	}
+
+	if imgui.MenuItemV("Revert", "", false, isFile && e.IsModified()) {
+		g.revertID = e.id
+	}
+
+	imgui.Separator()
+
+	if imgui.MenuItemV("Close", "Ctrl+W", false, true) {
+		g.requestClose(g.activeEditor)
+	}
+
+	if imgui.MenuItemV("Close All", "", false, true) {
+		g.requestCloseAll()
+	}
+}
+
+// newFile opens an empty scratch editor, which becomes a file when it is first saved
+func (g *Gopad) newFile() {
+
+	e := NewScratchEditor()
+	e.RefreshFontSettings()
+
+	g.editors = append(g.editors, *e)
+	g.activeEditor = len(g.editors) - 1
+}
+
+func (g *Gopad) openFile() {
+	g.openFileDialog(fileDialogOpen, "Open File", g.activeEditorDir(), "", g.handleFileClick)
+}
+
+func (g *Gopad) openFolder() {
+	g.openFileDialog(fileDialogFolder, "Open Folder", g.CurrDir, "", g.setCurrDir)
+}
+
+// setCurrDir makes dir the sidebar folder and reads its project settings. Open editors are kept.
+// If dir can't be read the sidebar stays as it was.
+func (g *Gopad) setCurrDir(dir string) {
+
+	dir = filepath.Clean(dir)
+	contents, err := os.ReadDir(dir)
+	if err != nil {
+		g.triggerError("Failed to open folder '" + dir + "'. Error: " + err.Error())
+		return
+	}
+
+	g.CurrDir = dir
+	g.CurrDirContents = contents
+	g.rememberFolder(dir)
+
+	//The project settings file lives in the sidebar folder
+	g.reloadSettings()
+}
+
+// refreshCurrDir reads the sidebar folder again, e.g. after a file was saved into it
+func (g *Gopad) refreshCurrDir() {
+
+	contents, err := os.ReadDir(g.CurrDir)
+	if err != nil {
+		g.triggerError("Failed to read folder '" + g.CurrDir + "'. Error: " + err.Error())
+		return
+	}
+
+	g.CurrDirContents = contents
+}
+
+// activeEditorDir returns the folder of the active editor's file, or the sidebar folder if it has no file
+func (g *Gopad) activeEditorDir() string {
+
+	e := g.getActiveEditor()
+	if e.FilePath == "" {
+		return g.CurrDir
+	}
+
+	return filepath.Dir(e.FilePath)
+}
+
+// saveAs asks for a file to save e to, then saves it there and calls onSaved (which may be nil) if that worked
+func (g *Gopad) saveAs(e *Editor, onSaved func()) {
+
+	//The editor might move in the slice (or close) while the dialog is open, so it is found again by id
+	id := e.id
+	fileName := ""
+	if e.FilePath != "" {
+		fileName = e.FileName
+	}
+
+	g.openFileDialog(fileDialogSave, "Save As", g.activeEditorDir(), fileName, func(fPath string) {
+
+		i := g.editorIndexByID(id)
+		if i == -1 {
+			return
+		}
+
+		if g.saveEditorAs(&g.editors[i], fPath) && onSaved != nil {
+			onSaved()
+		}
+	})
+}
+
+// saveEditorAs saves e to fPath, which becomes its file. If saving fails e keeps its old file.
+func (g *Gopad) saveEditorAs(e *Editor, fPath string) bool {
+
+	if other := g.findEditor(fPath); other != nil && other.id != e.id {
+		g.triggerError("'" + fPath + "' is open in another tab. Close it before saving over it.")
+		return false
+	}
+
+	//The changes made for the new file's settings are undone with the rest if saving fails
+	oldPath, oldEncoding := e.FilePath, e.Encoding
+	e.setFilePath(fPath)
+	prepared := e.PrepareSave(e.saveOptions())
+	if !g.writeEditor(e) {
+
+		if prepared {
+			e.Undo()
+		}
+
+		e.setFilePath(oldPath)
+		e.Encoding = oldEncoding
+		return false
+	}
+
+	g.refreshCurrDir()
+	return true
+}
+
+// saveAll saves every modified editor that has a file
+func (g *Gopad) saveAll() {
+
+	for i := 0; i < len(g.editors); i++ {
+
+		e := &g.editors[i]
+		if e.FileName != "**scratch**" && e.IsModified() {
+			g.saveEditor(e)
+		}
+	}
+}
+
+// requestCloseAll closes every editor, asking what to do with the unsaved changes of the modified ones
+func (g *Gopad) requestCloseAll() {
+
+	if len(g.closeGuard.editorIDs) > 0 {
+		return
+	}
+
+	//The unmodified editors close at the end of the frame like a single close does, since the menu is still being drawn
+	ids := []int{}
+	for i := 0; i < len(g.editors); i++ {
+
+		if g.editors[i].IsModified() {
+			ids = append(ids, g.editors[i].id)
+			continue
+		}
+
+		g.editorsToClose = append(g.editorsToClose, g.editors[i].id)
+	}
+
+	if len(ids) > 0 {
+		g.closeGuard = closeGuard{editorIDs: ids}
+	}
+}
+
+// drawRevertConfirm asks before throwing away the changes of the editor being reverted
+func (g *Gopad) drawRevertConfirm() {
+
+	if g.revertID == 0 {
+		return
+	}
+
+	i := g.editorIndexByID(g.revertID)
+	if i == -1 {
+		g.revertID = 0
+		return
+	}
+
+	imgui.OpenPopup("Revert File")
+	imgui.SetNextWindowPosV(imgui.Vec2{X: g.winWidth * 0.5, Y: g.winHeight * 0.5}, imgui.ConditionAlways, imgui.Vec2{X: 0.5, Y: 0.5})
+	if !imgui.BeginPopupModalV("Revert File", nil, imgui.WindowFlagsNoCollapse|imgui.WindowFlagsAlwaysAutoResize|imgui.WindowFlagsNoMove) {
+		return
+	}
+
+	e := &g.editors[i]
+	imgui.Text("Throw away the changes to '" + e.FileName + "' and load it from disk again?")
+	imgui.Separator()
+
+	if imgui.Button("Revert") {
+		g.reopenWithEncoding(i, e.decodedAs)
+		g.revertID = 0
+		imgui.CloseCurrentPopup()
+	}
+
+	imgui.SameLine()
+	if imgui.Button("Cancel") {
+		g.revertID = 0
+		imgui.CloseCurrentPopup()
+	}
+
+	imgui.EndPopup()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bloeys/gopad/buffer"
+	"github.com/bloeys/nmage/input"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const findInputWidth = 300
+
+// findBar is the find/replace panel shown above the active editor
+type findBar struct {
+	isOpen      bool
+	showReplace bool
+	focusQuery  bool
+
+	opts        buffer.FindOptions
+	replaceText string
+
+	//finder is nil if the query is empty or invalid, in which case finderErr says why
+	finder    *buffer.Finder
+	finderErr string
+
+	//status is the result of the last action, like how many matches were replaced
+	status string
+}
+
+func (g *Gopad) openFindBar(e *Editor, withReplace bool) {
+
+	g.find.isOpen = true
+	g.find.showReplace = withReplace
+	g.find.focusQuery = true
+	g.find.status = ""
+
+	//Searching for what is selected is usually what's wanted, but only if it fits in the query box
+	if sel := e.SelectedText(); sel != "" && !strings.ContainsAny(sel, "\r\n") {
+		g.find.opts.Query = sel
+	}
+}
+
+func (g *Gopad) closeFindBar() {
+	g.find.isOpen = false
+	g.find.finder = nil
+}
+
+// drawFindBar draws the find bar at pos and returns its height
+func (g *Gopad) drawFindBar(e *Editor, pos *imgui.Vec2, width float32) (height float32) {
+
+	imgui.SetNextWindowPos(*pos)
+	imgui.SetNextWindowSize(imgui.Vec2{X: width})
+	imgui.BeginV("findBar", nil, imgui.WindowFlagsNoCollapse|imgui.WindowFlagsNoDecoration|imgui.WindowFlagsNoMove)
+
+	//Query row
+	if g.find.focusQuery {
+		imgui.SetKeyboardFocusHere()
+		g.find.focusQuery = false
+	}
+
+	imgui.SetNextItemWidth(findInputWidth)
+	if imgui.InputTextV("##findQuery", &g.find.opts.Query, imgui.InputTextFlagsEnterReturnsTrue, nil) {
+
+		//Enter ends editing the query, but the user most likely wants to keep pressing it to go through the matches
+		g.findNext(e, input.KeyDown(sdl.K_LSHIFT) || input.KeyDown(sdl.K_RSHIFT))
+		g.find.focusQuery = true
+	}
+
+	imgui.SameLine()
+	imgui.Checkbox("Aa", &g.find.opts.CaseSensitive)
+	imgui.SameLine()
+	imgui.Checkbox("Word", &g.find.opts.WholeWord)
+	imgui.SameLine()
+	imgui.Checkbox(".*", &g.find.opts.Regexp)
+
+	imgui.SameLine()
+	if imgui.Button("<") {
+		g.findNext(e, true)
+	}
+
+	imgui.SameLine()
+	if imgui.Button(">") {
+		g.findNext(e, false)
+	}
+
+	g.updateFinder()
+
+	imgui.SameLine()
+	if g.find.finderErr != "" {
+		imgui.Text(g.find.finderErr)
+	} else {
+		imgui.Text(g.find.status)
+	}
+
+	//Replace row
+	if g.find.showReplace {
+
+		imgui.SetNextItemWidth(findInputWidth)
+		imgui.InputText("##findReplace", &g.find.replaceText)
+
+		imgui.SameLine()
+		if imgui.Button("Replace") && g.find.finder != nil {
+
+			if !e.ReplaceSelection(g.find.finder, g.find.replaceText) {
+				g.findNext(e, false)
+			}
+		}
+
+		imgui.SameLine()
+		if imgui.Button("Replace All") && g.find.finder != nil {
+			count := e.ReplaceAll(g.find.finder, g.find.replaceText)
+			g.find.status = "Replaced " + strconv.Itoa(count)
+		}
+	}
+
+	height = imgui.WindowHeight()
+	imgui.End()
+
+	return height
+}
+
+// updateFinder recompiles the finder if the query or options changed
+func (g *Gopad) updateFinder() {
+
+	if g.find.finder != nil && g.find.finder.Opts == g.find.opts {
+		return
+	}
+
+	g.find.finder = nil
+	g.find.finderErr = ""
+	g.find.status = ""
+	if g.find.opts.Query == "" {
+		return
+	}
+
+	f, err := buffer.NewFinder(g.find.opts)
+	if err != nil {
+		g.find.finderErr = "Invalid regexp: " + err.Error()
+		return
+	}
+
+	g.find.finder = f
+}
+
+// findNext selects the next (or previous) match after the cursor and scrolls to it
+func (g *Gopad) findNext(e *Editor, backwards bool) {
+
+	g.updateFinder()
+	if g.find.finder == nil {
+		return
+	}
+
+	//Start from the selection start, so that going forward skips the currently selected match
+	from, _ := e.Selection()
+	if !e.HasSelection() && !backwards {
+		from--
+	}
+
+	m, ok := e.FindNext(g.find.finder, from, backwards)
+	if !ok {
+		g.find.status = "No matches"
+		return
+	}
+
+	g.find.status = ""
+	e.ShowMatch(m)
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bloeys/gopad/buffer"
+	"github.com/bloeys/gopad/search"
+	"github.com/bloeys/gopad/settings"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+const (
+	// maxResultsPerFrame limits how many files worth of results are taken from a running search each frame, so the UI stays responsive
+	maxResultsPerFrame = 64
+	// maxResultLineLen is how much of a matching line is shown in the results
+	maxResultLineLen = 200
+)
+
+// findInFilesPanel searches (and optionally replaces) in all the files under the sidebar folder
+type findInFilesPanel struct {
+	isOpen      bool
+	focusQuery  bool
+	showReplace bool
+
+	opts        buffer.FindOptions
+	excludeText string
+	replaceText string
+
+	search     *search.Search
+	finder     *buffer.Finder
+	root       string
+	results    []fileResult
+	matchCount int
+	err        string
+	status     string
+}
+
+// fileResult is the search result of one file, together with which of its matches should be replaced
+type fileResult struct {
+	search.FileResult
+
+	keep []bool
+}
+
+// keepFunc returns a function that tells if the match at index i of line lineNum should be replaced
+func (r *fileResult) keepFunc() func(lineNum, i int) bool {
+
+	keep := make(map[[2]int]bool, len(r.Matches))
+	for i := 0; i < len(r.Matches); i++ {
+		keep[[2]int{r.Matches[i].Line, r.Matches[i].Index}] = r.keep[i]
+	}
+
+	return func(lineNum, i int) bool {
+		return keep[[2]int{lineNum, i}]
+	}
+}
+
+func (r *fileResult) keptCount() int {
+
+	count := 0
+	for _, k := range r.keep {
+		if k {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (g *Gopad) openFindInFiles(e *Editor, withReplace bool) {
+
+	g.fif.isOpen = true
+	g.fif.focusQuery = true
+	g.fif.showReplace = withReplace
+
+	if g.fif.excludeText == "" {
+		g.fif.excludeText = strings.Join(settings.FindInFilesExclude, ", ")
+	}
+
+	if sel := e.SelectedText(); sel != "" && !strings.ContainsAny(sel, "\r\n") {
+		g.fif.opts.Query = sel
+	}
+}
+
+func (g *Gopad) startFindInFiles() {
+
+	g.cancelFindInFiles()
+
+	g.fif.results = g.fif.results[:0]
+	g.fif.matchCount = 0
+	g.fif.err = ""
+	g.fif.status = ""
+	if g.fif.opts.Query == "" {
+		return
+	}
+
+	exclude := []string{}
+	for _, p := range strings.Split(g.fif.excludeText, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			exclude = append(exclude, p)
+		}
+	}
+
+	finder, err := buffer.NewFinder(g.fif.opts)
+	if err != nil {
+		g.fif.err = "Invalid regexp: " + err.Error()
+		return
+	}
+
+	//Open files might have unsaved changes, so their text is searched instead of the file
+	open := map[string]*buffer.Snapshot{}
+	for i := 0; i < len(g.editors); i++ {
+		if e := &g.editors[i]; e.FilePath != "" {
+			open[filepath.Clean(e.FilePath)] = e.Buf.Snapshot()
+		}
+	}
+
+	s, err := search.Start(search.Options{
+		Root:         g.CurrDir,
+		Find:         g.fif.opts,
+		Exclude:      exclude,
+		MaxFileSize:  settings.FindInFilesMaxFileSize,
+		ContextLines: 2,
+		Open:         open,
+	})
+	if err != nil {
+		g.fif.err = "Invalid regexp: " + err.Error()
+		return
+	}
+
+	g.fif.search = s
+	g.fif.finder = finder
+	g.fif.root = g.CurrDir
+}
+
+func (g *Gopad) cancelFindInFiles() {
+
+	if g.fif.search != nil {
+		g.fif.search.Cancel()
+		g.fif.search = nil
+	}
+}
+
+// pollFindInFiles moves results found since the last frame into the results list
+func (g *Gopad) pollFindInFiles() {
+
+	if g.fif.search == nil {
+		return
+	}
+
+	for i := 0; i < maxResultsPerFrame; i++ {
+
+		select {
+		case res, ok := <-g.fif.search.Results:
+
+			if !ok {
+				g.fif.search = nil
+				return
+			}
+
+			fr := fileResult{FileResult: res, keep: make([]bool, len(res.Matches))}
+			for j := 0; j < len(fr.keep); j++ {
+				fr.keep[j] = true
+			}
+
+			g.fif.results = append(g.fif.results, fr)
+			g.fif.matchCount += len(res.Matches)
+
+		default:
+			return
+		}
+	}
+}
+
+func (g *Gopad) drawFindInFiles() {
+
+	if !g.fif.isOpen {
+		g.cancelFindInFiles()
+		return
+	}
+
+	g.pollFindInFiles()
+
+	imgui.SetNextWindowPosV(imgui.Vec2{X: g.winWidth * 0.25, Y: g.winHeight * 0.2}, imgui.ConditionFirstUseEver, imgui.Vec2{})
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: g.winWidth * 0.5, Y: g.winHeight * 0.6}, imgui.ConditionFirstUseEver)
+	if !imgui.BeginV("Find in Files", &g.fif.isOpen, imgui.WindowFlagsNoCollapse) {
+		imgui.End()
+		return
+	}
+
+	if g.fif.focusQuery {
+		imgui.SetKeyboardFocusHere()
+		g.fif.focusQuery = false
+	}
+
+	imgui.SetNextItemWidth(findInputWidth)
+	if imgui.InputTextV("Find##fifQuery", &g.fif.opts.Query, imgui.InputTextFlagsEnterReturnsTrue, nil) {
+		g.startFindInFiles()
+	}
+
+	imgui.SameLine()
+	imgui.Checkbox("Aa", &g.fif.opts.CaseSensitive)
+	imgui.SameLine()
+	imgui.Checkbox("Word", &g.fif.opts.WholeWord)
+	imgui.SameLine()
+	imgui.Checkbox(".*", &g.fif.opts.Regexp)
+	imgui.SameLine()
+	imgui.Checkbox("Replace", &g.fif.showReplace)
+
+	if g.fif.showReplace {
+
+		imgui.SetNextItemWidth(findInputWidth)
+		imgui.InputText("Replace##fifReplace", &g.fif.replaceText)
+
+		imgui.SameLine()
+		if imgui.Button("Replace All") && g.fif.search == nil {
+			g.applyReplaceInFiles()
+		}
+	}
+
+	imgui.SetNextItemWidth(findInputWidth)
+	imgui.InputText("Exclude##fifExclude", &g.fif.excludeText)
+
+	imgui.SameLine()
+	if g.fif.search == nil {
+		if imgui.Button("Search") {
+			g.startFindInFiles()
+		}
+	} else if imgui.Button("Cancel") {
+		g.cancelFindInFiles()
+	}
+
+	imgui.SameLine()
+	switch {
+	case g.fif.err != "":
+		imgui.Text(g.fif.err)
+	case g.fif.status != "":
+		imgui.Text(g.fif.status)
+	case g.fif.search != nil:
+		imgui.Text("Searching... " + strconv.Itoa(g.fif.matchCount) + " matches")
+	default:
+		imgui.Text(strconv.Itoa(g.fif.matchCount) + " matches in " + strconv.Itoa(len(g.fif.results)) + " files")
+	}
+
+	imgui.Separator()
+	imgui.BeginChild("fifResults")
+	for i := 0; i < len(g.fif.results); i++ {
+		g.drawFileResult(&g.fif.results[i])
+	}
+	imgui.EndChild()
+
+	imgui.End()
+}
+
+func (g *Gopad) drawFileResult(res *fileResult) {
+
+	relPath, err := filepath.Rel(g.fif.root, res.Path)
+	if err != nil {
+		relPath = res.Path
+	}
+
+	label := relPath + " (" + strconv.Itoa(len(res.Matches)) + ")##" + res.Path
+	if !imgui.TreeNodeV(label, imgui.TreeNodeFlagsDefaultOpen|imgui.TreeNodeFlagsSpanAvailWidth) {
+		return
+	}
+
+	for i := 0; i < len(res.Matches); i++ {
+
+		m := &res.Matches[i]
+		if g.fif.showReplace {
+			imgui.Checkbox("##fifKeep"+strconv.Itoa(i), &res.keep[i])
+			imgui.SameLine()
+		}
+
+		if imgui.Selectable(strconv.Itoa(m.Line+1) + ": " + resultPreview(m.Text) + "##" + strconv.Itoa(i)) {
+			g.openSearchResult(res.Path, m)
+		}
+
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip(strings.Join(m.Before, "\n") + "\n" + m.Text + "\n" + strings.Join(m.After, "\n"))
+		}
+
+		//Show how the line will look after replacing, once all of its matches were listed
+		isLastInLine := i == len(res.Matches)-1 || res.Matches[i+1].Line != m.Line
+		if g.fif.showReplace && isLastInLine {
+			g.drawReplacePreview(res, i)
+		}
+	}
+
+	imgui.TreePop()
+}
+
+// drawReplacePreview shows a diff of the line of the match at index matchIndex before and after replacing its kept matches
+func (g *Gopad) drawReplacePreview(res *fileResult, matchIndex int) {
+
+	m := &res.Matches[matchIndex]
+	keep := res.keepFunc()
+	newLine, count := g.fif.finder.ReplaceLine([]byte(m.Text), g.fif.replaceText, func(i int) bool { return keep(m.Line, i) })
+	if count == 0 {
+		return
+	}
+
+	imgui.PushStyleColor(imgui.StyleColorText, settings.DiffRemovedColor)
+	imgui.Text("    - " + resultPreview(m.Text))
+	imgui.PopStyleColor()
+
+	imgui.PushStyleColor(imgui.StyleColorText, settings.DiffAddedColor)
+	imgui.Text("    + " + resultPreview(string(newLine)))
+	imgui.PopStyleColor()
+}
+
+// applyReplaceInFiles replaces all the kept matches. Open files are changed in their editor, and other files are written to disk.
+//
+// Every file is first prepared (checked, converted and written to a temp file), and only once all of them are prepared are they
+// put in place, so that failing on one file doesn't leave the others half done. Files that fail are skipped and reported together at the end.
+func (g *Gopad) applyReplaceInFiles() {
+
+	type fileSave struct {
+		fPath string
+		ps    *pendingSave
+	}
+
+	var saves []fileSave
+	var errs, notices []string
+	replaced := 0
+	fileCount := 0
+	for i := 0; i < len(g.fif.results); i++ {
+
+		res := &g.fif.results[i]
+		if res.keptCount() == 0 {
+			continue
+		}
+
+		if e := g.findEditor(res.Path); e != nil {
+
+			//The editor has the text that counts, which can differ from what was searched on disk
+			if !res.linesMatch(e) {
+				errs = append(errs, res.Path+": the open file changed since it was searched. Search again to replace in it")
+				continue
+			}
+
+			replaced += e.ReplaceMatches(g.fif.finder, g.fif.replaceText, res.keepFunc())
+			fileCount++
+			continue
+		}
+
+		data, err := os.ReadFile(res.Path)
+		if err != nil {
+			errs = append(errs, res.Path+": "+err.Error())
+			continue
+		}
+
+		newData, count, err := search.ReplaceFile(data, &res.FileResult, g.fif.finder, g.fif.replaceText, res.keepFunc())
+		if err != nil {
+			errs = append(errs, res.Path+": "+err.Error())
+			continue
+		}
+
+		if count == 0 {
+			continue
+		}
+
+		ps, err := prepareSave(res.Path, newData)
+		if err != nil {
+			errs = append(errs, res.Path+": "+err.Error())
+			continue
+		}
+
+		saves = append(saves, fileSave{fPath: res.Path, ps: ps})
+		replaced += count
+		fileCount++
+	}
+
+	for _, save := range saves {
+
+		if err := save.ps.commit(); err != nil {
+			errs = append(errs, save.fPath+": "+err.Error())
+			continue
+		}
+
+		if notice := save.ps.notice(); notice != "" {
+			notices = append(notices, notice)
+		}
+	}
+
+	//The results no longer match the files
+	g.fif.results = g.fif.results[:0]
+	g.fif.matchCount = 0
+	g.fif.status = "Replaced " + strconv.Itoa(replaced) + " matches in " + strconv.Itoa(fileCount) + " files"
+
+	if len(errs) > 0 {
+		g.triggerError("Failed to replace in " + strconv.Itoa(len(errs)) + " files:\n" + strings.Join(errs, "\n"))
+	}
+
+	if len(notices) > 0 {
+		g.triggerError(strings.Join(notices, "\n"))
+	}
+}
+
+// linesMatch returns true if every line of e with a kept match still has the text it had when it was searched
+func (r *fileResult) linesMatch(e *Editor) bool {
+
+	for i := 0; i < len(r.Matches); i++ {
+
+		m := &r.Matches[i]
+		if r.keep[i] && (m.Line >= e.LineCount() || string(e.Buf.Line(m.Line)) != m.Text) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// openSearchResult opens the file of a result (or switches to it if already open) and selects the match
+func (g *Gopad) openSearchResult(fPath string, m *search.LineMatch) {
+
+	g.handleFileClick(fPath)
+
+	e := g.findEditor(fPath)
+	if e == nil {
+		return
+	}
+
+	off := e.Buf.LineStart(m.Line) + m.Start
+	e.ShowMatch(buffer.Match{Start: off, End: off + m.End - m.Start})
+}
+
+// findEditor returns the editor that has fPath open, or nil if there isn't one
+func (g *Gopad) findEditor(fPath string) *Editor {
+
+	fPath = filepath.Clean(fPath)
+	for i := 0; i < len(g.editors); i++ {
+
+		e := &g.editors[i]
+		if e.FilePath != "" && filepath.Clean(e.FilePath) == fPath {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// resultPreview returns text trimmed and shortened to fit in the results list
+func resultPreview(text string) string {
+
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\t", " "))
+	if runes := []rune(text); len(runes) > maxResultLineLen {
+		text = string(runes[:maxResultLineLen]) + "..."
+	}
+
+	return text
+}
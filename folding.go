@@ -0,0 +1,278 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/bloeys/gopad/buffer"
+)
+
+// fold is a range of hidden lines, kept as byte offsets so that it moves along with edits before it.
+// start is the start of the first hidden line and end is the end of the last one.
+type fold struct {
+	start int
+	end   int
+}
+
+// foldSet holds the folds of an editor. It is a pointer so that the buffer listener keeps working when editors are copied.
+type foldSet struct {
+	//folds are sorted by start. Folds can be nested.
+	folds []fold
+}
+
+func (fs *foldSet) edit(off, removed, inserted int) {
+
+	delta := inserted - removed
+	kept := fs.folds[:0]
+	for _, f := range fs.folds {
+
+		//Editing hidden text unfolds it
+		if off <= f.end && off+removed >= f.start {
+			continue
+		}
+
+		if off < f.start {
+			f.start += delta
+			f.end += delta
+		}
+
+		kept = append(kept, f)
+	}
+
+	fs.folds = kept
+}
+
+func (fs *foldSet) add(f fold) {
+
+	i := sort.Search(len(fs.folds), func(i int) bool { return fs.folds[i].start >= f.start })
+	if i < len(fs.folds) && fs.folds[i] == f {
+		return
+	}
+
+	fs.folds = append(fs.folds, fold{})
+	copy(fs.folds[i+1:], fs.folds[i:])
+	fs.folds[i] = f
+}
+
+// containing returns the outermost fold that hides off
+func (fs *foldSet) containing(off int) (fold, bool) {
+
+	for _, f := range fs.folds {
+
+		if f.start > off {
+			break
+		}
+
+		if off <= f.end {
+			return f, true
+		}
+	}
+
+	return fold{}, false
+}
+
+func newFoldSet(buf *buffer.Buffer) *foldSet {
+	fs := &foldSet{}
+	buf.Listen(fs.edit)
+	return fs
+}
+
+// isFolded returns true if the lines after lineNum are hidden by a fold that starts there
+func (e *Editor) isFolded(lineNum int) bool {
+
+	if lineNum+1 >= e.Buf.LineCount() {
+		return false
+	}
+
+	start := e.Buf.LineStart(lineNum + 1)
+	for _, f := range e.folds.folds {
+		if f.start == start {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *Editor) isLineHidden(lineNum int) bool {
+	_, ok := e.folds.containing(e.Buf.LineStart(lineNum))
+	return ok
+}
+
+// ToggleFold folds the foldable range that starts on lineNum, or unfolds it if it is folded
+func (e *Editor) ToggleFold(lineNum int) {
+
+	if e.isFolded(lineNum) {
+		e.Unfold(lineNum)
+		return
+	}
+
+	if e.Tree == nil {
+		return
+	}
+
+	if fr, ok := e.Tree.FoldAt(lineNum); ok {
+		e.folds.add(fold{start: e.Buf.LineStart(fr.StartLine + 1), end: e.Buf.LineEnd(fr.EndLine)})
+	}
+}
+
+func (e *Editor) Unfold(lineNum int) {
+
+	if lineNum+1 >= e.Buf.LineCount() {
+		return
+	}
+
+	start := e.Buf.LineStart(lineNum + 1)
+	kept := e.folds.folds[:0]
+	for _, f := range e.folds.folds {
+		if f.start != start {
+			kept = append(kept, f)
+		}
+	}
+
+	e.folds.folds = kept
+}
+
+// FoldEnclosing folds the innermost foldable range the cursor is in (or that starts on the cursor line)
+func (e *Editor) FoldEnclosing() {
+
+	if e.Tree == nil {
+		return
+	}
+
+	//Ranges are sorted and nested, so the last one that holds the line is the innermost
+	foldLine := -1
+	for _, fr := range e.Tree.FoldRanges() {
+
+		if fr.StartLine > e.Cursor.Line {
+			break
+		}
+
+		//EndLine+1 is the line with the closing bracket
+		if e.Cursor.Line <= fr.EndLine+1 && !e.isFolded(fr.StartLine) {
+			foldLine = fr.StartLine
+		}
+	}
+
+	if foldLine != -1 {
+		e.ToggleFold(foldLine)
+		e.SetCursor(foldLine, e.Line(foldLine).RuneCount())
+	}
+}
+
+func (e *Editor) FoldAll() {
+
+	if e.Tree == nil {
+		return
+	}
+
+	for _, fr := range e.Tree.FoldRanges() {
+		e.folds.add(fold{start: e.Buf.LineStart(fr.StartLine + 1), end: e.Buf.LineEnd(fr.EndLine)})
+	}
+
+	e.unfoldAtCursors()
+}
+
+func (e *Editor) UnfoldAll() {
+	e.folds.folds = e.folds.folds[:0]
+}
+
+// unfoldAtCursors removes the folds that hide a cursor, so that cursors are never on a hidden line
+func (e *Editor) unfoldAtCursors() {
+
+	for _, c := range e.Cursors() {
+
+		off := e.PosToOffset(c.Line, c.Col)
+		for {
+
+			f, ok := e.folds.containing(off)
+			if !ok {
+				break
+			}
+
+			e.Unfold(e.Buf.OffsetToLine(f.start) - 1)
+		}
+	}
+}
+
+// nextVisibleLine returns the first line after lineNum that isn't hidden, or LineCount if there is none
+func (e *Editor) nextVisibleLine(lineNum int) int {
+
+	lineNum++
+	for lineNum < e.Buf.LineCount() {
+
+		f, ok := e.folds.containing(e.Buf.LineStart(lineNum))
+		if !ok {
+			return lineNum
+		}
+
+		lineNum = e.Buf.OffsetToLine(f.end) + 1
+	}
+
+	return e.Buf.LineCount()
+}
+
+// prevVisibleLine returns the first line before lineNum that isn't hidden, or -1 if there is none
+func (e *Editor) prevVisibleLine(lineNum int) int {
+
+	lineNum--
+	for lineNum >= 0 {
+
+		f, ok := e.folds.containing(e.Buf.LineStart(lineNum))
+		if !ok {
+			return lineNum
+		}
+
+		//The line a fold starts after is always visible unless another fold hides it
+		lineNum = e.Buf.OffsetToLine(f.start) - 1
+	}
+
+	return -1
+}
+
+// visibleRows returns the line numbers of up to count rows of text starting at the line startLine
+func (e *Editor) visibleRows(startLine, count int, dst []int) []int {
+
+	dst = dst[:0]
+	if e.isLineHidden(startLine) {
+		startLine = e.prevVisibleLine(startLine)
+	}
+
+	for l := startLine; l >= 0 && l < e.Buf.LineCount() && len(dst) < count; l = e.nextVisibleLine(l) {
+		dst = append(dst, l)
+	}
+
+	return dst
+}
+
+// moveRows returns the line that is rowCount rows (i.e. visible lines) away from lineNum, stopping at the first and last lines
+func (e *Editor) moveRows(lineNum, rowCount int) int {
+
+	for ; rowCount > 0; rowCount-- {
+		next := e.nextVisibleLine(lineNum)
+		if next >= e.Buf.LineCount() {
+			break
+		}
+		lineNum = next
+	}
+
+	for ; rowCount < 0; rowCount++ {
+		prev := e.prevVisibleLine(lineNum)
+		if prev < 0 {
+			break
+		}
+		lineNum = prev
+	}
+
+	return lineNum
+}
+
+// rowsBetween returns how many rows there are from line a down to line b
+func (e *Editor) rowsBetween(a, b int) int {
+
+	rows := 0
+	for l := a; l < b; l = e.nextVisibleLine(l) {
+		rows++
+	}
+
+	return rows
+}
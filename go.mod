@@ -6,6 +6,7 @@ require (
 	github.com/bloeys/nmage v0.16.2
 	github.com/inkyblackness/imgui-go/v4 v4.6.0
 	github.com/veandco/go-sdl2 v0.4.25
+	golang.org/x/text v0.14.0
 )
 
 require (
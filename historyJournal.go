@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/bloeys/gopad/buffer"
+	"github.com/bloeys/gopad/charset"
+)
+
+// historyJournal is what gets persisted for every file so that its undo history survives closing it.
+// FileHash is the hash of the file contents at the saved state of the history, which lets us
+// detect that the file was changed outside Gopad and that the history no longer applies.
+// Encoding is what the file was decoded as, since the same bytes decoded differently are different text.
+// It is empty in journals from before encodings were supported, which were all UTF-8.
+type historyJournal struct {
+	FilePath string
+	FileHash string
+	Encoding string `json:",omitempty"`
+	History  buffer.Journal
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func journalPath(fPath string) (absPath, journalFile string, err error) {
+
+	absPath, err = filepath.Abs(fPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	dir, err := appDataDir("history")
+	if err != nil {
+		return "", "", err
+	}
+
+	return absPath, filepath.Join(dir, hashBytes([]byte(absPath))+".json"), nil
+}
+
+// loadHistoryJournal returns the persisted history of fPath, or nil if there is none or it doesn't match fileHash and enc.
+// Journals that can't be used are deleted.
+func loadHistoryJournal(fPath, fileHash string, enc charset.Encoding) *buffer.History {
+
+	absPath, journalFile, err := journalPath(fPath)
+	if err != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(journalFile)
+	if err != nil {
+		return nil
+	}
+
+	j := historyJournal{}
+	err = json.Unmarshal(data, &j)
+	if j.Encoding == "" {
+		j.Encoding = charset.UTF8.String()
+	}
+
+	if err != nil || j.FilePath != absPath || j.FileHash != fileHash || j.Encoding != enc.String() {
+		os.Remove(journalFile)
+		return nil
+	}
+
+	h, err := buffer.NewHistoryFromJournal(j.History)
+	if err != nil {
+		os.Remove(journalFile)
+		return nil
+	}
+
+	return h
+}
+
+// saveHistoryJournal persists the history of e. If there is nothing to undo or redo any old journal is removed instead.
+func saveHistoryJournal(e *Editor) error {
+
+	if e.FilePath == "" {
+		return nil
+	}
+
+	absPath, journalFile, err := journalPath(e.FilePath)
+	if err != nil {
+		return err
+	}
+
+	//A history with only the root step has nothing worth keeping
+	hj := e.History.Journal()
+	if len(hj.Steps) < 2 {
+		err = os.Remove(journalFile)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	data, err := json.Marshal(historyJournal{
+		FilePath: absPath,
+		FileHash: e.diskHash,
+		Encoding: e.decodedAs.String(),
+		History:  hj,
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(journalFile, data, 0600)
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 
+	"github.com/bloeys/gopad/buffer"
+	"github.com/bloeys/gopad/charset"
 	"github.com/bloeys/gopad/settings"
+	"github.com/bloeys/gopad/syntax"
+	"github.com/bloeys/gopad/theme"
 	"github.com/bloeys/nmage/engine"
 	"github.com/bloeys/nmage/input"
 	"github.com/bloeys/nmage/logging"
@@ -30,11 +36,34 @@ type Gopad struct {
 	CurrDir         string
 	CurrDirContents []fs.DirEntry
 
+	//cliPaths are the files given on the command line, and recentFolders the folders recently opened, newest first
+	cliPaths      []string
+	recentFolders []string
+
 	editors          []Editor
-	editorToClose    int
+	editorsToClose   []int
 	activeEditor     int
 	lastActiveEditor int
 	newRunes         []rune
+	keyPresses       []keyPress
+
+	clipboard *buffer.ClipboardHistory
+	find      findBar
+	fif       findInFilesPanel
+
+	themes      []*theme.Theme
+	themeImport themeImport
+
+	settingsFiles settingsFiles
+	settingsErr   string
+	prefs         preferences
+
+	recovery   recovery
+	closeGuard closeGuard
+	fileDialog fileDialog
+
+	//revertID is the editor waiting for the user to confirm throwing its changes away, or 0
+	revertID int
 
 	//Errors
 	haveErr bool
@@ -47,12 +76,18 @@ type Gopad struct {
 
 func main() {
 
+	//Paths on the command line are relative to where Gopad was started, not to the executable's folder it moves to
+	startDir, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+
 	chdirErr := os.Chdir(filepath.Dir(os.Args[0]))
 	if chdirErr != nil {
 		panic(chdirErr.Error())
 	}
 
-	if err := engine.Init(); err != nil {
+	if err = engine.Init(); err != nil {
 		panic(err)
 	}
 
@@ -71,12 +106,39 @@ func main() {
 		Win:                window,
 		ImGUIInfo:          nmageimgui.NewImGUI(),
 		CurrDir:            dir,
-		editors:            []Editor{*NewScratchEditor()},
-		editorToClose:      -1,
 		sidebarWidthFactor: 0.15,
 		newRunes:           []rune{},
 	}
 
+	//Unsaved changes are written out if anything panics
+	defer g.saveSessionOnPanic()
+
+	//A folder on the command line becomes the sidebar folder before the settings are read, so its project settings apply
+	g.cliPaths = make([]string, 0, len(os.Args)-1)
+	for _, arg := range os.Args[1:] {
+
+		if !filepath.IsAbs(arg) {
+			arg = filepath.Join(startDir, arg)
+		}
+
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			g.CurrDir = filepath.Clean(arg)
+			continue
+		}
+
+		g.cliPaths = append(g.cliPaths, arg)
+	}
+
+	g.recentFolders = loadRecentPaths().Folders
+	if g.CurrDir != dir {
+		g.rememberFolder(g.CurrDir)
+	}
+
+	//Settings are read before anything that uses them is created, but errors can only be shown once imgui is running
+	g.settingsErr = g.readSettings()
+	g.editors = []Editor{*NewScratchEditor()}
+	g.clipboard = buffer.NewClipboardHistory(sdlClipboard{}, settings.ClipboardHistorySize)
+
 	// Init runs within an imgui frame, but imgui frames do NOT allow adding fonts,
 	// so we do it here
 	g.LoadFonts()
@@ -102,22 +164,36 @@ func (g *Gopad) Init() {
 	g.Win.EventCallbacks = append(g.Win.EventCallbacks, g.handleWindowEvents)
 
 	//Sidebar
-	g.CurrDirContents = getDirContents(g.CurrDir)
+	g.refreshCurrDir()
 
 	w, h := g.Win.SDLWin.GetSize()
 	g.winWidth = float32(w)
 	g.winHeight = float32(h)
 	g.sidebarWidthPx = g.winWidth * g.sidebarWidthFactor
 
-	//Read os.Args
-	for i := 1; i < len(os.Args); i++ {
+	//Grammars must be loaded before any editors are created so they can be used for highlighting
+	if errs := syntax.LoadGrammars("./res/grammars"); len(errs) > 0 {
 
-		e := *NewEditor(os.Args[i])
-		g.editors = append(g.editors, e)
-		g.activeEditor = len(g.editors) - 1
+		errMsg := ""
+		for _, err := range errs {
+			errMsg += err.Error() + "\n"
+		}
+
+		g.triggerError(errMsg)
 	}
 
-	g.activeEditor = len(g.editors) - 1
+	if g.settingsErr != "" {
+		g.triggerError("Problems in settings:\n" + g.settingsErr)
+	}
+
+	g.loadThemes()
+
+	g.restoreSession()
+
+	//Files the session already opened are only switched to
+	for _, fPath := range g.cliPaths {
+		g.handleFileClick(fPath)
+	}
 
 	// Prepare editors
 	imgui.PushFont(g.mainFont)
@@ -135,6 +211,11 @@ func (g *Gopad) handleWindowEvents(event sdl.Event) {
 	case *sdl.TextEditingEvent:
 	case *sdl.TextInputEvent:
 		g.newRunes = append(g.newRunes, []rune(e.GetText())...)
+	case *sdl.KeyboardEvent:
+		//Unlike input.KeyClicked, this also gets the repeated presses that happen while a key is held
+		if e.Type == sdl.KEYDOWN {
+			g.keyPresses = append(g.keyPresses, keyPress{key: e.Keysym.Sym, mod: e.Keysym.Mod})
+		}
 	case *sdl.WindowEvent:
 		if e.Event == sdl.WINDOWEVENT_SIZE_CHANGED {
 			w, h := g.Win.SDLWin.GetSize()
@@ -147,6 +228,7 @@ func (g *Gopad) handleWindowEvents(event sdl.Event) {
 
 func (g *Gopad) closeEditor(eIndex int) {
 
+	g.saveHistoryJournal(&g.editors[eIndex])
 	g.editors = append(g.editors[:eIndex], g.editors[eIndex+1:]...)
 
 	if g.activeEditor >= len(g.editors) {
@@ -159,7 +241,7 @@ func (g *Gopad) closeEditor(eIndex int) {
 func (g *Gopad) Update() {
 
 	if input.IsQuitClicked() {
-		engine.Quit()
+		g.requestQuit()
 		return
 	}
 
@@ -167,6 +249,9 @@ func (g *Gopad) Update() {
 		g.showErrorPopup()
 	}
 
+	g.checkSettingsFiles()
+	g.checkRecovery()
+
 	if input.MouseClicked(sdl.BUTTON_LEFT) {
 		x, y := input.GetMousePos()
 		g.getActiveEditor().SetCursorPos(int(x), int(y))
@@ -178,41 +263,138 @@ func (g *Gopad) Update() {
 
 	//Close editor if needed
 	if input.KeyDown(sdl.K_LCTRL) && input.KeyClicked(sdl.K_w) {
-		g.closeEditor(g.activeEditor)
-		g.editorToClose = -1
+		g.requestClose(g.activeEditor)
+	}
+
+	if input.KeyDown(sdl.K_LCTRL) && input.KeyClicked(sdl.K_n) {
+		g.newFile()
+	} else if input.KeyDown(sdl.K_LCTRL) && input.KeyClicked(sdl.K_o) {
+		g.openFile()
+	} else if input.KeyDown(sdl.K_LCTRL) && input.KeyDown(sdl.K_LSHIFT) && input.KeyClicked(sdl.K_s) {
+		g.saveAs(g.getActiveEditor(), nil)
 	}
 
 	e := g.getActiveEditor()
 
+	if input.KeyDown(sdl.K_LCTRL) && input.KeyClicked(sdl.K_COMMA) {
+		g.openPreferences()
+	}
+
+	//Undo/redo
+	if input.KeyDown(sdl.K_LCTRL) && input.KeyClicked(sdl.K_z) {
+
+		if input.KeyDown(sdl.K_LSHIFT) {
+			e.Redo()
+		} else {
+			e.Undo()
+		}
+	}
+
+	//Find/replace
+	if input.KeyDown(sdl.K_LCTRL) && input.KeyDown(sdl.K_LSHIFT) && input.KeyClicked(sdl.K_f) {
+		g.openFindInFiles(e, false)
+	} else if input.KeyDown(sdl.K_LCTRL) && input.KeyDown(sdl.K_LSHIFT) && input.KeyClicked(sdl.K_h) {
+		g.openFindInFiles(e, true)
+	} else if input.KeyDown(sdl.K_LCTRL) && input.KeyClicked(sdl.K_f) {
+		g.openFindBar(e, false)
+	} else if input.KeyDown(sdl.K_LCTRL) && input.KeyClicked(sdl.K_h) {
+		g.openFindBar(e, true)
+	} else if g.find.isOpen && input.KeyClicked(sdl.K_ESCAPE) {
+		g.closeFindBar()
+	}
+
+	//Clipboard
+	if input.KeyDown(sdl.K_LCTRL) && !imgui.IsAnyItemActive() {
+
+		if input.KeyClicked(sdl.K_c) {
+			g.copy(e, false)
+		} else if input.KeyClicked(sdl.K_x) {
+			g.copy(e, true)
+		} else if input.KeyClicked(sdl.K_v) {
+
+			if input.KeyDown(sdl.K_LSHIFT) {
+				imgui.OpenPopup("clipboardHistory")
+			} else {
+				g.paste(e)
+			}
+		}
+	}
+
 	//Save if needed
-	if !e.IsModified {
+	if !e.IsModified() {
 		return
 	}
 
-	if !input.KeyDown(sdl.K_LCTRL) || !input.KeyClicked(sdl.K_s) {
+	if !input.KeyDown(sdl.K_LCTRL) || input.KeyDown(sdl.K_LSHIFT) || !input.KeyClicked(sdl.K_s) {
 		return
 	}
 
 	g.saveEditor(e)
 }
 
-func (g *Gopad) saveEditor(e *Editor) {
+// saveEditor applies the save options (e.g. trimming whitespace) to the editor's document then writes it, and returns false if that failed
+func (g *Gopad) saveEditor(e *Editor) bool {
 
+	//A scratch editor has no file yet, so it is saved once a file is picked
 	if e.FileName == "**scratch**" {
-		e.IsModified = false
-		return
+		g.saveAs(e, nil)
+		return false
 	}
 
-	err := os.WriteFile(e.FilePath, []byte(e.FileContents), os.ModePerm)
+	e.PrepareSave(e.saveOptions())
+	return g.writeEditor(e)
+}
+
+// writeEditor writes the editor's document to its file in the editor's encoding, and returns false if that failed
+func (g *Gopad) writeEditor(e *Editor) bool {
+
+	text := bytes.Buffer{}
+	text.Grow(e.Buf.Len())
+	e.Endings.WriteTo(&text, 0)
+
+	//Nothing is written rather than silently losing chars
+	data, unencodable := charset.Encode(text.Bytes(), e.Encoding)
+	if unencodable > 0 {
+		g.triggerError(fmt.Sprintf("Can't save '%s' as %s because it has %d chars that %s can't represent. Use 'Save with Encoding' to pick another encoding.",
+			e.FileName, e.Encoding, unencodable, e.Encoding))
+		return false
+	}
+
+	notice, err := saveFile(e.FilePath, data)
 	if err != nil {
 		g.triggerError("Failed to save file. Error: " + err.Error())
-		return
+		return false
 	}
 
-	e.IsModified = false
+	if notice != "" {
+		g.triggerError(notice)
+	}
+
+	e.diskHash = hashBytes(data)
+	e.decodedAs = e.Encoding
+	e.lossyDecode = false
+	e.MarkSaved()
+
+	g.saveHistoryJournal(e)
+	return true
+}
+
+func (g *Gopad) saveHistoryJournal(e *Editor) {
+
+	err := saveHistoryJournal(e)
+	if err != nil {
+		g.triggerError("Failed to save undo history of '" + e.FileName + "'. Error: " + err.Error())
+	}
 }
 
 func (g *Gopad) triggerError(errMsg string) {
+
+	//Errors that happen while one is being shown are added to it instead of replacing it
+	if g.haveErr {
+		g.errMsg += "\n" + errMsg
+		return
+	}
+
 	imgui.OpenPopup("err")
 	g.haveErr = true
 	g.errMsg = errMsg
@@ -225,7 +407,10 @@ func (g *Gopad) showErrorPopup() {
 
 	shouldEnd := imgui.BeginPopup("err")
 
+	imgui.PushStyleColor(imgui.StyleColorText, settings.ErrorTextColor)
 	imgui.Text(g.errMsg)
+	imgui.PopStyleColor()
+
 	if imgui.Button("OK") {
 		g.haveErr = false
 		imgui.CloseCurrentPopup()
@@ -243,9 +428,24 @@ func (g *Gopad) Render() {
 	//Global imgui settings
 	imgui.PushFont(g.mainFont)
 
+	if g.settingsFiles.editorsNeedFonts {
+		for i := 0; i < len(g.editors); i++ {
+			g.editors[i].RefreshFontSettings()
+		}
+		g.settingsFiles.editorsNeedFonts = false
+	}
+
 	g.drawMenubar()
 	g.drawSidebar()
 	g.drawEditors()
+	g.drawClipboardHistory()
+	g.drawFindInFiles()
+	g.drawThemeImport()
+	g.drawPreferences()
+	g.drawRecovery()
+	g.drawCloseGuard()
+	g.drawRevertConfirm()
+	g.drawFileDialog()
 
 	imgui.PopFont()
 }
@@ -256,10 +456,116 @@ func (g *Gopad) drawMenubar() {
 
 	if imgui.BeginMenu("File") {
 
-		if imgui.MenuItem("Save") {
-			g.saveEditor(g.getActiveEditor())
+		g.drawFileMenuItems()
+		g.drawEncodingMenus()
+		imgui.Separator()
+
+		if imgui.MenuItemV("Preferences", "Ctrl+,", false, true) {
+			g.openPreferences()
+		}
+
+		imgui.EndMenu()
+	}
+
+	//Popups opened from inside a menu would be part of the menu's ID stack, so they are opened after it
+	openClipboardHistory := false
+	if imgui.BeginMenu("Edit") {
+
+		e := g.getActiveEditor()
+		if imgui.MenuItemV("Undo", "Ctrl+Z", false, e.History.CanUndo()) {
+			e.Undo()
+		}
+
+		if imgui.MenuItemV("Redo", "Ctrl+Shift+Z", false, e.History.CanRedo()) {
+			e.Redo()
+		}
+
+		//Edits made after undoing start a new branch, and this picks which one Redo follows
+		branch, branchCount := e.History.RedoBranches()
+		if imgui.MenuItemV("Next Redo Branch ("+strconv.Itoa(branch+1)+"/"+strconv.Itoa(branchCount)+")", "", false, branchCount > 1) {
+			e.History.NextRedoBranch()
+		}
+
+		imgui.Separator()
+
+		if imgui.MenuItemV("Cut", "Ctrl+X", false, e.HasSelection()) {
+			g.copy(e, true)
+		}
+
+		if imgui.MenuItemV("Copy", "Ctrl+C", false, e.HasSelection()) {
+			g.copy(e, false)
+		}
+
+		if imgui.MenuItemV("Paste", "Ctrl+V", false, true) {
+			g.paste(e)
+		}
+
+		if imgui.MenuItemV("Paste from History", "Ctrl+Shift+V", false, len(g.clipboard.Entries) > 0) {
+			openClipboardHistory = true
+		}
+
+		imgui.Separator()
+
+		if imgui.MenuItemV("Find", "Ctrl+F", false, true) {
+			g.openFindBar(e, false)
+		}
+
+		if imgui.MenuItemV("Replace", "Ctrl+H", false, true) {
+			g.openFindBar(e, true)
 		}
 
+		if imgui.MenuItemV("Find in Files", "Ctrl+Shift+F", false, true) {
+			g.openFindInFiles(e, false)
+		}
+
+		if imgui.MenuItemV("Replace in Files", "Ctrl+Shift+H", false, true) {
+			g.openFindInFiles(e, true)
+		}
+
+		imgui.Separator()
+
+		if imgui.MenuItemV("Expand Selection", "Alt+Shift+Right", false, e.Tree != nil) {
+			e.ExpandSelection()
+		}
+
+		if imgui.MenuItemV("Shrink Selection", "Alt+Shift+Left", false, len(e.expandHistory) > 0) {
+			e.ShrinkSelection()
+		}
+
+		if imgui.MenuItemV("Go to Bracket", "Ctrl+Shift+\\", false, e.Tree != nil) {
+			e.GoToMatchingBracket()
+		}
+
+		imgui.Separator()
+
+		if imgui.MenuItemV("Fold", "Ctrl+Shift+[", false, e.Tree != nil) {
+			e.FoldEnclosing()
+		}
+
+		if imgui.MenuItemV("Unfold", "Ctrl+Shift+]", false, e.isFolded(e.Cursor.Line)) {
+			e.Unfold(e.Cursor.Line)
+		}
+
+		if imgui.MenuItemV("Fold All", "", false, e.Tree != nil) {
+			e.FoldAll()
+		}
+
+		if imgui.MenuItemV("Unfold All", "", false, len(e.folds.folds) > 0) {
+			e.UnfoldAll()
+		}
+
+		imgui.Separator()
+
+		if imgui.BeginMenu("Line Endings") {
+			drawLineEndingItems(e)
+			imgui.EndMenu()
+		}
+
+		imgui.EndMenu()
+	}
+
+	if imgui.BeginMenu("View") {
+		g.drawThemeMenu()
 		imgui.EndMenu()
 	}
 
@@ -267,15 +573,32 @@ func (g *Gopad) drawMenubar() {
 	if shouldCloseMenuBar {
 		imgui.EndMainMenuBar()
 	}
+
+	if openClipboardHistory {
+		imgui.OpenPopup("clipboardHistory")
+	}
 }
 
 func (g *Gopad) drawSidebar() {
 
 	imgui.SetNextWindowPos(imgui.Vec2{X: 0, Y: g.mainMenuBarHeight})
 	imgui.SetNextWindowSize(imgui.Vec2{X: g.sidebarWidthPx, Y: g.winHeight - g.mainMenuBarHeight})
+	imgui.PushStyleColor(imgui.StyleColorWindowBg, settings.SidebarBgColor)
 	imgui.BeginV("sidebar", nil, imgui.WindowFlagsNoCollapse|imgui.WindowFlagsNoDecoration|imgui.WindowFlagsNoMove)
 
 	imgui.PushStyleColor(imgui.StyleColorButton, imgui.Vec4{W: 0})
+	imgui.PushStyleColor(imgui.StyleColorText, settings.SidebarTextColor)
+
+	//Clicking the folder name opens another folder
+	if imgui.Button(filepath.Base(g.CurrDir) + "/##sidebarRoot") {
+		g.openFolder()
+	}
+
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip(g.CurrDir + "\nClick to open another folder")
+	}
+	imgui.Separator()
+
 	for i := 0; i < len(g.CurrDirContents); i++ {
 
 		c := g.CurrDirContents[i]
@@ -286,8 +609,9 @@ func (g *Gopad) drawSidebar() {
 		}
 	}
 
-	imgui.PopStyleColor()
+	imgui.PopStyleColorV(2)
 	imgui.End()
+	imgui.PopStyleColor()
 }
 
 func (g *Gopad) drawEditors() {
@@ -301,7 +625,6 @@ func (g *Gopad) drawEditors() {
 	isEditorsEnabled := imgui.BeginTabBarV("editorTabs", 0)
 	shouldForceSwitch := g.activeEditor != g.lastActiveEditor
 
-	prevActiveEditor := g.activeEditor
 	for i := 0; i < len(g.editors); i++ {
 
 		e := &g.editors[i]
@@ -311,20 +634,21 @@ func (g *Gopad) drawEditors() {
 			flags = imgui.TabItemFlagsSetSelected
 		}
 
-		if e.IsModified {
+		if e.IsModified() {
 			flags |= imgui.TabItemFlagsUnsavedDocument
 		}
 
 		open := true
-		if !imgui.BeginTabItemV(e.FileName, &open, flags) {
+		//The id keeps tabs of files with the same name apart
+		if !imgui.BeginTabItemV(e.FileName+"##"+strconv.Itoa(e.id), &open, flags) {
 
 			if !open {
-				g.editorToClose = i
+				g.requestClose(i)
 			}
 			continue
 		}
 		if !open {
-			g.editorToClose = i
+			g.requestClose(i)
 		}
 
 		//If these two aren't equal it means we programmatically changed the active editor (instead of a mouse click),
@@ -344,40 +668,40 @@ func (g *Gopad) drawEditors() {
 	tabsHeight := imgui.WindowHeight()
 	imgui.End()
 
-	g.getActiveEditor().UpdateAndDraw(
-		&imgui.Vec2{X: g.sidebarWidthPx, Y: g.mainMenuBarHeight + tabsHeight},
-		&imgui.Vec2{X: g.winWidth - g.sidebarWidthPx, Y: g.winHeight - g.mainMenuBarHeight - tabsHeight},
-		g.newRunes,
-	)
+	e := g.getActiveEditor()
+	e.Finder = nil
 
-	if shouldForceSwitch || prevActiveEditor != g.activeEditor {
-		imgui.SetKeyboardFocusHereV(-1)
+	findBarHeight := float32(0)
+	if g.find.isOpen {
+		findBarHeight = g.drawFindBar(e, &imgui.Vec2{X: g.sidebarWidthPx, Y: g.mainMenuBarHeight + tabsHeight}, g.winWidth-g.sidebarWidthPx)
+		e.Finder = g.find.finder
 	}
 
-	imgui.PopStyleColor()
-	imgui.PopStyleColor()
-	imgui.End()
-}
+	topHeight := g.mainMenuBarHeight + tabsHeight + findBarHeight
+	statusHeight := statusBarHeight()
+	e.UpdateAndDraw(
+		&imgui.Vec2{X: g.sidebarWidthPx, Y: topHeight},
+		&imgui.Vec2{X: g.winWidth - g.sidebarWidthPx, Y: g.winHeight - topHeight - statusHeight},
+		g.newRunes,
+		g.keyPresses,
+	)
 
-func (g *Gopad) getActiveEditor() *Editor {
-	return g.getEditor(g.activeEditor)
+	g.drawStatusBar(e, &imgui.Vec2{X: g.sidebarWidthPx, Y: g.winHeight - statusHeight}, g.winWidth-g.sidebarWidthPx)
 }
 
-func (g *Gopad) getEditor(index int) *Editor {
+// getActiveEditor returns the active editor, opening a scratch editor if there are none
+func (g *Gopad) getActiveEditor() *Editor {
 
 	if len(g.editors) == 0 {
-		e := *NewScratchEditor()
+		e := NewScratchEditor()
 		e.RefreshFontSettings()
-		g.editors = append(g.editors, e)
+		g.editors = append(g.editors, *e)
 		g.activeEditor = 0
-		return &e
-	}
-
-	if index >= 0 && index < len(g.editors) {
-		return &g.editors[index]
 	}
 
-	panic(fmt.Sprint("Invalid editor index: ", index))
+	//An index left out of range (e.g. by closing tabs) moves to the nearest editor
+	g.activeEditor = clampInt(g.activeEditor, 0, len(g.editors)-1)
+	return &g.editors[g.activeEditor]
 }
 
 func (g *Gopad) drawDir(dir fs.DirEntry, path string) {
@@ -413,7 +737,7 @@ func (g *Gopad) handleFileClick(fPath string) {
 	for i := 0; i < len(g.editors); i++ {
 
 		e := &g.editors[i]
-		if e.FilePath == fPath {
+		if filepath.Clean(e.FilePath) == filepath.Clean(fPath) {
 			editorIndex = i
 			break
 		}
@@ -426,19 +750,37 @@ func (g *Gopad) handleFileClick(fPath string) {
 	}
 
 	//Read new file and switch to it
-	e := *NewEditor(fPath)
+	e, err := NewEditorWithEncoding(fPath, nil)
+	if err != nil {
+		g.triggerError("Failed to open '" + fPath + "'. Error: " + err.Error())
+		return
+	}
+
 	e.RefreshFontSettings()
-	g.editors = append(g.editors, e)
+	g.reportOpenProblems(e)
+
+	g.editors = append(g.editors, *e)
 	g.activeEditor = len(g.editors) - 1
 }
 
 func (g *Gopad) FrameEnd() {
 	g.newRunes = []rune{}
+	g.keyPresses = g.keyPresses[:0]
 
 	// Close editors if needed
-	if g.editorToClose > -1 {
-		g.closeEditor(g.editorToClose)
-		g.editorToClose = -1
+	for _, id := range g.editorsToClose {
+		if i := g.editorIndexByID(id); i != -1 {
+			g.closeEditor(i)
+		}
+	}
+	g.editorsToClose = g.editorsToClose[:0]
+
+	//Fonts can't be added during a frame, so a font size change is applied here and editors pick it up next frame.
+	//@NOTE: The old font stays in the font atlas
+	if g.settingsFiles.fontsChanged {
+		g.LoadFonts()
+		g.settingsFiles.fontsChanged = false
+		g.settingsFiles.editorsNeedFonts = true
 	}
 }
 
@@ -446,11 +788,13 @@ func (g *Gopad) DeInit() {
 	g.Win.Destroy()
 }
 
+// getDirContents returns the entries of dir. Folders that can't be read (e.g. without permission) show as empty
+// rather than stopping the sidebar from drawing.
 func getDirContents(dir string) []fs.DirEntry {
 
 	contents, err := os.ReadDir(dir)
 	if err != nil {
-		panic(err)
+		return nil
 	}
 
 	return contents
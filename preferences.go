@@ -0,0 +1,374 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bloeys/gopad/settings"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// settingsCheckInterval is how often the settings files are checked for changes
+const settingsCheckInterval = time.Second
+
+// settingsFiles tracks the user and project settings files, so that they can be applied again when they change
+type settingsFiles struct {
+	userPath    string
+	projectPath string
+
+	user    settings.Values
+	project settings.Values
+
+	//stamps are the mod time and size of each file when it was last read, which are zero for missing files
+	stamps    map[string]fileStamp
+	lastCheck time.Time
+
+	//fontsChanged means the fonts must be loaded again at the end of the frame, and editorsNeedFonts means
+	//the editors must then refresh their font settings in the next frame
+	fontsChanged     bool
+	editorsNeedFonts bool
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statStamp(fPath string) fileStamp {
+
+	info, err := os.Stat(fPath)
+	if err != nil {
+		return fileStamp{}
+	}
+
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// preferences is the state of the Preferences window, which edits the user settings file
+type preferences struct {
+	isOpen bool
+
+	//vals are the user settings being edited, and texts the text of the settings edited as text
+	vals  settings.Values
+	texts map[string]string
+}
+
+// readSettings reads the user and project settings files and applies them. It returns the problems found, if any.
+// It doesn't touch imgui, so it can run before the first frame.
+func (g *Gopad) readSettings() string {
+
+	sf := &g.settingsFiles
+	errMsg := ""
+	if dir, err := appDataDir(""); err == nil {
+		sf.userPath = filepath.Join(dir, settings.FileName)
+	} else {
+		errMsg += "Failed to find user settings folder. Error: " + err.Error() + "\n"
+	}
+
+	sf.projectPath = filepath.Join(g.CurrDir, settings.ProjectFileName)
+	sf.stamps = map[string]fileStamp{}
+
+	if sf.userPath != "" {
+		sf.stamps[sf.userPath] = statStamp(sf.userPath)
+		errMsg += readSettingsFile(sf.userPath, &sf.user)
+	}
+
+	sf.stamps[sf.projectPath] = statStamp(sf.projectPath)
+	errMsg += readSettingsFile(sf.projectPath, &sf.project)
+
+	settings.Apply(sf.user, sf.project)
+	sf.lastCheck = time.Now()
+	return errMsg
+}
+
+// readSettingsFile reads a settings file into vals. A file that can't be read at all (e.g. while it is half edited)
+// leaves vals as they were, so settings don't jump back to their defaults.
+func readSettingsFile(fPath string, vals *settings.Values) string {
+
+	newVals, err := settings.ReadFile(fPath)
+	if newVals != nil {
+		*vals = newVals
+	}
+
+	if err != nil {
+		return err.Error() + "\n"
+	}
+
+	return ""
+}
+
+// reloadSettings reads the settings files again and updates everything that depends on a setting that changed
+func (g *Gopad) reloadSettings() {
+
+	old := settings.Current()
+	if errMsg := g.readSettings(); errMsg != "" {
+		g.triggerError("Problems in settings:\n" + errMsg)
+	}
+
+	g.applySettingChanges(old)
+}
+
+// applySettingChanges updates the state that was created from the settings in old
+func (g *Gopad) applySettingChanges(old settings.Values) {
+
+	if old["editor.font_size"] != settings.FontSize {
+		g.settingsFiles.fontsChanged = true
+	}
+
+	if old["editor.tab_size"] != settings.TabSize {
+		//Files with an .editorconfig tab width keep it
+		for i := 0; i < len(g.editors); i++ {
+			if g.editors[i].Config.TabWidth == 0 {
+				g.editors[i].TabSize = settings.TabSize
+			}
+		}
+	}
+
+	g.clipboard.MaxEntries = settings.ClipboardHistorySize
+	if len(g.clipboard.Entries) > g.clipboard.MaxEntries {
+		g.clipboard.Entries = g.clipboard.Entries[:g.clipboard.MaxEntries]
+	}
+
+	if old["theme"] != settings.Theme {
+
+		t := g.findTheme(settings.Theme)
+		if t == nil {
+			g.triggerError("Unknown theme '" + settings.Theme + "'")
+			return
+		}
+
+		g.applyTheme(t)
+	}
+}
+
+// checkSettingsFiles reloads the settings if either settings file changed since it was last read
+func (g *Gopad) checkSettingsFiles() {
+
+	sf := &g.settingsFiles
+	if time.Since(sf.lastCheck) < settingsCheckInterval {
+		return
+	}
+
+	sf.lastCheck = time.Now()
+	for fPath, stamp := range sf.stamps {
+		if statStamp(fPath) != stamp {
+			g.reloadSettings()
+			return
+		}
+	}
+}
+
+func (g *Gopad) openPreferences() {
+
+	//Settings the user file doesn't set are shown with their defaults
+	p := &g.prefs
+	p.isOpen = true
+	p.vals = settings.Defaults()
+	for k, v := range g.settingsFiles.user {
+		p.vals[k] = v
+	}
+
+	p.texts = map[string]string{}
+	for _, s := range settings.Schema {
+		switch v := p.vals[s.Name].(type) {
+		case int64:
+			p.texts[s.Name] = strconv.FormatInt(v, 10)
+		case []string:
+			p.texts[s.Name] = strings.Join(v, ", ")
+		}
+	}
+}
+
+func (g *Gopad) drawPreferences() {
+
+	p := &g.prefs
+	if !p.isOpen {
+		return
+	}
+
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: g.winWidth * 0.5, Y: g.winHeight * 0.6}, imgui.ConditionFirstUseEver)
+	if !imgui.BeginV("Preferences", &p.isOpen, imgui.WindowFlagsNoCollapse) {
+		imgui.End()
+		return
+	}
+
+	imgui.TextDisabled("Saved to " + g.settingsFiles.userPath)
+	imgui.Separator()
+
+	for _, s := range settings.Schema {
+		g.drawSettingInput(s)
+	}
+
+	imgui.Separator()
+	if imgui.Button("Save") {
+		g.savePreferences()
+	}
+
+	imgui.SameLine()
+	if imgui.Button("Reset to Defaults") {
+		g.settingsFiles.user = settings.Values{}
+		g.openPreferences()
+	}
+
+	imgui.SameLine()
+	if imgui.Button("Open Settings File") {
+		g.openSettingsFile()
+	}
+
+	imgui.End()
+}
+
+// drawSettingInput draws the widget that edits one setting in the Preferences window
+func (g *Gopad) drawSettingInput(s *settings.Setting) {
+
+	p := &g.prefs
+	label := s.Name
+	switch v := p.vals[s.Name].(type) {
+
+	case float32:
+		if imgui.SliderFloat(label, &v, float32(s.Min), float32(s.Max)) {
+			p.vals[s.Name] = v
+		}
+
+	case int:
+		v32 := int32(v)
+		if imgui.InputInt(label, &v32) {
+			p.vals[s.Name] = clampInt(int(v32), int(s.Min), int(s.Max))
+		}
+
+	case string:
+		if s.Ptr == &settings.Theme {
+			g.drawThemeCombo(label, v)
+		} else if len(s.Options) > 0 {
+			g.drawOptionsCombo(s, v)
+		} else if imgui.InputText(label, &v) {
+			p.vals[s.Name] = v
+		}
+
+	case int64:
+		text := p.texts[s.Name]
+		if imgui.InputText(label, &text) {
+			p.texts[s.Name] = text
+			if n, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64); err == nil && n >= int64(s.Min) {
+				p.vals[s.Name] = n
+			}
+		}
+
+	case []string:
+		text := p.texts[s.Name]
+		if imgui.InputText(label, &text) {
+			p.texts[s.Name] = text
+			p.vals[s.Name] = splitList(text)
+		}
+	}
+
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip(s.Desc)
+	}
+
+	if _, ok := g.settingsFiles.project[s.Name]; ok {
+		imgui.SameLine()
+		imgui.TextDisabled("(overridden by " + settings.ProjectFileName + ")")
+	}
+}
+
+func (g *Gopad) drawThemeCombo(label, current string) {
+
+	if !imgui.BeginCombo(label, current) {
+		return
+	}
+
+	for _, t := range g.themes {
+		if imgui.SelectableV(t.Name, strings.EqualFold(t.Name, current), 0, imgui.Vec2{}) {
+			g.prefs.vals["theme"] = t.Name
+		}
+	}
+
+	imgui.EndCombo()
+}
+
+func (g *Gopad) drawOptionsCombo(s *settings.Setting, current string) {
+
+	if !imgui.BeginCombo(s.Name, current) {
+		return
+	}
+
+	for _, opt := range s.Options {
+		if imgui.SelectableV(opt, opt == current, 0, imgui.Vec2{}) {
+			g.prefs.vals[s.Name] = opt
+		}
+	}
+
+	imgui.EndCombo()
+}
+
+// savePreferences writes the settings that differ from their defaults (or that the file already had) to the user settings file
+func (g *Gopad) savePreferences() {
+
+	defaults := settings.Defaults()
+	vals := settings.Values{}
+	for k, v := range g.prefs.vals {
+
+		_, inFile := g.settingsFiles.user[k]
+		if inFile || !reflect.DeepEqual(v, defaults[k]) {
+			vals[k] = v
+		}
+	}
+
+	if err := settings.WriteFile(g.settingsFiles.userPath, vals); err != nil {
+		g.triggerError("Failed to save settings. Error: " + err.Error())
+		return
+	}
+
+	//Applied right away instead of waiting for the file check to notice
+	g.reloadSettings()
+}
+
+// setUserSetting changes one setting in the user settings file, keeping the rest of the file's settings
+func (g *Gopad) setUserSetting(name string, v interface{}) {
+
+	vals := settings.Values{}
+	for k, uv := range g.settingsFiles.user {
+		vals[k] = uv
+	}
+	vals[name] = v
+
+	if err := settings.WriteFile(g.settingsFiles.userPath, vals); err != nil {
+		g.triggerError("Failed to save settings. Error: " + err.Error())
+		return
+	}
+
+	g.reloadSettings()
+}
+
+// openSettingsFile opens the user settings file in an editor, writing the current user settings to it first if it doesn't exist yet
+func (g *Gopad) openSettingsFile() {
+
+	fPath := g.settingsFiles.userPath
+	if _, err := os.Stat(fPath); err != nil {
+		if err := settings.WriteFile(fPath, g.prefs.vals); err != nil {
+			g.triggerError("Failed to create settings file. Error: " + err.Error())
+			return
+		}
+	}
+
+	g.handleFileClick(fPath)
+	g.prefs.isOpen = false
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(text string) []string {
+
+	items := []string{}
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
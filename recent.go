@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/bloeys/nmage/logging"
+)
+
+const (
+	recentFileName = "recent.json"
+
+	// maxRecent is how many entries each recent list keeps
+	maxRecent = 10
+)
+
+// recentPaths are kept between runs, newest first. Locations are the folders recently used in the file dialog
+// and Folders the ones recently opened in the sidebar.
+type recentPaths struct {
+	Locations []string
+	Folders   []string
+}
+
+func recentPathsFile() (string, error) {
+
+	dir, err := appDataDir("")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, recentFileName), nil
+}
+
+// loadRecentPaths reads the recent paths. A missing or broken file just means there are none.
+func loadRecentPaths() recentPaths {
+
+	r := recentPaths{}
+	fPath, err := recentPathsFile()
+	if err != nil {
+		return r
+	}
+
+	data, err := os.ReadFile(fPath)
+	if err != nil {
+		return r
+	}
+
+	json.Unmarshal(data, &r)
+	return r
+}
+
+func (r *recentPaths) save() error {
+
+	fPath, err := recentPathsFile()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(fPath, data, 0600)
+}
+
+// addRecent moves (or adds) item to the front of list, dropping the oldest items beyond maxRecent
+func addRecent(list []string, item string) []string {
+
+	newList := make([]string, 0, len(list)+1)
+	newList = append(newList, item)
+	for _, l := range list {
+		if l != item && len(newList) < maxRecent {
+			newList = append(newList, l)
+		}
+	}
+
+	return newList
+}
+
+// rememberFolder adds dir to the recent sidebar folders. The file is read again first so the dialog's locations aren't lost.
+func (g *Gopad) rememberFolder(dir string) {
+
+	r := loadRecentPaths()
+	r.Folders = addRecent(r.Folders, dir)
+	g.recentFolders = r.Folders
+	if err := r.save(); err != nil {
+		logging.ErrLog.Println("Failed to save recent folders. Error: " + err.Error())
+	}
+}
@@ -0,0 +1,649 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bloeys/gopad/buffer"
+	"github.com/bloeys/gopad/charset"
+	"github.com/bloeys/gopad/settings"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+const (
+	// recoveryInterval is how often the session and the unsaved text of editors are written to disk
+	recoveryInterval = 5 * time.Second
+
+	sessionFileName = "session.json"
+	snapshotExt     = ".snapshot.json"
+
+	// diffContextLines is how many unchanged lines are shown around changes in the recovery window
+	diffContextLines = 3
+)
+
+// session is the state of the open editors, which is written regularly so that they can be brought back when
+// Gopad starts again after exiting or crashing. The unsaved text of every modified editor is in its own snapshot
+// file, which is only written again when the editor changes.
+//
+// @NOTE: All Gopad instances share one session, so the last one to write it wins
+type session struct {
+	//CleanExit is false while Gopad runs, so a session that has it false at start up is from a crash
+	CleanExit bool
+	Active    int
+	Tabs      []sessionTab
+}
+
+type sessionTab struct {
+	//FilePath is empty for scratch editors
+	FilePath string
+
+	Line     int
+	Col      int
+	StartPos float32
+	ScrollX  int
+
+	//Snapshot is the name of the file with the unsaved text of the editor, or empty if it has none
+	Snapshot string
+
+	//Pending are the names of the files with recovered text the user hasn't restored or discarded yet
+	Pending []string
+}
+
+// snapshot is the unsaved text of an editor
+type snapshot struct {
+	FilePath string
+
+	//DiskHash is the hash of the file the text is based on, to know if the file changed since
+	DiskHash string
+	Encoding string
+	Text     string
+}
+
+// recovery tracks writing the session, and the recovered texts that wait for the user to restore or discard them
+type recovery struct {
+	lastWrite   time.Time
+	lastSession []byte
+	lastErr     string
+
+	//writing is set while the session is written in the background, and gets the result when it's done
+	writing chan sessionWriteResult
+
+	pending  []pendingRecovery
+	selected int
+}
+
+type pendingRecovery struct {
+	//editorID is the editor the text belongs to, which has the file as it is on disk
+	editorID     int
+	snapshotName string
+	snap         snapshot
+
+	label string
+	diff  []buffer.DiffLine
+}
+
+func recoveryDir() (string, error) {
+	return appDataDir("recovery")
+}
+
+// checkRecovery writes the session if it is time to. The writing happens in the background, and its errors are
+// reported once it is done.
+func (g *Gopad) checkRecovery() {
+
+	if g.recovery.writing != nil {
+
+		select {
+		case res := <-g.recovery.writing:
+			g.recovery.writing = nil
+			g.reportRecoveryErr(g.finishSessionWrite(res))
+		default:
+			//Writes never overlap, so the next one waits for this one
+			return
+		}
+	}
+
+	if time.Since(g.recovery.lastWrite) < recoveryInterval {
+		return
+	}
+
+	g.recovery.lastWrite = time.Now()
+	w, err := g.prepareSessionWrite(false, true)
+	if err != nil {
+		g.reportRecoveryErr(err)
+		return
+	}
+
+	writing := make(chan sessionWriteResult, 1)
+	g.recovery.writing = writing
+	go func() {
+		writing <- w.write()
+	}()
+}
+
+func (g *Gopad) reportRecoveryErr(err error) {
+
+	//The same error every few seconds is only shown once
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+
+	if errMsg != "" && errMsg != g.recovery.lastErr {
+		g.triggerError("Failed to save unsaved changes for crash recovery. Error: " + errMsg)
+	}
+	g.recovery.lastErr = errMsg
+}
+
+// writeSession writes the session and, if keepUnsaved, a snapshot of every modified editor that changed since its last snapshot.
+// Snapshots no longer used are removed. Unlike checkRecovery it is done writing when it returns.
+func (g *Gopad) writeSession(cleanExit, keepUnsaved bool) error {
+
+	if g.recovery.writing != nil {
+		g.finishSessionWrite(<-g.recovery.writing)
+		g.recovery.writing = nil
+	}
+
+	w, err := g.prepareSessionWrite(cleanExit, keepUnsaved)
+	if err != nil {
+		return err
+	}
+
+	return g.finishSessionWrite(w.write())
+}
+
+// sessionWrite is what writing the session needs, taken on the UI thread so the writing itself can happen on another one
+type sessionWrite struct {
+	dir string
+
+	//session is nil if it didn't change since it was last written
+	session   []byte
+	snapshots []snapshotWrite
+
+	//used are the snapshot files that are still needed, and the others are removed
+	used map[string]bool
+}
+
+type snapshotWrite struct {
+	name string
+	//snap has everything but the text, which is turned into a string by the writing goroutine
+	snap snapshot
+	text *buffer.Snapshot
+}
+
+type sessionWriteResult struct {
+	//session is what was written as the session, or nil if it wasn't written
+	session []byte
+	//failed are the snapshots that couldn't be written
+	failed []string
+	err    error
+}
+
+// prepareSessionWrite gathers the session and the snapshots of the modified editors that changed since their last one.
+// Editors get a snapshot name the first time they need one.
+func (g *Gopad) prepareSessionWrite(cleanExit, keepUnsaved bool) (*sessionWrite, error) {
+
+	dir, err := recoveryDir()
+	if err != nil {
+		return nil, err
+	}
+
+	//Recovered text stays in the session until the user decides what happens to it
+	w := &sessionWrite{dir: dir, used: map[string]bool{}}
+	pending := map[int][]string{}
+	for _, p := range g.recovery.pending {
+		pending[p.editorID] = append(pending[p.editorID], p.snapshotName)
+		w.used[p.snapshotName] = true
+	}
+
+	s := session{CleanExit: cleanExit, Active: g.activeEditor}
+	for i := 0; i < len(g.editors); i++ {
+
+		e := &g.editors[i]
+		tab := sessionTab{
+			FilePath: e.FilePath,
+			Line:     e.Cursor.Line,
+			Col:      e.Cursor.Col,
+			StartPos: e.StartPos,
+			ScrollX:  e.ScrollX,
+			Pending:  pending[e.id],
+		}
+
+		if !e.IsModified() || !keepUnsaved {
+			e.snapshotName = ""
+			s.Tabs = append(s.Tabs, tab)
+			continue
+		}
+
+		if e.snapshotName == "" {
+			e.snapshotName = strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.Itoa(e.id) + snapshotExt
+			e.snapshotVersion = -1
+		}
+
+		//The version is updated now so editing during the write is noticed, and is reset if the write fails
+		if e.snapshotVersion != e.Buf.Version() {
+
+			w.snapshots = append(w.snapshots, snapshotWrite{
+				name: e.snapshotName,
+				snap: snapshot{
+					FilePath: e.FilePath,
+					DiskHash: e.diskHash,
+					Encoding: e.decodedAs.String(),
+				},
+				text: e.Buf.Snapshot(),
+			})
+
+			e.snapshotVersion = e.Buf.Version()
+		}
+
+		tab.Snapshot = e.snapshotName
+		w.used[e.snapshotName] = true
+		s.Tabs = append(s.Tabs, tab)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(data, g.recovery.lastSession) {
+		w.session = data
+	}
+
+	return w, nil
+}
+
+// write writes the snapshots then the session, and removes the snapshots no longer used. It doesn't touch
+// anything of the UI thread, so it can run on another goroutine.
+func (w *sessionWrite) write() (res sessionWriteResult) {
+
+	var errs []string
+	for _, sw := range w.snapshots {
+
+		sw.snap.Text = sw.text.String()
+		data, err := json.Marshal(sw.snap)
+		if err == nil {
+			err = writeFileAtomic(filepath.Join(w.dir, sw.name), data, 0600)
+		}
+
+		if err != nil {
+			res.failed = append(res.failed, sw.name)
+			errs = append(errs, sw.snap.FilePath+": "+err.Error())
+		}
+	}
+
+	if w.session != nil {
+
+		if err := writeFileAtomic(filepath.Join(w.dir, sessionFileName), w.session, 0600); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			res.session = w.session
+		}
+	}
+
+	//Snapshots are only removed once the session no longer points to them
+	if entries, err := os.ReadDir(w.dir); err == nil {
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), snapshotExt) && !w.used[entry.Name()] {
+				os.Remove(filepath.Join(w.dir, entry.Name()))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		res.err = errors.New(strings.Join(errs, "\n"))
+	}
+
+	return res
+}
+
+// finishSessionWrite records what a write did, so that what failed is written again next time
+func (g *Gopad) finishSessionWrite(res sessionWriteResult) error {
+
+	if res.session != nil {
+		g.recovery.lastSession = res.session
+	}
+
+	for _, name := range res.failed {
+		for i := 0; i < len(g.editors); i++ {
+			if g.editors[i].snapshotName == name {
+				g.editors[i].snapshotVersion = -1
+			}
+		}
+	}
+
+	return res.err
+}
+
+func readSnapshot(dir, name string) (*snapshot, error) {
+
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &snapshot{}
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+// restoreSession opens the editors of the last session. After a clean exit unsaved text is put back right away,
+// but after a crash (or if the file changed on disk since) it waits in the recovery window so the user can check it first.
+func (g *Gopad) restoreSession() {
+
+	dir, err := recoveryDir()
+	if err != nil {
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, sessionFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+
+	s := session{}
+	if err == nil {
+		err = json.Unmarshal(data, &s)
+	}
+
+	if err != nil {
+		g.triggerError("Failed to restore the last session. Error: " + err.Error())
+		return
+	}
+
+	editors := make([]Editor, 0, len(s.Tabs))
+	var errs []string
+	for _, tab := range s.Tabs {
+
+		var snap *snapshot
+		if tab.Snapshot != "" {
+			snap, err = readSnapshot(dir, tab.Snapshot)
+			if err != nil {
+				errs = append(errs, "Unsaved changes of '"+tab.FilePath+"' are lost: "+err.Error())
+			}
+		}
+
+		var pendingNames []string
+		var pendingSnaps []*snapshot
+		for _, name := range tab.Pending {
+
+			p, err := readSnapshot(dir, name)
+			if err != nil {
+				errs = append(errs, "Recovered changes of '"+tab.FilePath+"' are lost: "+err.Error())
+				continue
+			}
+
+			pendingNames = append(pendingNames, name)
+			pendingSnaps = append(pendingSnaps, p)
+		}
+
+		//A tab is opened the way its snapshot needs, and its recovered text is shown against that
+		openSnap := snap
+		if openSnap == nil && len(pendingSnaps) > 0 {
+			openSnap = pendingSnaps[0]
+		}
+
+		e, err := openSessionTab(tab, openSnap)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		if e == nil {
+			continue
+		}
+
+		if snap != nil {
+
+			if s.CleanExit && snap.DiskHash == e.diskHash {
+				e.SetText(snap.Text)
+				e.snapshotName = tab.Snapshot
+			} else {
+				g.recovery.pending = append(g.recovery.pending, newPendingRecovery(e, tab.Snapshot, snap))
+			}
+		}
+
+		//Text that was still waiting to be recovered keeps waiting, even after a clean exit
+		for j, p := range pendingSnaps {
+			g.recovery.pending = append(g.recovery.pending, newPendingRecovery(e, pendingNames[j], p))
+		}
+
+		e.SetCursor(tab.Line, tab.Col)
+		e.StartPos = tab.StartPos
+		e.ScrollX = tab.ScrollX
+
+		g.reportOpenProblems(e)
+		editors = append(editors, *e)
+	}
+
+	if len(errs) > 0 {
+		g.triggerError("Problems restoring the last session:\n" + strings.Join(errs, "\n"))
+	}
+
+	if len(editors) == 0 {
+		return
+	}
+
+	g.editors = editors
+	g.activeEditor = clampInt(s.Active, 0, len(g.editors)-1)
+	g.lastActiveEditor = g.activeEditor
+}
+
+// openSessionTab opens the editor of a tab. A file that no longer exists only gets an editor if it had unsaved text,
+// and otherwise nil is returned.
+func openSessionTab(tab sessionTab, snap *snapshot) (*Editor, error) {
+
+	if tab.FilePath == "" {
+		return NewScratchEditor(), nil
+	}
+
+	_, err := os.Stat(tab.FilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+
+		if snap == nil {
+			return nil, nil
+		}
+
+		e := NewScratchEditor()
+		e.FileName = filepath.Base(tab.FilePath)
+		e.FilePath = tab.FilePath
+		return e, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	//The snapshot is only meaningful against the file decoded the way it was then
+	if snap != nil {
+		if enc, ok := charset.ByName(snap.Encoding); ok {
+			return NewEditorWithEncoding(tab.FilePath, &enc)
+		}
+	}
+
+	return NewEditorWithEncoding(tab.FilePath, nil)
+}
+
+func newPendingRecovery(e *Editor, snapshotName string, snap *snapshot) pendingRecovery {
+
+	p := pendingRecovery{
+		editorID:     e.id,
+		snapshotName: snapshotName,
+		snap:         *snap,
+		label:        e.FileName,
+	}
+
+	if e.FilePath != "" {
+		p.label = e.FilePath
+	}
+
+	if snap.DiskHash != e.diskHash {
+		p.label += " (changed on disk since)"
+	}
+
+	p.diff = buffer.DiffLines(strings.Split(e.Buf.String(), "\n"), strings.Split(snap.Text, "\n"))
+	p.diff = trimDiffContext(p.diff, diffContextLines)
+	return p
+}
+
+// trimDiffContext replaces runs of unchanged lines that are more than context lines away from a change with one line saying how many were skipped
+func trimDiffContext(diff []buffer.DiffLine, context int) []buffer.DiffLine {
+
+	trimmed := make([]buffer.DiffLine, 0, len(diff))
+	for i := 0; i < len(diff); {
+
+		if diff[i].Op != buffer.DiffSame {
+			trimmed = append(trimmed, diff[i])
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(diff) && diff[end].Op == buffer.DiffSame {
+			end++
+		}
+
+		//Context is kept after the previous change and before the next one
+		keepStart, keepEnd := i+context, end-context
+		if i == 0 {
+			keepStart = i
+		}
+		if end == len(diff) {
+			keepEnd = end
+		}
+
+		if keepEnd-keepStart <= 1 {
+			trimmed = append(trimmed, diff[i:end]...)
+			i = end
+			continue
+		}
+
+		trimmed = append(trimmed, diff[i:keepStart]...)
+		trimmed = append(trimmed, buffer.DiffLine{Op: buffer.DiffSame, Text: "... " + strconv.Itoa(keepEnd-keepStart) + " unchanged lines ..."})
+		trimmed = append(trimmed, diff[keepEnd:end]...)
+		i = end
+	}
+
+	return trimmed
+}
+
+// saveSessionOnPanic writes the session when Gopad panics so the unsaved changes can be recovered, then panics again
+func (g *Gopad) saveSessionOnPanic() {
+
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	//The state might be broken, and a second panic here must not hide the first one
+	func() {
+		defer func() { recover() }()
+		g.writeSession(false, true)
+	}()
+
+	panic(r)
+}
+
+func (g *Gopad) drawRecovery() {
+
+	r := &g.recovery
+	if len(r.pending) == 0 {
+		return
+	}
+
+	//The window can't be closed without deciding what happens to every recovered text
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: g.winWidth * 0.7, Y: g.winHeight * 0.7}, imgui.ConditionFirstUseEver)
+	if !imgui.BeginV("Recover Unsaved Changes", nil, imgui.WindowFlagsNoCollapse) {
+		imgui.End()
+		return
+	}
+
+	imgui.TextWrapped("Gopad didn't exit cleanly, or these files changed since. Their unsaved changes are shown against the files as they are now.")
+
+	r.selected = clampInt(r.selected, 0, len(r.pending)-1)
+	listHeight := -imgui.FrameHeightWithSpacing()
+	imgui.BeginChildV("recoveryList", imgui.Vec2{X: g.winWidth * 0.2, Y: listHeight}, true, 0)
+	for i, p := range r.pending {
+		if imgui.SelectableV(p.label+"##"+strconv.Itoa(i), i == r.selected, 0, imgui.Vec2{}) {
+			r.selected = i
+		}
+	}
+	imgui.EndChild()
+
+	imgui.SameLine()
+	imgui.BeginChildV("recoveryDiff", imgui.Vec2{X: 0, Y: listHeight}, true, imgui.WindowFlagsHorizontalScrollbar)
+	for _, l := range r.pending[r.selected].diff {
+		drawDiffLine(l)
+	}
+	imgui.EndChild()
+
+	if imgui.Button("Restore") {
+		g.restorePending(r.selected)
+	}
+
+	imgui.SameLine()
+	if imgui.Button("Discard") {
+		r.pending = append(r.pending[:r.selected], r.pending[r.selected+1:]...)
+	}
+
+	imgui.SameLine()
+	if imgui.Button("Restore All") {
+		for len(r.pending) > 0 {
+			g.restorePending(0)
+		}
+	}
+
+	imgui.SameLine()
+	if imgui.Button("Discard All") {
+		r.pending = r.pending[:0]
+	}
+
+	imgui.End()
+}
+
+func drawDiffLine(l buffer.DiffLine) {
+
+	switch l.Op {
+	case buffer.DiffRemoved:
+		imgui.PushStyleColor(imgui.StyleColorText, settings.DiffRemovedColor)
+		imgui.Text("- " + l.Text)
+		imgui.PopStyleColor()
+	case buffer.DiffAdded:
+		imgui.PushStyleColor(imgui.StyleColorText, settings.DiffAddedColor)
+		imgui.Text("+ " + l.Text)
+		imgui.PopStyleColor()
+	default:
+		imgui.Text("  " + l.Text)
+	}
+}
+
+// restorePending puts the recovered text at index i back into its editor as an undoable change
+func (g *Gopad) restorePending(i int) {
+
+	p := g.recovery.pending[i]
+	g.recovery.pending = append(g.recovery.pending[:i], g.recovery.pending[i+1:]...)
+
+	for j := 0; j < len(g.editors); j++ {
+
+		e := &g.editors[j]
+		if e.id != p.editorID {
+			continue
+		}
+
+		e.SetText(p.snap.Text)
+		e.snapshotName = p.snapshotName
+		g.activeEditor = j
+		return
+	}
+
+	g.triggerError("Can't restore the changes of '" + p.label + "' because its tab was closed")
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bloeys/gopad/settings"
+)
+
+const (
+	// newFilePerm is the mode of files that didn't exist before being saved, before the umask
+	newFilePerm os.FileMode = 0666
+
+	backupTimeFormat = "20060102-150405"
+)
+
+// saveFile replaces the contents of fPath with data so that a crash at any point leaves either the old or the new file.
+// The data goes to a temp file in the same folder that is synced then renamed over the file. Symlinks are kept and
+// their target written, and the mode and owner of the file are kept. A backup is made first if the settings ask for one.
+//
+// If the file had to be written in place instead (see pendingSave.ownerErr) then notice says so, to be shown to the user.
+func saveFile(fPath string, data []byte) (notice string, err error) {
+
+	ps, err := prepareSave(fPath, data)
+	if err != nil {
+		return "", err
+	}
+
+	err = ps.commit()
+	if err != nil {
+		return "", err
+	}
+
+	return ps.notice(), nil
+}
+
+// keepOwner is copyOwner, and is only replaced by tests
+var keepOwner = copyOwner
+
+// pendingSave is a save that is ready to be put in place by commit.
+// Saving several files in two steps means a failure to prepare one of them leaves all of them untouched.
+type pendingSave struct {
+	target string
+	data   []byte
+
+	//tmpPath holds the new contents, unless the file is new or has to be written in place
+	tmpPath string
+	isNew   bool
+
+	//ownerErr is why the owner of the file couldn't be given to the temp file, in which case the file is written in place
+	ownerErr error
+}
+
+// prepareSave does everything saveFile does short of replacing the file: it backs up the file and writes data to a temp file
+func prepareSave(fPath string, data []byte) (*pendingSave, error) {
+
+	target, err := resolveSymlinks(fPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ps := &pendingSave{target: target, data: data}
+	info, err := os.Stat(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		ps.isNew = true
+		return ps, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	//A rename replaces a file even when it is read-only, so that is checked first
+	f, err := os.OpenFile(target, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, describeSaveError(target, err)
+	}
+	f.Close()
+
+	if err := backupFile(target, info); err != nil {
+		return nil, errors.New("failed to make a backup, so the file wasn't saved. Error: " + err.Error())
+	}
+
+	perm := info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+	tmpPath, err := writeTempFile(target, data, perm)
+	if err != nil {
+		return nil, describeSaveError(target, err)
+	}
+
+	//A file whose owner can't be kept (e.g. another user's file we can write to) is written in place instead,
+	//since keeping the owner matters more than an atomic write, and there is a backup if one was asked for.
+	//@NOTE: Hard links are also broken by the rename
+	if err := keepOwner(tmpPath, info); err != nil {
+		os.Remove(tmpPath)
+		ps.ownerErr = err
+		return ps, nil
+	}
+
+	//Changing the owner can clear the setuid and setgid bits
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	ps.tmpPath = tmpPath
+	return ps, nil
+}
+
+// commit puts the new contents in place
+func (ps *pendingSave) commit() error {
+
+	if ps.isNew {
+		return writeNewFile(ps.target, ps.data)
+	}
+
+	if ps.tmpPath == "" {
+		return writeInPlace(ps.target, ps.data)
+	}
+
+	err := os.Rename(ps.tmpPath, ps.target)
+	if err != nil {
+		os.Remove(ps.tmpPath)
+		return describeSaveError(ps.target, err)
+	}
+
+	return syncDir(filepath.Dir(ps.target))
+}
+
+// notice tells the user the file was written in place, which a crash could leave half written, or returns "" if it wasn't
+func (ps *pendingSave) notice() string {
+
+	if ps.isNew || ps.ownerErr == nil {
+		return ""
+	}
+
+	return "'" + ps.target + "' was saved by overwriting it in place, because a new copy of it couldn't be given its owner and group. " +
+		"A crash while saving could leave it partly written. Error: " + ps.ownerErr.Error()
+}
+
+// resolveSymlinks returns the file fPath finally points to, which may not exist yet
+func resolveSymlinks(fPath string) (string, error) {
+
+	for i := 0; i < 255; i++ {
+
+		info, err := os.Lstat(fPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fPath, nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		if info.Mode()&fs.ModeSymlink == 0 {
+			return fPath, nil
+		}
+
+		link, err := os.Readlink(fPath)
+		if err != nil {
+			return "", err
+		}
+
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(fPath), link)
+		}
+		fPath = link
+	}
+
+	return "", errors.New("too many levels of symbolic links in '" + fPath + "'")
+}
+
+func writeNewFile(fPath string, data []byte) error {
+
+	//O_EXCL so that a file created since we checked isn't overwritten without a backup
+	f, err := os.OpenFile(fPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, newFilePerm)
+	if err != nil {
+		return describeSaveError(fPath, err)
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(fPath)
+		return err
+	}
+
+	return syncDir(filepath.Dir(fPath))
+}
+
+func writeInPlace(fPath string, data []byte) error {
+
+	f, err := os.OpenFile(fPath, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return describeSaveError(fPath, err)
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// describeSaveError turns permission errors into a message that says what is read-only
+func describeSaveError(fPath string, err error) error {
+
+	if !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+
+	f, openErr := os.OpenFile(fPath, os.O_WRONLY, 0)
+	if openErr == nil {
+		f.Close()
+	} else if errors.Is(openErr, fs.ErrPermission) {
+		return errors.New("'" + fPath + "' is read-only or you don't have permission to write it")
+	}
+
+	return errors.New("the folder '" + filepath.Dir(fPath) + "' is read-only or you don't have permission to create files in it, " +
+		"which saving needs. Error: " + err.Error())
+}
+
+// backupFile copies fPath to a backup as settings.Backup asks, removing old timestamped backups beyond settings.BackupCount
+func backupFile(fPath string, info fs.FileInfo) error {
+
+	var backupPath string
+	switch settings.Backup {
+	case "tilde":
+		backupPath = fPath + "~"
+	case "timestamped":
+		backupPath = fPath + "." + time.Now().Format(backupTimeFormat) + "~"
+	default:
+		return nil
+	}
+
+	data, err := os.ReadFile(fPath)
+	if err != nil {
+		return err
+	}
+
+	err = writeFileAtomic(backupPath, data, info.Mode().Perm())
+	if err != nil {
+		return describeSaveError(backupPath, err)
+	}
+
+	if settings.Backup == "timestamped" {
+		pruneBackups(fPath, settings.BackupCount)
+	}
+
+	return nil
+}
+
+// pruneBackups removes all but the newest keep timestamped backups of fPath
+func pruneBackups(fPath string, keep int) {
+
+	entries, err := os.ReadDir(filepath.Dir(fPath))
+	if err != nil {
+		return
+	}
+
+	prefix := filepath.Base(fPath) + "."
+	backups := []string{}
+	for _, e := range entries {
+
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, "~") {
+			continue
+		}
+
+		stamp := name[len(prefix) : len(name)-1]
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, name)
+		}
+	}
+
+	//Timestamps sort by time, so the oldest come first
+	sort.Strings(backups)
+	for i := 0; i < len(backups)-keep; i++ {
+		os.Remove(filepath.Join(filepath.Dir(fPath), backups[i]))
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveFileOwnerNotKept(t *testing.T) {
+
+	fPath := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(fPath, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.Stat(fPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldKeepOwner := keepOwner
+	defer func() { keepOwner = oldKeepOwner }()
+	keepOwner = func(string, fs.FileInfo) error { return errors.New("operation not permitted") }
+
+	notice, err := saveFile(fPath, []byte("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(notice, fPath) || !strings.Contains(notice, "operation not permitted") {
+		t.Errorf("expected a notice naming the file and the error, but got %q", notice)
+	}
+
+	data, err := os.ReadFile(fPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "new" {
+		t.Errorf("expected 'new' but got %q", data)
+	}
+
+	//Written in place means it is still the same file, and no temp file is left behind
+	after, err := os.Stat(fPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !os.SameFile(before, after) {
+		t.Error("expected the file to be written in place")
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(fPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("expected only the saved file in its folder, but found %d entries", len(entries))
+	}
+}
+
+func TestSaveFileAtomic(t *testing.T) {
+
+	fPath := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(fPath, []byte("old"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.Stat(fPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notice, err := saveFile(fPath, []byte("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if notice != "" {
+		t.Errorf("expected no notice but got %q", notice)
+	}
+
+	after, err := os.Stat(fPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//The temp file was renamed over the old one, keeping its mode
+	if os.SameFile(before, after) {
+		t.Error("expected the file to be replaced by a renamed temp file")
+	}
+
+	if after.Mode().Perm() != 0640 {
+		t.Errorf("expected mode 0640 but got %v", after.Mode().Perm())
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// copyOwner gives fPath the owner and group of the file info came from
+func copyOwner(fPath string, info fs.FileInfo) error {
+
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	return os.Chown(fPath, int(st.Uid), int(st.Gid))
+}
+
+// syncDir syncs a folder so that a rename in it is on disk
+func syncDir(dir string) error {
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"io/fs"
+)
+
+// copyOwner does nothing on Windows, where new files get their permissions from the folder like the old file did
+func copyOwner(fPath string, info fs.FileInfo) error {
+	return nil
+}
+
+// syncDir does nothing on Windows, which can't sync folders and doesn't need to for a rename to be on disk
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+)
+
+// ignorePattern is one line of a .gitignore file
+type ignorePattern struct {
+	//segments is the pattern split on '/'. Unanchored patterns have a single segment that is matched against file names.
+	segments []string
+	anchored bool
+	negate   bool
+	dirOnly  bool
+}
+
+// ignoreList is a list of gitignore style patterns, where the last pattern that matches a path decides if it's ignored
+type ignoreList struct {
+	patterns []ignorePattern
+}
+
+// match returns whether relPath (relative to the directory the list applies to, and using '/') is ignored.
+// decided is false if no pattern matched it at all.
+func (l *ignoreList) match(relPath string, isDir bool) (ignored, decided bool) {
+
+	segments := strings.Split(relPath, "/")
+	for i := len(l.patterns) - 1; i >= 0; i-- {
+
+		p := &l.patterns[i]
+		if p.dirOnly && !isDir {
+			continue
+		}
+
+		var matched bool
+		if p.anchored {
+			matched = matchSegments(p.segments, segments)
+		} else {
+			matched, _ = path.Match(p.segments[0], segments[len(segments)-1])
+		}
+
+		if matched {
+			return !p.negate, true
+		}
+	}
+
+	return false, false
+}
+
+func (l *ignoreList) add(pattern string) {
+
+	pattern = strings.TrimRight(pattern, " \t\r")
+	if pattern == "" || pattern[0] == '#' {
+		return
+	}
+
+	p := ignorePattern{}
+	if pattern[0] == '!' {
+		p.negate = true
+		pattern = pattern[1:]
+	} else if pattern[0] == '\\' {
+		//Escapes a leading '#' or '!'
+		pattern = pattern[1:]
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	//A slash anywhere but the end ties the pattern to the directory of the .gitignore
+	if strings.Contains(pattern, "/") {
+		p.anchored = true
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+
+	if pattern == "" {
+		return
+	}
+
+	p.segments = strings.Split(pattern, "/")
+	l.patterns = append(l.patterns, p)
+}
+
+// matchSegments matches path segments against pattern segments, where a '**' pattern segment matches zero or more path segments
+func matchSegments(pattern, segments []string) bool {
+
+	for len(pattern) > 0 {
+
+		if pattern[0] == "**" {
+
+			for skip := 0; skip <= len(segments); skip++ {
+				if matchSegments(pattern[1:], segments[skip:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+
+		pattern = pattern[1:]
+		segments = segments[1:]
+	}
+
+	return len(segments) == 0
+}
+
+func newIgnoreList(patterns []string) *ignoreList {
+
+	l := &ignoreList{}
+	for _, p := range patterns {
+		l.add(p)
+	}
+
+	return l
+}
+
+// loadIgnoreFile reads a .gitignore file. A missing file gives a nil list.
+func loadIgnoreFile(fPath string) *ignoreList {
+
+	f, err := os.Open(fPath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	l := &ignoreList{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l.add(scanner.Text())
+	}
+
+	return l
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"crypto/sha256"
+	"errors"
+	"strconv"
+
+	"github.com/bloeys/gopad/buffer"
+	"github.com/bloeys/gopad/charset"
+)
+
+// ErrChanged means a file isn't the same as when it was searched, so its matches no longer line up with it
+var ErrChanged = errors.New("the file changed since it was searched. Search again to replace in it")
+
+// ReplaceFile is like Replace but takes the bytes of the file res was found in, which are decoded and encoded back
+// in the encoding the file was searched in. It fails if data isn't what was searched, if data has bytes that
+// aren't valid in the encoding, or if the replacements have chars the encoding can't represent.
+func ReplaceFile(data []byte, res *FileResult, finder *buffer.Finder, template string, keep func(line, i int) bool) (out []byte, count int, err error) {
+
+	if sha256.Sum256(data) != res.Hash {
+		return nil, 0, ErrChanged
+	}
+
+	text, lossless := charset.Decode(data, res.Encoding)
+	if !lossless {
+		return nil, 0, errors.New("the file has bytes that aren't valid " + res.Encoding.String() + ", which replacing would lose")
+	}
+
+	newText, count := Replace(text, finder, template, keep)
+	if count == 0 {
+		return data, 0, nil
+	}
+
+	out, unencodable := charset.Encode(newText, res.Encoding)
+	if unencodable > 0 {
+		return nil, 0, errors.New(strconv.Itoa(unencodable) + " chars of the replacements can't be written in " + res.Encoding.String())
+	}
+
+	return out, count, nil
+}
+
+// Replace returns data (UTF-8 text) with the matches of finder replaced by template. Lines are handled the same way as when searching,
+// so the LineMatch results of a search line up with what is replaced here.
+//
+// If keep isn't nil then only the matches it returns true for are replaced, where line is the zero based line
+// number and i is the index of the match in that line.
+func Replace(data []byte, finder *buffer.Finder, template string, keep func(line, i int) bool) (out []byte, count int) {
+
+	lines, ends := splitLines(data)
+	out = make([]byte, 0, len(data))
+	for i := 0; i < len(lines); i++ {
+
+		line := lines[i]
+		var lineKeep func(int) bool
+		if keep != nil {
+			lineNum := i
+			lineKeep = func(matchIndex int) bool { return keep(lineNum, matchIndex) }
+		}
+
+		newLine, n := finder.ReplaceLine(line, template, lineKeep)
+		out = append(out, newLine...)
+		out = append(out, ends[i]...)
+		count += n
+	}
+
+	return out, count
+}
@@ -0,0 +1,92 @@
+package search
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bloeys/gopad/buffer"
+	"github.com/bloeys/gopad/charset"
+)
+
+func TestReplaceFile(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		enc      charset.Encoding
+		text     string
+		template string
+
+		//expect is the text after replacing, or empty if replacing should fail
+		expect string
+	}{
+		{"utf-8", charset.UTF8, "café\r\ncafé", "tea", "tea\r\ntea"},
+		{"mixed line endings", charset.UTF8, "café\rcafé\r\ncafé\n", "tea", "tea\rtea\r\ntea\n"},
+		{"utf-16 le with bom", charset.UTF16LEBOM, "a café\nb", "thé", "a thé\nb"},
+		{"utf-16 be", charset.UTF16BE, "café café", "x", "x x"},
+		{"latin-1", charset.Latin1, "un café", "thé", "un thé"},
+		{"latin-1 can't hold the replacement", charset.Latin1, "un café", "茶", ""},
+	}
+
+	finder, err := buffer.NewFinder(buffer.FindOptions{Query: "café"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+
+		data, _ := charset.Encode([]byte(tt.text), tt.enc)
+		fPath := filepath.Join(t.TempDir(), "file.txt")
+		if err := os.WriteFile(fPath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		res, ok := searchFile(fPath, finder, &Options{})
+		if !ok {
+			t.Errorf("%s: expected matches", tt.name)
+			continue
+		}
+
+		out, _, err := ReplaceFile(data, &res, finder, tt.template, nil)
+		if tt.expect == "" {
+			if err == nil {
+				t.Errorf("%s: expected replacing to fail", tt.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		expect, _ := charset.Encode([]byte(tt.expect), tt.enc)
+		if !bytes.Equal(out, expect) {
+			t.Errorf("%s: expected %q but got %q", tt.name, expect, out)
+		}
+	}
+}
+
+func TestReplaceFileChanged(t *testing.T) {
+
+	finder, err := buffer.NewFinder(buffer.FindOptions{Query: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fPath := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(fPath, []byte("a x\nb x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, ok := searchFile(fPath, finder, &Options{})
+	if !ok {
+		t.Fatal("expected matches")
+	}
+
+	//A line added at the start moves every match down a line
+	if _, _, err := ReplaceFile([]byte("new\na x\nb x"), &res, finder, "y", nil); err != ErrChanged {
+		t.Fatalf("expected ErrChanged but got %v", err)
+	}
+}
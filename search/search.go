@@ -0,0 +1,339 @@
+// Package search finds text in all the files under a directory
+package search
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/bloeys/gopad/buffer"
+	"github.com/bloeys/gopad/charset"
+)
+
+const (
+	// binaryCheckLen is how many bytes at the start of a file are checked for a NUL to decide that it's binary
+	binaryCheckLen = 8000
+)
+
+// Options describes what to search for and where
+type Options struct {
+	Root string
+	Find buffer.FindOptions
+
+	//Exclude is a list of gitignore style patterns (relative to Root) for files and folders to skip, on top of any .gitignore files
+	Exclude []string
+
+	//Files bigger than MaxFileSize bytes are skipped. Zero means no limit.
+	MaxFileSize int64
+
+	//ContextLines is how many lines before and after each match are included with it
+	ContextLines int
+
+	//Workers is how many files are searched at the same time. Zero means one per CPU.
+	Workers int
+
+	//Open has the text of files that are open in editors by cleaned path, which is searched instead of what is on disk
+	//so that unsaved changes are found too
+	Open map[string]*buffer.Snapshot
+}
+
+// LineMatch is a match in a line of a file
+type LineMatch struct {
+	//Line is the zero based line number
+	Line int
+
+	//Start and End are the byte offsets of the match inside the line
+	Start int
+	End   int
+	//Index is the index of the match among the matches of its line
+	Index int
+
+	Text   string
+	Before []string
+	After  []string
+}
+
+// FileResult holds all the matches in one file.
+// Encoding is what the file was decoded as, and Hash the hash of its bytes, which tells if it changed since it was searched.
+// If FromEditor is true the matches are from the text of the open editor (see Options.Open), and Encoding and Hash are unset.
+type FileResult struct {
+	Path    string
+	Matches []LineMatch
+
+	Encoding   charset.Encoding
+	Hash       [sha256.Size]byte
+	FromEditor bool
+}
+
+// Search is a search running in the background. Results are sent as each file is done, and
+// the channel is closed once everything was searched or the search was cancelled.
+type Search struct {
+	Results <-chan FileResult
+
+	cancel context.CancelFunc
+}
+
+// errCancelled stops the directory walk early
+var errCancelled = errors.New("search cancelled")
+
+// Cancel stops the search. No more results are sent after it returns, but the ones already sent might still be in the channel.
+func (s *Search) Cancel() {
+	s.cancel()
+}
+
+// Start starts searching. The only errors are invalid find options.
+func Start(opts Options) (*Search, error) {
+
+	finder, err := buffer.NewFinder(opts.Find)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan FileResult, 64)
+	paths := make(chan string, 256)
+
+	go func() {
+		defer close(paths)
+		walk(ctx, &opts, paths)
+	}()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+
+		go func() {
+
+			defer wg.Done()
+			for fPath := range paths {
+
+				if ctx.Err() != nil {
+					return
+				}
+
+				res, ok := searchFile(fPath, finder, &opts)
+				if !ok {
+					continue
+				}
+
+				select {
+				case results <- res:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return &Search{Results: results, cancel: cancel}, nil
+}
+
+// walk sends the path of every file under the root that isn't ignored
+func walk(ctx context.Context, opts *Options, paths chan<- string) {
+
+	exclude := newIgnoreList(append([]string{".git/"}, opts.Exclude...))
+
+	//Each folder's .gitignore applies to everything under it
+	ignoreLists := map[string]*ignoreList{}
+	if l := loadIgnoreFile(filepath.Join(opts.Root, ".gitignore")); l != nil {
+		ignoreLists[opts.Root] = l
+	}
+
+	filepath.WalkDir(opts.Root, func(fPath string, d fs.DirEntry, err error) error {
+
+		if ctx.Err() != nil {
+			return errCancelled
+		}
+
+		if err != nil || fPath == opts.Root {
+			return nil
+		}
+
+		if isIgnored(opts.Root, fPath, d.IsDir(), exclude, ignoreLists) {
+
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if d.IsDir() {
+
+			if l := loadIgnoreFile(filepath.Join(fPath, ".gitignore")); l != nil {
+				ignoreLists[fPath] = l
+			}
+
+			return nil
+		}
+
+		if !d.Type().IsRegular() && d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		select {
+		case paths <- fPath:
+			return nil
+		case <-ctx.Done():
+			return errCancelled
+		}
+	})
+}
+
+func isIgnored(root, fPath string, isDir bool, exclude *ignoreList, ignoreLists map[string]*ignoreList) bool {
+
+	rel, _ := filepath.Rel(root, fPath)
+	if ignored, decided := exclude.match(filepath.ToSlash(rel), isDir); decided {
+		return ignored
+	}
+
+	//Deeper .gitignore files override shallower ones, so the deepest one with an opinion decides
+	for dir := filepath.Dir(fPath); ; dir = filepath.Dir(dir) {
+
+		if l := ignoreLists[dir]; l != nil {
+
+			rel, _ := filepath.Rel(dir, fPath)
+			if ignored, decided := l.match(filepath.ToSlash(rel), isDir); decided {
+				return ignored
+			}
+		}
+
+		if dir == root || len(dir) < len(root) {
+			return false
+		}
+	}
+}
+
+// searchFile returns the matches in the file at fPath. ok is false if the file can't be read, is binary or has no matches.
+func searchFile(fPath string, finder *buffer.Finder, opts *Options) (res FileResult, ok bool) {
+
+	var text []byte
+	if snap := opts.Open[filepath.Clean(fPath)]; snap != nil {
+
+		if opts.MaxFileSize > 0 && int64(snap.Len()) > opts.MaxFileSize {
+			return res, false
+		}
+
+		text = snap.Bytes()
+		res.FromEditor = true
+	} else {
+
+		if opts.MaxFileSize > 0 {
+			if info, err := os.Stat(fPath); err != nil || info.Size() > opts.MaxFileSize {
+				return res, false
+			}
+		}
+
+		data, err := os.ReadFile(fPath)
+		if err != nil {
+			return res, false
+		}
+
+		//UTF-16 has lots of zero bytes, so binary files are only told apart once decoded
+		res.Encoding = charset.Detect(data)
+		res.Hash = sha256.Sum256(data)
+		text, _ = charset.Decode(data, res.Encoding)
+	}
+
+	if bytes.IndexByte(text[:minInt(len(text), binaryCheckLen)], 0) != -1 {
+		return res, false
+	}
+
+	lines, _ := splitLines(text)
+	var matches []buffer.Match
+	for i := 0; i < len(lines); i++ {
+
+		line := lines[i]
+		matches = finder.FindInLine(line, 0, matches[:0])
+		for j, m := range matches {
+			res.Matches = append(res.Matches, LineMatch{
+				Line:   i,
+				Start:  m.Start,
+				End:    m.End,
+				Index:  j,
+				Text:   string(line),
+				Before: contextLines(lines, i-opts.ContextLines, i),
+				After:  contextLines(lines, i+1, i+1+opts.ContextLines),
+			})
+		}
+	}
+
+	res.Path = fPath
+	return res, len(res.Matches) > 0
+}
+
+// contextLines returns the lines [start, end) clamped to the file
+func contextLines(lines [][]byte, start, end int) []string {
+
+	start = maxInt(start, 0)
+	end = minInt(end, len(lines))
+	if start >= end {
+		return nil
+	}
+
+	out := make([]string, 0, end-start)
+	for i := start; i < end; i++ {
+		out = append(out, string(lines[i]))
+	}
+
+	return out
+}
+
+// splitLines splits text into lines the same way documents do, at "\r\n", '\r' or '\n'.
+// ends[i] is the line ending after lines[i], which is empty for the last line.
+func splitLines(text []byte) (lines, ends [][]byte) {
+
+	for {
+
+		i := bytes.IndexAny(text, "\r\n")
+		if i == -1 {
+			break
+		}
+
+		end := i + 1
+		if text[i] == '\r' && end < len(text) && text[end] == '\n' {
+			end++
+		}
+
+		lines = append(lines, text[:i])
+		ends = append(ends, text[i:end])
+		text = text[end:]
+	}
+
+	lines = append(lines, text)
+	ends = append(ends, nil)
+	return lines, ends
+}
+
+func minInt(x, y int) int {
+
+	if x < y {
+		return x
+	}
+
+	return y
+}
+
+func maxInt(x, y int) int {
+
+	if x > y {
+		return x
+	}
+
+	return y
+}
@@ -0,0 +1,72 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bloeys/gopad/buffer"
+)
+
+func TestSearchFileLines(t *testing.T) {
+
+	finder, err := buffer.NewFinder(buffer.FindOptions{Query: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//A lone '\r' ends a line like it does in documents
+	fPath := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(fPath, []byte("a x\rb\r\nx c\n\rx"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, ok := searchFile(fPath, finder, &Options{ContextLines: 1})
+	if !ok {
+		t.Fatal("expected matches")
+	}
+
+	expect := []LineMatch{
+		{Line: 0, Start: 2, End: 3, Text: "a x", After: []string{"b"}},
+		{Line: 2, Start: 0, End: 1, Text: "x c", Before: []string{"b"}, After: []string{""}},
+		{Line: 4, Start: 0, End: 1, Text: "x", Before: []string{""}},
+	}
+
+	if len(res.Matches) != len(expect) {
+		t.Fatalf("expected %d matches but got %+v", len(expect), res.Matches)
+	}
+
+	for i, m := range res.Matches {
+
+		e := expect[i]
+		if m.Line != e.Line || m.Start != e.Start || m.End != e.End || m.Text != e.Text ||
+			len(m.Before) != len(e.Before) || len(m.After) != len(e.After) ||
+			(len(e.Before) > 0 && m.Before[0] != e.Before[0]) || (len(e.After) > 0 && m.After[0] != e.After[0]) {
+			t.Errorf("match %d: expected %+v but got %+v", i, e, m)
+		}
+	}
+}
+
+func TestSearchOpenFiles(t *testing.T) {
+
+	finder, err := buffer.NewFinder(buffer.FindOptions{Query: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fPath := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(fPath, []byte("nothing here"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	//The match only exists in the unsaved text of the editor
+	opts := &Options{Open: map[string]*buffer.Snapshot{fPath: buffer.New([]byte("a\nunsaved x")).Snapshot()}}
+	res, ok := searchFile(fPath, finder, opts)
+	if !ok || !res.FromEditor || len(res.Matches) != 1 || res.Matches[0].Line != 1 {
+		t.Fatalf("expected one match on line 1 of the open file but got %+v", res)
+	}
+
+	if _, ok := searchFile(fPath, finder, &Options{}); ok {
+		t.Fatal("expected no matches on disk")
+	}
+}
@@ -1,57 +1,465 @@
 package main
 
 import (
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/bloeys/gopad/settings"
+	"github.com/bloeys/gopad/syntax"
+	"github.com/bloeys/nmage/input"
 	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/veandco/go-sdl2/sdl"
 )
 
-func selectFolder(startDir string, winWidth float32, winHeight float32) (path string, done bool) {
+type fileDialogMode int
+
+const (
+	fileDialogOpen fileDialogMode = iota
+	fileDialogSave
+	fileDialogFolder
+)
+
+// fileFilter limits the files shown in the file dialog to some extensions. No extensions means all files.
+type fileFilter struct {
+	name       string
+	extensions []string
+}
+
+// fileDialog picks a file to open or save, or a folder. The path can be typed (with Tab completing it)
+// or picked from the list of the current folder.
+type fileDialog struct {
+	isOpen bool
+	mode   fileDialogMode
+	title  string
+
+	//onDone is called with the picked path once the dialog is accepted
+	onDone func(path string)
+
+	//dir is the folder being shown and pathText is what is typed, which is relative to dir unless absolute
+	dir      string
+	pathText string
+
+	entries    []fs.DirEntry
+	entriesErr error
+
+	filters    []fileFilter
+	filter     int
+	showHidden bool
+
+	//confirmOverwrite is the existing file a save waits on the user to agree to replace
+	confirmOverwrite string
+	errMsg           string
+
+	recent recentPaths
+}
+
+// openFileDialog shows the file dialog in startDir. When the user picks a path, onDone is called with it.
+func (g *Gopad) openFileDialog(mode fileDialogMode, title, startDir, fileName string, onDone func(path string)) {
 
 	if strings.TrimSpace(startDir) == "" {
-		var err error
-		startDir, err = os.UserHomeDir()
-		if err != nil {
-			panic(err.Error())
+		startDir = g.CurrDir
+	}
+
+	fd := &g.fileDialog
+	*fd = fileDialog{
+		isOpen:     true,
+		mode:       mode,
+		title:      title,
+		onDone:     onDone,
+		pathText:   fileName,
+		filters:    fileFilters(),
+		showHidden: fd.showHidden,
+		recent:     loadRecentPaths(),
+	}
+
+	fd.setDir(startDir)
+}
+
+// fileFilters returns a filter for every known language, after the one that shows all files
+func fileFilters() []fileFilter {
+
+	filters := []fileFilter{{name: "All Files"}}
+	for _, lang := range syntax.Languages() {
+
+		if len(lang.Extensions) == 0 {
+			continue
+		}
+
+		filters = append(filters, fileFilter{
+			name:       lang.Name + " (*" + strings.Join(lang.Extensions, ", *") + ")",
+			extensions: lang.Extensions,
+		})
+	}
+
+	return filters
+}
+
+func (fd *fileDialog) setDir(dir string) {
+
+	fd.dir = filepath.Clean(dir)
+	fd.errMsg = ""
+	fd.confirmOverwrite = ""
+	fd.entries, fd.entriesErr = os.ReadDir(fd.dir)
+
+	//Folders come first
+	sort.SliceStable(fd.entries, func(i, j int) bool {
+		return fd.entries[i].IsDir() && !fd.entries[j].IsDir()
+	})
+}
+
+// isShown returns true if the entry passes the hidden files and extension filters
+func (fd *fileDialog) isShown(entry fs.DirEntry) bool {
+
+	if !fd.showHidden && isHidden(entry.Name()) {
+		return false
+	}
+
+	if entry.IsDir() {
+		return true
+	}
+
+	if fd.mode == fileDialogFolder {
+		return false
+	}
+
+	exts := fd.filters[fd.filter].extensions
+	if len(exts) == 0 {
+		return true
+	}
+
+	ext := strings.ToLower(filepath.Ext(entry.Name()))
+	for _, e := range exts {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHidden returns true for dot files.
+// @NOTE: The hidden attribute of Windows files isn't checked
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// resolvePath turns typed text into an absolute path, with '~' being the home folder
+func (fd *fileDialog) resolvePath(text string) string {
+
+	text = strings.TrimSpace(text)
+	if text == "~" || strings.HasPrefix(text, "~/") || strings.HasPrefix(text, "~"+string(filepath.Separator)) {
+		if home, err := os.UserHomeDir(); err == nil {
+			text = home + text[1:]
+		}
+	}
+
+	if !filepath.IsAbs(text) {
+		text = filepath.Join(fd.dir, text)
+	}
+
+	return filepath.Clean(text)
+}
+
+func (g *Gopad) drawFileDialog() {
+
+	fd := &g.fileDialog
+	if !fd.isOpen {
+		return
+	}
+
+	imgui.OpenPopup("fileDialog")
+	imgui.SetNextWindowPosV(imgui.Vec2{X: g.winWidth * 0.5, Y: g.winHeight * 0.5}, imgui.ConditionAppearing, imgui.Vec2{X: 0.5, Y: 0.5})
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: g.winWidth * 0.6, Y: g.winHeight * 0.7}, imgui.ConditionAppearing)
+	if !imgui.BeginPopupModalV("fileDialog", nil, imgui.WindowFlagsNoCollapse|imgui.WindowFlagsNoTitleBar) {
+		return
+	}
+
+	imgui.Text(fd.title)
+	imgui.Separator()
+
+	if imgui.Button("Up") {
+		fd.setDir(filepath.Dir(fd.dir))
+	}
+
+	imgui.SameLine()
+	imgui.Text(fd.dir)
+
+	//The bottom rows are the path, the filters and the buttons
+	listHeight := -3 * imgui.FrameHeightWithSpacing()
+	if fd.errMsg != "" || fd.confirmOverwrite != "" {
+		listHeight -= imgui.FrameHeightWithSpacing()
+	}
+
+	imgui.BeginChildV("fileDialogPlaces", imgui.Vec2{X: g.winWidth * 0.12, Y: listHeight}, true, 0)
+	fd.drawPlaces(g.CurrDir)
+	imgui.EndChild()
+
+	imgui.SameLine()
+	imgui.BeginChildV("fileDialogEntries", imgui.Vec2{X: 0, Y: listHeight}, true, 0)
+	g.drawFileDialogEntries()
+	imgui.EndChild()
+
+	imgui.PushItemWidth(-1)
+	accept := imgui.InputTextV("##fileDialogPath", &fd.pathText, imgui.InputTextFlagsEnterReturnsTrue|imgui.InputTextFlagsCallbackCompletion, fd.completePath)
+	imgui.PopItemWidth()
+
+	if fd.mode != fileDialogFolder {
+
+		imgui.PushItemWidth(g.winWidth * 0.25)
+		if imgui.BeginCombo("##fileDialogFilter", fd.filters[fd.filter].name) {
+			for i, f := range fd.filters {
+				if imgui.SelectableV(f.name, i == fd.filter, 0, imgui.Vec2{}) {
+					fd.filter = i
+				}
+			}
+			imgui.EndCombo()
+		}
+		imgui.PopItemWidth()
+		imgui.SameLine()
+	}
+
+	imgui.Checkbox("Show hidden files", &fd.showHidden)
+
+	if fd.errMsg != "" {
+		imgui.PushStyleColor(imgui.StyleColorText, settings.ErrorTextColor)
+		imgui.Text(fd.errMsg)
+		imgui.PopStyleColor()
+	}
+
+	if fd.confirmOverwrite != "" {
+
+		imgui.Text("'" + filepath.Base(fd.confirmOverwrite) + "' already exists. Replace it?")
+		imgui.SameLine()
+		if imgui.Button("Replace") {
+			g.finishFileDialog(fd.confirmOverwrite)
+		}
+
+		imgui.SameLine()
+		if imgui.Button("Keep") {
+			fd.confirmOverwrite = ""
+		}
+	}
+
+	acceptLabel := "Open"
+	switch fd.mode {
+	case fileDialogSave:
+		acceptLabel = "Save"
+	case fileDialogFolder:
+		acceptLabel = "Select Folder"
+	}
+
+	if imgui.Button(acceptLabel) || accept {
+		g.acceptFileDialog()
+	}
+
+	imgui.SameLine()
+	if imgui.Button("Cancel") || input.KeyClicked(sdl.K_ESCAPE) {
+		fd.isOpen = false
+	}
+
+	if !fd.isOpen {
+		imgui.CloseCurrentPopup()
+	}
+
+	imgui.EndPopup()
+}
+
+// drawPlaces lists the home folder, the sidebar folder and the recent locations
+func (fd *fileDialog) drawPlaces(currDir string) {
+
+	if home, err := os.UserHomeDir(); err == nil && imgui.Selectable("Home") {
+		fd.setDir(home)
+	}
+
+	if imgui.Selectable("Current Folder") {
+		fd.setDir(currDir)
+	}
+
+	if len(fd.recent.Locations) == 0 {
+		return
+	}
+
+	imgui.Separator()
+	imgui.TextDisabled("Recent")
+	for _, loc := range fd.recent.Locations {
+
+		if imgui.Selectable(filepath.Base(loc) + "##" + loc) {
+			fd.setDir(loc)
+		}
+
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip(loc)
+		}
+	}
+}
+
+func (g *Gopad) drawFileDialogEntries() {
+
+	fd := &g.fileDialog
+	if fd.entriesErr != nil {
+		imgui.TextWrapped("Can't read this folder: " + fd.entriesErr.Error())
+		return
+	}
+
+	for _, entry := range fd.entries {
+
+		if !fd.isShown(entry) {
+			continue
+		}
+
+		name := entry.Name()
+		label := name
+		if entry.IsDir() {
+			label += string(filepath.Separator)
+		}
+
+		if !imgui.SelectableV(label, fd.pathText == name, imgui.SelectableFlagsAllowDoubleClick, imgui.Vec2{}) {
+			continue
+		}
+
+		//A click picks, and a double click opens folders or accepts files
+		fd.pathText = name
+		if !imgui.IsMouseDoubleClicked(0) {
+			continue
+		}
+
+		if entry.IsDir() {
+			fd.setDir(filepath.Join(fd.dir, name))
+			fd.pathText = ""
+			return
+		}
+
+		g.acceptFileDialog()
+		return
+	}
+}
+
+// acceptFileDialog goes into the typed folder, or finishes with the typed path if it is valid for the dialog mode
+func (g *Gopad) acceptFileDialog() {
+
+	fd := &g.fileDialog
+	fd.errMsg = ""
+	if strings.TrimSpace(fd.pathText) == "" {
+
+		if fd.mode == fileDialogFolder {
+			g.finishFileDialog(fd.dir)
 		}
+		return
 	}
 
-	imgui.OpenPopup("selectFolder")
+	fPath := fd.resolvePath(fd.pathText)
+	info, err := os.Stat(fPath)
+	switch {
+
+	case err == nil && info.IsDir() && fd.mode == fileDialogFolder:
+		g.finishFileDialog(fPath)
+
+	case err == nil && info.IsDir():
+		fd.setDir(fPath)
+		fd.pathText = ""
 
-	imgui.SetNextWindowPos(imgui.Vec2{X: float32(winWidth) * 0.5, Y: float32(winHeight) * 0.5})
-	shouldEnd := imgui.BeginPopupModalV("selectFolder", nil, imgui.WindowFlagsNoCollapse)
+	case fd.mode == fileDialogFolder:
+		fd.errMsg = "'" + fPath + "' isn't a folder"
 
-	drawDir(startDir, true)
+	case fd.mode == fileDialogOpen && err != nil:
+		fd.errMsg = "Can't open '" + fPath + "': " + err.Error()
 
-	if shouldEnd {
-		imgui.EndPopup()
-	} else {
-		done = true
+	case fd.mode == fileDialogSave && err == nil:
+		fd.confirmOverwrite = fPath
+
+	case fd.mode == fileDialogSave:
+
+		if dirInfo, err := os.Stat(filepath.Dir(fPath)); err != nil || !dirInfo.IsDir() {
+			fd.errMsg = "The folder '" + filepath.Dir(fPath) + "' doesn't exist"
+			return
+		}
+		g.finishFileDialog(fPath)
+
+	default:
+		g.finishFileDialog(fPath)
+	}
+}
+
+// finishFileDialog closes the dialog, remembers where the path is and passes it on
+func (g *Gopad) finishFileDialog(fPath string) {
+
+	fd := &g.fileDialog
+	fd.isOpen = false
+
+	loc := filepath.Dir(fPath)
+	if fd.mode == fileDialogFolder {
+		loc = fPath
 	}
 
-	return path, done
+	fd.recent.Locations = addRecent(fd.recent.Locations, loc)
+	if err := fd.recent.save(); err != nil {
+		g.triggerError("Failed to save recent locations. Error: " + err.Error())
+	}
+
+	if fd.onDone != nil {
+		fd.onDone(fPath)
+	}
 }
 
-func drawDir(fPath string, foldersOnly bool) {
+// completePath completes the typed path on Tab as far as the names in its folder that start with it agree
+func (fd *fileDialog) completePath(data imgui.InputTextCallbackData) int32 {
+
+	if data.EventFlag() != imgui.InputTextFlagsCallbackCompletion {
+		return 0
+	}
+
+	text := string(data.Buffer())
+	sepIndex := strings.LastIndexAny(text, "/"+string(filepath.Separator))
+	typedDir, prefix := text[:sepIndex+1], text[sepIndex+1:]
 
-	// contents, err := os.ReadDir(fPath)
-	// if err != nil {
-	// 	panic(err)
-	// }
+	entries, err := os.ReadDir(fd.resolvePath(typedDir))
+	if err != nil {
+		return 0
+	}
 
-	// for _, c := range contents {
+	var matches []fs.DirEntry
+	for _, entry := range entries {
 
-	// 	if !c.IsDir() {
-	// 		continue
-	// 	}
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || (isHidden(name) && !fd.showHidden && !isHidden(prefix)) {
+			continue
+		}
+		matches = append(matches, entry)
+	}
 
-	// 	isEnabled := imgui.TreeNodeV( dir.Name(), imgui.TreeNodeFlagsSpanAvailWidth)
-	// 	if !isEnabled {
-	// 		return
-	// 	}
+	if len(matches) == 0 {
+		return 0
+	}
 
-	// 	imgui.bu
-	// }
+	completed := matches[0].Name()
+	for _, m := range matches[1:] {
+		completed = commonPrefix(completed, m.Name())
+	}
+
+	if len(matches) == 1 && matches[0].IsDir() {
+		completed += string(filepath.Separator)
+	}
+
+	data.DeleteBytes(0, len(data.Buffer()))
+	data.InsertBytes(0, []byte(typedDir+completed))
+	return 0
+}
+
+func commonPrefix(a, b string) string {
+
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+
+	//Names that differ in the middle of a char only share the chars before it
+	for i > 0 && i < len(a) && !utf8.RuneStart(a[i]) {
+		i--
+	}
 
+	return a[:i]
 }
@@ -0,0 +1,380 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FileName is the name of the user settings file in the user config directory
+	FileName = "settings.toml"
+	// ProjectFileName is the name of the per-project settings file, which overrides the user settings
+	ProjectFileName = ".gopad.toml"
+)
+
+// Setting is one setting that can be set from a settings file
+type Setting struct {
+	//Name is the dotted TOML key, e.g. 'editor.font_size'
+	Name string
+	Desc string
+
+	//Ptr is the variable the setting is stored in, which is one of *float32, *int, *int64, *string or *[]string
+	Ptr interface{}
+
+	//Min and Max limit number settings
+	Min float64
+	Max float64
+
+	//Options are the values a string setting can have, or nil if it can have any
+	Options []string
+}
+
+// Schema lists every setting a settings file can set, in the order they are written and shown.
+// Settings outside of a table must come first.
+var Schema = []*Setting{
+	{Name: "theme", Desc: "Name of the colour theme", Ptr: &Theme},
+	{Name: "editor.font_size", Desc: "Size of the editor font in pixels", Ptr: &FontSize, Min: 6, Max: 72},
+	{Name: "editor.tab_size", Desc: "Number of columns a tab takes", Ptr: &TabSize, Min: 1, Max: 16},
+	{Name: "editor.scroll_speed", Desc: "Lines scrolled per mouse wheel step", Ptr: &ScrollSpeed, Min: 0.5, Max: 50},
+	{Name: "editor.cursor_width", Desc: "Width of the cursor as a fraction of a char", Ptr: &CursorWidthFactor, Min: 0.05, Max: 1},
+	{Name: "clipboard.history_size", Desc: "Number of copied texts to remember", Ptr: &ClipboardHistorySize, Min: 1, Max: 1000},
+	{Name: "find_in_files.exclude", Desc: "Gitignore style patterns of files to skip", Ptr: &FindInFilesExclude},
+	{Name: "find_in_files.max_file_size", Desc: "Files bigger than this many bytes are skipped", Ptr: &FindInFilesMaxFileSize, Min: 1, Max: math.MaxInt64},
+	{Name: "files.backup", Desc: "Copy kept of a file before saving over it: none, tilde ('file~') or timestamped ('file.<time>~')", Ptr: &Backup, Options: []string{"none", "tilde", "timestamped"}},
+	{Name: "files.backup_count", Desc: "Number of timestamped backups kept per file", Ptr: &BackupCount, Min: 1, Max: 1000},
+}
+
+// Values are setting values keyed by setting name. Each value has the type the setting's Ptr points to.
+type Values map[string]interface{}
+
+// defaults are the values settings have before any settings file is applied
+var defaults = Current()
+
+// Lookup returns the setting with the given name, or nil if there is none
+func Lookup(name string) *Setting {
+
+	for _, s := range Schema {
+		if s.Name == name {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// Get returns the current value of the setting
+func (s *Setting) Get() interface{} {
+
+	switch p := s.Ptr.(type) {
+	case *float32:
+		return *p
+	case *int:
+		return *p
+	case *int64:
+		return *p
+	case *string:
+		return *p
+	case *[]string:
+		return append([]string(nil), *p...)
+	}
+
+	panic("unknown setting type for " + s.Name)
+}
+
+// Set sets the setting. v must have the type Ptr points to.
+func (s *Setting) Set(v interface{}) {
+
+	switch p := s.Ptr.(type) {
+	case *float32:
+		*p = v.(float32)
+	case *int:
+		*p = v.(int)
+	case *int64:
+		*p = v.(int64)
+	case *string:
+		*p = v.(string)
+	case *[]string:
+		*p = append([]string(nil), v.([]string)...)
+	}
+}
+
+// convert turns a parsed TOML value into the type of the setting, making sure it is valid
+func (s *Setting) convert(v interface{}) (interface{}, error) {
+
+	checkRange := func(f float64) error {
+		if f < s.Min || f > s.Max {
+			return fmt.Errorf("must be between %s and %s", strconv.FormatFloat(s.Min, 'g', -1, 64), strconv.FormatFloat(s.Max, 'g', -1, 64))
+		}
+		return nil
+	}
+
+	switch s.Ptr.(type) {
+
+	case *float32:
+
+		var f float64
+		switch n := v.(type) {
+		case float64:
+			f = n
+		case int64:
+			f = float64(n)
+		default:
+			return nil, errors.New("must be a number")
+		}
+
+		return float32(f), checkRange(f)
+
+	case *int, *int64:
+
+		n, ok := v.(int64)
+		if !ok {
+			return nil, errors.New("must be a whole number")
+		}
+
+		if err := checkRange(float64(n)); err != nil {
+			return nil, err
+		}
+
+		if _, ok := s.Ptr.(*int); ok {
+			return int(n), nil
+		}
+		return n, nil
+
+	case *string:
+
+		str, ok := v.(string)
+		if !ok {
+			return nil, errors.New("must be a string")
+		}
+
+		if len(s.Options) > 0 && !containsString(s.Options, str) {
+			return nil, errors.New("must be one of " + strings.Join(s.Options, ", "))
+		}
+		return str, nil
+
+	case *[]string:
+
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, errors.New("must be an array of strings")
+		}
+
+		strs := make([]string, 0, len(items))
+		for _, item := range items {
+			str, ok := item.(string)
+			if !ok {
+				return nil, errors.New("must be an array of strings")
+			}
+			strs = append(strs, str)
+		}
+		return strs, nil
+	}
+
+	return nil, errors.New("unknown setting type")
+}
+
+// Parse reads a settings file. Settings with problems are left out of the returned values and listed in the error,
+// so that one bad line doesn't throw away the rest of the file. name is used in error messages.
+func Parse(data []byte, name string) (Values, error) {
+
+	raw, lines, err := parseTOML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+
+	//Errors are sorted by line so they read in the order of the file
+	sort.Slice(keys, func(i, j int) bool { return lines[keys[i]] < lines[keys[j]] })
+
+	vals := Values{}
+	var problems []string
+	for _, k := range keys {
+
+		s := Lookup(k)
+		if s == nil {
+			problems = append(problems, fmt.Sprintf("%s: line %d: unknown setting '%s'", name, lines[k], k))
+			continue
+		}
+
+		v, err := s.convert(raw[k])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: line %d: '%s' %s", name, lines[k], k, err.Error()))
+			continue
+		}
+
+		vals[k] = v
+	}
+
+	if len(problems) > 0 {
+		return vals, errors.New(strings.Join(problems, "\n"))
+	}
+
+	return vals, nil
+}
+
+// ReadFile parses the settings file at fPath. A missing file has no values and isn't an error, while
+// a file that can't be read or parsed returns nil values.
+func ReadFile(fPath string) (Values, error) {
+
+	data, err := os.ReadFile(fPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return Values{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data, fPath)
+}
+
+// Apply resets every setting to its default then applies the layers in order, so later layers override earlier ones
+func Apply(layers ...Values) {
+
+	for _, s := range Schema {
+		s.Set(defaults[s.Name])
+	}
+
+	for _, l := range layers {
+		for _, s := range Schema {
+			if v, ok := l[s.Name]; ok {
+				s.Set(v)
+			}
+		}
+	}
+}
+
+// Current returns the current values of all settings
+func Current() Values {
+
+	vals := make(Values, len(Schema))
+	for _, s := range Schema {
+		vals[s.Name] = s.Get()
+	}
+
+	return vals
+}
+
+// Defaults returns the values settings have when no settings file sets them
+func Defaults() Values {
+
+	vals := make(Values, len(defaults))
+	for k, v := range defaults {
+		vals[k] = v
+	}
+
+	return vals
+}
+
+// Encode writes vals as a settings file, with settings grouped into tables and in schema order
+func Encode(vals Values) []byte {
+
+	b := strings.Builder{}
+	table := ""
+	for _, s := range Schema {
+
+		v, ok := vals[s.Name]
+		if !ok {
+			continue
+		}
+
+		key := s.Name
+		newTable := ""
+		if dot := strings.LastIndexByte(s.Name, '.'); dot != -1 {
+			newTable, key = s.Name[:dot], s.Name[dot+1:]
+		}
+
+		if newTable != table {
+			b.WriteString("\n[" + newTable + "]\n")
+			table = newTable
+		}
+
+		b.WriteString("# " + s.Desc + "\n")
+		b.WriteString(key + " = " + encodeValue(v) + "\n")
+	}
+
+	return []byte(strings.TrimLeft(b.String(), "\n"))
+}
+
+func encodeValue(v interface{}) string {
+
+	switch v := v.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return tomlQuote(v)
+	case []string:
+
+		quoted := make([]string, len(v))
+		for i := range v {
+			quoted[i] = tomlQuote(v[i])
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+
+	panic(fmt.Sprintf("can't encode setting value of type %T", v))
+}
+
+// WriteFile writes vals to fPath, creating its folder if needed. The file is written to a temp file that is renamed over fPath,
+// so something reading the file (e.g. a Gopad instance watching it) never sees it half written.
+func WriteFile(fPath string, vals Values) error {
+
+	if err := os.MkdirAll(filepath.Dir(fPath), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(fPath), filepath.Base(fPath)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	_, err = f.Write(Encode(vals))
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmpPath, 0644)
+	}
+
+	if err == nil {
+		err = os.Rename(tmpPath, fPath)
+	}
+
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+func containsString(strs []string, str string) bool {
+
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,149 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	tests := []struct {
+		name string
+		src  string
+		vals Values
+
+		//err is the whole error message, or empty if there should be no error
+		err string
+	}{
+		{"every type", "theme = 'Light'\n[editor]\nfont_size = 20\ntab_size = 2\n[find_in_files]\nexclude = ['a/', 'b']\nmax_file_size = 1_000",
+			Values{"theme": "Light", "editor.font_size": float32(20), "editor.tab_size": 2, "find_in_files.exclude": []string{"a/", "b"}, "find_in_files.max_file_size": int64(1000)}, ""},
+		{"whole number for a float", "editor.scroll_speed = 3", Values{"editor.scroll_speed": float32(3)}, ""},
+		{"option", "files.backup = 'tilde'", Values{"files.backup": "tilde"}, ""},
+		{"range limits are allowed", "editor.tab_size = 1\nfiles.backup_count = 1000", Values{"editor.tab_size": 1, "files.backup_count": 1000}, ""},
+
+		{"unknown setting", "editor.nope = 1\ntheme = 'x'", Values{"theme": "x"}, "test.toml: line 1: unknown setting 'editor.nope'"},
+		{"string for a number", "editor.font_size = '16'", Values{}, "test.toml: line 1: 'editor.font_size' must be a number"},
+		{"float for a whole number", "editor.tab_size = 2.5", Values{}, "test.toml: line 1: 'editor.tab_size' must be a whole number"},
+		{"number for a string", "theme = 1", Values{}, "test.toml: line 1: 'theme' must be a string"},
+		{"string for an array", "find_in_files.exclude = 'a'", Values{}, "test.toml: line 1: 'find_in_files.exclude' must be an array of strings"},
+		{"array with a number", "find_in_files.exclude = ['a', 1]", Values{}, "test.toml: line 1: 'find_in_files.exclude' must be an array of strings"},
+		{"bool for a number", "editor.tab_size = true", Values{}, "test.toml: line 1: 'editor.tab_size' must be a whole number"},
+		{"below the minimum", "editor.tab_size = 0", Values{}, "test.toml: line 1: 'editor.tab_size' must be between 1 and 16"},
+		{"above the maximum", "editor.font_size = 72.5", Values{}, "test.toml: line 1: 'editor.font_size' must be between 6 and 72"},
+		{"fractional limits", "editor.cursor_width = 0.01", Values{}, "test.toml: line 1: 'editor.cursor_width' must be between 0.05 and 1"},
+		{"not an option", "files.backup = 'all'", Values{}, "test.toml: line 1: 'files.backup' must be one of none, tilde, timestamped"},
+
+		{"problems are listed in file order and good values kept", "[editor]\ntab_size = 99\nfont_size = 12\n\nscroll_speed = 'fast'\n[nope]\nx = 1",
+			Values{"editor.font_size": float32(12)},
+			"test.toml: line 2: 'editor.tab_size' must be between 1 and 16\n" +
+				"test.toml: line 5: 'editor.scroll_speed' must be a number\n" +
+				"test.toml: line 7: unknown setting 'nope.x'"},
+
+		{"syntax error", "theme = 'x'\n[editor\n", nil, "test.toml: line 2: expected ']' after table name"},
+	}
+
+	for _, tt := range tests {
+
+		vals, err := Parse([]byte(tt.src), "test.toml")
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+
+		if errMsg != tt.err {
+			t.Errorf("%s: expected error %q but got %q", tt.name, tt.err, errMsg)
+		}
+
+		if !reflect.DeepEqual(vals, tt.vals) {
+			t.Errorf("%s: expected values %#v but got %#v", tt.name, tt.vals, vals)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+
+	defer Apply()
+
+	user := Values{"editor.tab_size": 8, "theme": "Light", "find_in_files.exclude": []string{"a"}}
+	project := Values{"editor.tab_size": 2}
+	Apply(user, project)
+
+	if TabSize != 2 {
+		t.Errorf("expected the later layer to win with a tab size of 2 but got %d", TabSize)
+	}
+
+	if Theme != "Light" {
+		t.Errorf("expected the theme of the first layer but got '%s'", Theme)
+	}
+
+	//The setting has its own copy of the slice
+	user["find_in_files.exclude"].([]string)[0] = "changed"
+	if !reflect.DeepEqual(FindInFilesExclude, []string{"a"}) {
+		t.Errorf("expected the exclude list to be [a] but got %v", FindInFilesExclude)
+	}
+
+	//Settings no layer sets go back to their defaults
+	Apply(project)
+	if Theme != defaults["theme"] || !reflect.DeepEqual(FindInFilesExclude, defaults["find_in_files.exclude"]) {
+		t.Errorf("expected the theme and exclude list to be reset but got '%s' and %v", Theme, FindInFilesExclude)
+	}
+
+	Apply()
+	if !reflect.DeepEqual(Current(), Defaults()) {
+		t.Errorf("expected no layers to give the defaults but got %#v", Current())
+	}
+}
+
+func TestEncode(t *testing.T) {
+
+	vals := Defaults()
+	vals["theme"] = "My \"Theme\""
+	vals["editor.font_size"] = float32(13.5)
+	vals["find_in_files.exclude"] = []string{}
+
+	parsed, err := Parse(Encode(vals), "encoded")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(parsed, vals) {
+		t.Errorf("expected the encoded values to parse back the same, but got %#v", parsed)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+
+	fPath := filepath.Join(t.TempDir(), "config", "settings.toml")
+	for _, theme := range []string{"First", "Second"} {
+
+		if err := WriteFile(fPath, Values{"theme": theme}); err != nil {
+			t.Fatal(err)
+		}
+
+		vals, err := ReadFile(fPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if vals["theme"] != theme {
+			t.Errorf("expected the theme to be '%s' but got %v", theme, vals["theme"])
+		}
+	}
+
+	//The temp file was renamed over the settings file, so it is the only file left
+	entries, err := os.ReadDir(filepath.Dir(fPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Name() != "settings.toml" {
+		t.Errorf("expected only settings.toml in the folder but found %d entries", len(entries))
+	}
+
+	vals, err := ReadFile(filepath.Join(t.TempDir(), "missing.toml"))
+	if err != nil || len(vals) != 0 {
+		t.Errorf("expected a missing file to have no values and no error, but got %v and %v", vals, err)
+	}
+}
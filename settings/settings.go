@@ -6,10 +6,60 @@ var (
 	FontSize           float32    = 16
 	TextSelectionColor imgui.Vec4 = imgui.Vec4{X: 84 / 255.0, Y: 153 / 255.0, Z: 199 / 255.0, W: 0.4}
 	EditorBgColor      imgui.Vec4 = imgui.Vec4{X: 0.1, Y: 0.1, Z: 0.1, W: 1}
+	TextColor          imgui.Vec4 = imgui.Vec4{X: 1, Y: 1, Z: 1, W: 1}
+	LineNumberColor    imgui.Vec4 = imgui.Vec4{X: 0.5, Y: 0.5, Z: 0.5, W: 1}
+	FindMatchColor     imgui.Vec4 = imgui.Vec4{X: 230 / 255.0, Y: 160 / 255.0, Z: 40 / 255.0, W: 0.35}
+	DiffAddedColor     imgui.Vec4 = imgui.Vec4{X: 0.45, Y: 0.8, Z: 0.45, W: 1}
+	DiffRemovedColor   imgui.Vec4 = imgui.Vec4{X: 0.9, Y: 0.45, Z: 0.45, W: 1}
+	BracketMatchColor  imgui.Vec4 = imgui.Vec4{X: 0.6, Y: 0.6, Z: 0.6, W: 0.35}
+	GutterBgColor      imgui.Vec4 = imgui.Vec4{X: 0.1, Y: 0.1, Z: 0.1, W: 1}
+
+	//Colours of the rest of the UI
+	UITextColor        imgui.Vec4 = imgui.Vec4{X: 1, Y: 1, Z: 1, W: 1}
+	SidebarBgColor     imgui.Vec4 = imgui.Vec4{X: 0.06, Y: 0.06, Z: 0.06, W: 0.94}
+	SidebarTextColor   imgui.Vec4 = imgui.Vec4{X: 1, Y: 1, Z: 1, W: 1}
+	MenubarBgColor     imgui.Vec4 = imgui.Vec4{X: 0.14, Y: 0.14, Z: 0.14, W: 1}
+	TabColor           imgui.Vec4 = imgui.Vec4{X: 0.18, Y: 0.35, Z: 0.58, W: 0.86}
+	TabHoveredColor    imgui.Vec4 = imgui.Vec4{X: 0.26, Y: 0.59, Z: 0.98, W: 0.8}
+	TabActiveColor     imgui.Vec4 = imgui.Vec4{X: 0.2, Y: 0.41, Z: 0.68, W: 1}
+	PopupBgColor       imgui.Vec4 = imgui.Vec4{X: 0.08, Y: 0.08, Z: 0.08, W: 0.94}
+	WidgetBgColor      imgui.Vec4 = imgui.Vec4{X: 0.16, Y: 0.29, Z: 0.48, W: 0.54}
+	WidgetHoveredColor imgui.Vec4 = imgui.Vec4{X: 0.26, Y: 0.59, Z: 0.98, W: 0.4}
+	ErrorTextColor     imgui.Vec4 = imgui.Vec4{X: 1, Y: 0.4, Z: 0.4, W: 1}
+
+	//Theme is the name of the colour theme in use (see the theme package)
+	Theme string = "Dark"
+
+	//SyntaxColors are the colours of syntax highlighting tokens by kind (see syntax.TokenKind). Kinds without a colour use TextColor.
+	SyntaxColors map[string]imgui.Vec4 = map[string]imgui.Vec4{
+		"keyword":      {X: 86 / 255.0, Y: 156 / 255.0, Z: 214 / 255.0, W: 1},
+		"type":         {X: 78 / 255.0, Y: 201 / 255.0, Z: 176 / 255.0, W: 1},
+		"constant":     {X: 86 / 255.0, Y: 156 / 255.0, Z: 214 / 255.0, W: 1},
+		"number":       {X: 181 / 255.0, Y: 206 / 255.0, Z: 168 / 255.0, W: 1},
+		"string":       {X: 206 / 255.0, Y: 145 / 255.0, Z: 120 / 255.0, W: 1},
+		"comment":      {X: 106 / 255.0, Y: 153 / 255.0, Z: 85 / 255.0, W: 1},
+		"function":     {X: 220 / 255.0, Y: 220 / 255.0, Z: 170 / 255.0, W: 1},
+		"operator":     {X: 212 / 255.0, Y: 212 / 255.0, Z: 212 / 255.0, W: 1},
+		"key":          {X: 156 / 255.0, Y: 220 / 255.0, Z: 254 / 255.0, W: 1},
+		"variable":     {X: 156 / 255.0, Y: 220 / 255.0, Z: 254 / 255.0, W: 1},
+		"preprocessor": {X: 197 / 255.0, Y: 134 / 255.0, Z: 192 / 255.0, W: 1},
+		"heading":      {X: 86 / 255.0, Y: 156 / 255.0, Z: 214 / 255.0, W: 1},
+		"emphasis":     {X: 197 / 255.0, Y: 134 / 255.0, Z: 192 / 255.0, W: 1},
+		"link":         {X: 78 / 255.0, Y: 201 / 255.0, Z: 176 / 255.0, W: 1},
+		"code":         {X: 206 / 255.0, Y: 145 / 255.0, Z: 120 / 255.0, W: 1},
+	}
 
-	//NOTE: Imgui hardcodes tab size to 4 in '#define IM_TABSIZE 4'
 	TabSize           int        = 4
 	ScrollSpeed       float32    = 4
 	CursorWidthFactor float32    = 0.15
 	CursorColor       imgui.Vec4 = imgui.Vec4{X: 1, Y: 1, Z: 1, W: 1}
+
+	ClipboardHistorySize int = 20
+
+	FindInFilesExclude     []string = []string{"node_modules/", "vendor/", "*.exe", "*.syso"}
+	FindInFilesMaxFileSize int64    = 10 * 1024 * 1024
+
+	//Backup is 'none', 'tilde' or 'timestamped'. Only the newest BackupCount timestamped backups of a file are kept.
+	Backup      string = "none"
+	BackupCount int    = 5
 )
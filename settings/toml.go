@@ -0,0 +1,368 @@
+package settings
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tomlParser reads the subset of TOML settings files use: tables, (dotted) keys, strings, numbers, bools and arrays.
+// Values are returned as string, int64, float64, bool or []interface{}, keyed by their full dotted name.
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+
+	table string
+	out   map[string]interface{}
+	lines map[string]int
+}
+
+type tomlError struct {
+	line int
+	msg  string
+}
+
+func (e *tomlError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+// parseTOML parses src, returning the values by key and the line each key was set on
+func parseTOML(src string) (values map[string]interface{}, lines map[string]int, err error) {
+
+	p := &tomlParser{
+		src:   strings.ReplaceAll(src, "\r\n", "\n"),
+		line:  1,
+		out:   map[string]interface{}{},
+		lines: map[string]int{},
+	}
+
+	if err := p.parse(); err != nil {
+		return nil, nil, err
+	}
+
+	return p.out, p.lines, nil
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return &tomlError{line: p.line, msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *tomlParser) parse() error {
+
+	for {
+
+		p.skipSpaceAndComments(true)
+		if p.pos >= len(p.src) {
+			return nil
+		}
+
+		if p.src[p.pos] == '[' {
+
+			if strings.HasPrefix(p.src[p.pos:], "[[") {
+				return p.errorf("arrays of tables are not supported")
+			}
+
+			p.pos++
+			p.skipSpaceAndComments(false)
+			key, err := p.key()
+			if err != nil {
+				return err
+			}
+
+			p.skipSpaceAndComments(false)
+			if p.pos >= len(p.src) || p.src[p.pos] != ']' {
+				return p.errorf("expected ']' after table name")
+			}
+
+			p.pos++
+			p.table = key
+			if err := p.lineEnd(); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		key, err := p.key()
+		if err != nil {
+			return err
+		}
+
+		if p.table != "" {
+			key = p.table + "." + key
+		}
+
+		p.skipSpaceAndComments(false)
+		if p.pos >= len(p.src) || p.src[p.pos] != '=' {
+			return p.errorf("expected '=' after '%s'", key)
+		}
+
+		p.pos++
+		p.skipSpaceAndComments(false)
+		line := p.line
+		v, err := p.value()
+		if err != nil {
+			return err
+		}
+
+		if _, ok := p.out[key]; ok {
+			return &tomlError{line: line, msg: "'" + key + "' is set more than once"}
+		}
+
+		p.out[key] = v
+		p.lines[key] = line
+		if err := p.lineEnd(); err != nil {
+			return err
+		}
+	}
+}
+
+// skipSpaceAndComments skips whitespace and comments, and newlines too if newlines is true
+func (p *tomlParser) skipSpaceAndComments(newlines bool) {
+
+	for p.pos < len(p.src) {
+
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case c == '\n' && newlines:
+			p.pos++
+			p.line++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// lineEnd makes sure nothing but a comment follows a value or table header
+func (p *tomlParser) lineEnd() error {
+
+	p.skipSpaceAndComments(false)
+	if p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		return p.errorf("unexpected '%c' after value", p.src[p.pos])
+	}
+
+	return nil
+}
+
+// key reads a bare, quoted or dotted key
+func (p *tomlParser) key() (string, error) {
+
+	parts := []string{}
+	for {
+
+		p.skipSpaceAndComments(false)
+		if p.pos >= len(p.src) {
+			return "", p.errorf("expected a key")
+		}
+
+		switch c := p.src[p.pos]; {
+
+		case c == '"' || c == '\'':
+			s, err := p.str()
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, s)
+
+		case isBareKeyByte(c):
+			start := p.pos
+			for p.pos < len(p.src) && isBareKeyByte(p.src[p.pos]) {
+				p.pos++
+			}
+			parts = append(parts, p.src[start:p.pos])
+
+		default:
+			return "", p.errorf("unexpected '%c' where a key was expected", c)
+		}
+
+		p.skipSpaceAndComments(false)
+		if p.pos >= len(p.src) || p.src[p.pos] != '.' {
+			return strings.Join(parts, "."), nil
+		}
+
+		p.pos++
+	}
+}
+
+func isBareKeyByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+func (p *tomlParser) value() (interface{}, error) {
+
+	if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+		return nil, p.errorf("expected a value")
+	}
+
+	switch c := p.src[p.pos]; {
+
+	case c == '"' || c == '\'':
+		return p.str()
+
+	case c == '[':
+		return p.array()
+
+	case c == '{':
+		return nil, p.errorf("inline tables are not supported")
+	}
+
+	//Everything else is a bool or number that runs up to the next separator
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n#,]", p.src[p.pos]) == -1 {
+		p.pos++
+	}
+
+	word := p.src[start:p.pos]
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	clean := strings.ReplaceAll(word, "_", "")
+	if i, err := strconv.ParseInt(clean, 0, 64); err == nil {
+		return i, nil
+	}
+
+	if f, err := strconv.ParseFloat(clean, 64); err == nil {
+		return f, nil
+	}
+
+	return nil, p.errorf("'%s' is not a valid value (strings must be quoted)", word)
+}
+
+func (p *tomlParser) array() ([]interface{}, error) {
+
+	//Skip '['
+	p.pos++
+	items := []interface{}{}
+	for {
+
+		p.skipSpaceAndComments(true)
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("array is missing its closing ']'")
+		}
+
+		if p.src[p.pos] == ']' {
+			p.pos++
+			return items, nil
+		}
+
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+
+		p.skipSpaceAndComments(true)
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+		} else if p.pos < len(p.src) && p.src[p.pos] != ']' {
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+// str reads a basic ("...") or literal ('...') string
+func (p *tomlParser) str() (string, error) {
+
+	quote := p.src[p.pos]
+	if strings.HasPrefix(p.src[p.pos:], strings.Repeat(string(quote), 3)) {
+		return "", p.errorf("multi-line strings are not supported")
+	}
+
+	p.pos++
+	b := strings.Builder{}
+	for p.pos < len(p.src) {
+
+		c := p.src[p.pos]
+		switch {
+
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+
+		case c == '\n':
+			return "", p.errorf("string is missing its closing quote")
+
+		case c == '\\' && quote == '"':
+
+			if p.pos+1 >= len(p.src) {
+				return "", p.errorf("string is missing its closing quote")
+			}
+
+			p.pos++
+			switch esc := p.src[p.pos]; esc {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\':
+				b.WriteByte(esc)
+			case 'u', 'U':
+
+				size := 4
+				if esc == 'U' {
+					size = 8
+				}
+
+				if p.pos+size >= len(p.src) {
+					return "", p.errorf("bad unicode escape")
+				}
+
+				r, err := strconv.ParseUint(p.src[p.pos+1:p.pos+1+size], 16, 32)
+				if err != nil {
+					return "", p.errorf("bad unicode escape")
+				}
+
+				b.WriteRune(rune(r))
+				p.pos += size
+
+			default:
+				return "", p.errorf("unknown escape '\\%c'", esc)
+			}
+
+			p.pos++
+
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	return "", p.errorf("string is missing its closing quote")
+}
+
+// tomlQuote writes s as a basic TOML string
+func tomlQuote(s string) string {
+
+	b := strings.Builder{}
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString("\\n")
+		case '\t':
+			b.WriteString("\\t")
+		case '\r':
+			b.WriteString("\\r")
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package settings
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		src    string
+		values map[string]interface{}
+		lines  map[string]int
+	}{
+		{"empty", "", map[string]interface{}{}, map[string]int{}},
+		{"comments and blank lines", "# a comment\n\n  # another\n", map[string]interface{}{}, map[string]int{}},
+		{"bare key", "a = 1", map[string]interface{}{"a": int64(1)}, map[string]int{"a": 1}},
+		{"comment after value", "a = 1 # one\nb = 'x' # two", map[string]interface{}{"a": int64(1), "b": "x"}, map[string]int{"a": 1, "b": 2}},
+		{"crlf line endings", "a = 1\r\nb = 2\r\n", map[string]interface{}{"a": int64(1), "b": int64(2)}, map[string]int{"a": 1, "b": 2}},
+
+		{"numbers", "i = -42\nh = 0x1f\nu = 1_000\nf = 1.5\ne = 2e3",
+			map[string]interface{}{"i": int64(-42), "h": int64(31), "u": int64(1000), "f": 1.5, "e": 2000.0},
+			map[string]int{"i": 1, "h": 2, "u": 3, "f": 4, "e": 5}},
+		{"bools", "t = true\nf = false", map[string]interface{}{"t": true, "f": false}, map[string]int{"t": 1, "f": 2}},
+
+		{"basic string escapes", `s = "a\"b\\c\nd\te\u00e9\U0001F642"`, map[string]interface{}{"s": "a\"b\\c\nd\te\u00e9\U0001F642"}, map[string]int{"s": 1}},
+		{"literal string keeps backslashes", `s = 'C:\dir\n'`, map[string]interface{}{"s": `C:\dir\n`}, map[string]int{"s": 1}},
+		{"hash in string isn't a comment", `s = "a # b"`, map[string]interface{}{"s": "a # b"}, map[string]int{"s": 1}},
+
+		{"array", `a = ["x", 'y', 3]`, map[string]interface{}{"a": []interface{}{"x", "y", int64(3)}}, map[string]int{"a": 1}},
+		{"empty array", "a = []", map[string]interface{}{"a": []interface{}{}}, map[string]int{"a": 1}},
+		{"multi-line array with comments and trailing comma", "a = [\n  \"x\", # first\n  \"y\",\n]\nb = 1",
+			map[string]interface{}{"a": []interface{}{"x", "y"}, "b": int64(1)}, map[string]int{"a": 1, "b": 5}},
+		{"nested array", "a = [[1], []]", map[string]interface{}{"a": []interface{}{[]interface{}{int64(1)}, []interface{}{}}}, map[string]int{"a": 1}},
+
+		{"tables", "top = 1\n[editor]\nfont_size = 16\n[ find_in_files ] # comment\nexclude = []",
+			map[string]interface{}{"top": int64(1), "editor.font_size": int64(16), "find_in_files.exclude": []interface{}{}},
+			map[string]int{"top": 1, "editor.font_size": 3, "find_in_files.exclude": 5}},
+		{"dotted keys", "editor.tab_size = 2\n[a]\nb.c = 1", map[string]interface{}{"editor.tab_size": int64(2), "a.b.c": int64(1)}, map[string]int{"editor.tab_size": 1, "a.b.c": 3}},
+		{"dotted table name", "[a.b]\nc = 1", map[string]interface{}{"a.b.c": int64(1)}, map[string]int{"a.b.c": 2}},
+		{"quoted keys", `"a b" = 1` + "\n" + `'c'.d = 2`, map[string]interface{}{"a b": int64(1), "c.d": int64(2)}, map[string]int{"a b": 1, "c.d": 2}},
+	}
+
+	for _, tt := range tests {
+
+		values, lines, err := parseTOML(tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(values, tt.values) {
+			t.Errorf("%s: expected values %#v but got %#v", tt.name, tt.values, values)
+		}
+
+		if !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf("%s: expected lines %v but got %v", tt.name, tt.lines, lines)
+		}
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"missing equals", "a 1", "line 1: expected '=' after 'a'"},
+		{"missing value", "a =", "line 1: expected a value"},
+		{"missing value before newline", "a =\nb = 1", "line 1: expected a value"},
+		{"unquoted string", "a = hello", "line 1: 'hello' is not a valid value (strings must be quoted)"},
+		{"text after value", "a = 1 2", "line 1: unexpected '2' after value"},
+		{"duplicate key", "a = 1\n\na = 2", "line 3: 'a' is set more than once"},
+		{"duplicate key through a table", "a.b = 1\n[a]\nb = 2", "line 3: 'a.b' is set more than once"},
+		{"bad key", "= 1", "line 1: unexpected '=' where a key was expected"},
+
+		{"unclosed string", `a = "x`, "line 1: string is missing its closing quote"},
+		{"string across lines", "a = 'x\ny'", "line 1: string is missing its closing quote"},
+		{"unknown escape", `a = "\q"`, `line 1: unknown escape '\q'`},
+		{"bad unicode escape", `a = "\u12G4"`, "line 1: bad unicode escape"},
+		{"short unicode escape", `a = "\u12"`, "line 1: bad unicode escape"},
+		{"multi-line string", `a = """x"""`, "line 1: multi-line strings are not supported"},
+
+		{"unclosed array", "a = [1, 2", "line 1: array is missing its closing ']'"},
+		{"unclosed array over lines", "a = [\n1,\n", "line 3: array is missing its closing ']'"},
+		{"missing comma", "a = [1 2]", "line 1: expected ',' or ']' in array"},
+
+		{"unclosed table header", "[editor\na = 1", "line 1: expected ']' after table name"},
+		{"text after table header", "[editor] x", "line 1: unexpected 'x' after value"},
+		{"array of tables", "[[a]]", "line 1: arrays of tables are not supported"},
+		{"inline table", "a = {b = 1}", "line 1: inline tables are not supported"},
+	}
+
+	for _, tt := range tests {
+
+		_, _, err := parseTOML(tt.src)
+		if err == nil {
+			t.Errorf("%s: expected error %q but got none", tt.name, tt.err)
+			continue
+		}
+
+		if err.Error() != tt.err {
+			t.Errorf("%s: expected error %q but got %q", tt.name, tt.err, err.Error())
+		}
+	}
+}
+
+func TestTOMLQuote(t *testing.T) {
+
+	for _, s := range []string{"", "plain", `a"b\c`, "tab\tnew\nline\r", "é🙂"} {
+
+		values, _, err := parseTOML("s = " + tomlQuote(s))
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+
+		if values["s"] != s {
+			t.Errorf("expected %q to read back the same but got %q", s, values["s"])
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/bloeys/gopad/buffer"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// statusBarHeight returns the height of the status bar at the bottom of the editor area
+func statusBarHeight() float32 {
+	return imgui.FrameHeightWithSpacing() + 2*imgui.CurrentStyle().WindowPadding().Y
+}
+
+// drawStatusBar draws the status bar of the active editor at pos
+func (g *Gopad) drawStatusBar(e *Editor, pos *imgui.Vec2, width float32) {
+
+	imgui.SetNextWindowPos(*pos)
+	imgui.SetNextWindowSize(imgui.Vec2{X: width, Y: statusBarHeight()})
+	imgui.BeginV("statusBar", nil, imgui.WindowFlagsNoCollapse|imgui.WindowFlagsNoDecoration|imgui.WindowFlagsNoMove|imgui.WindowFlagsNoScrollWithMouse)
+
+	imgui.AlignTextToFramePadding()
+	imgui.Text("Ln " + strconv.Itoa(e.Cursor.Line+1) + ", Col " + strconv.Itoa(e.Cursor.Col+1))
+
+	imgui.SameLine()
+	if imgui.SmallButton(lineEndingLabel(e.Endings)) {
+		imgui.OpenPopup("lineEndings")
+	}
+
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Line endings. Click to convert.")
+	}
+
+	if imgui.BeginPopup("lineEndings") {
+		drawLineEndingItems(e)
+		imgui.EndPopup()
+	}
+
+	imgui.SameLine()
+	if imgui.SmallButton(e.Encoding.String()) {
+		imgui.OpenPopup("encoding")
+	}
+
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Encoding. Click to reopen or save with another one.")
+	}
+
+	if imgui.BeginPopup("encoding") {
+		g.drawEncodingMenus()
+		imgui.EndPopup()
+	}
+
+	imgui.End()
+}
+
+// lineEndingLabel returns how the line endings of a document are shown, e.g. 'CRLF' or 'Mixed (LF)'
+func lineEndingLabel(endings *buffer.LineEndings) string {
+
+	if endings.Mixed() {
+		return "Mixed (" + endings.Default.String() + ")"
+	}
+
+	return endings.Default.String()
+}
+
+// drawLineEndingItems draws menu items that convert all the line endings of the editor
+func drawLineEndingItems(e *Editor) {
+
+	for _, le := range [...]buffer.LineEnding{buffer.LF, buffer.CRLF, buffer.CR} {
+
+		selected := !e.Endings.Mixed() && e.Endings.Default == le
+		if imgui.MenuItemV(le.String(), "", selected, true) {
+			e.SetLineEnding(le)
+		}
+	}
+}
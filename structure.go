@@ -0,0 +1,63 @@
+package main
+
+// ExpandSelection grows the selection to the smallest syntax node around it, remembering the old selection for ShrinkSelection
+func (e *Editor) ExpandSelection() {
+
+	if e.Tree == nil {
+		return
+	}
+
+	start, end := e.Selection()
+
+	//Any other change of selection starts a new chain of expansions
+	if len(e.expandHistory) > 0 && e.expandedTo != [2]int{start, end} {
+		e.expandHistory = e.expandHistory[:0]
+	}
+
+	newStart, newEnd, ok := e.Tree.ExpandSelection(start, end)
+	if !ok {
+		return
+	}
+
+	e.expandHistory = append(e.expandHistory, [2]int{start, end})
+	e.expandedTo = [2]int{newStart, newEnd}
+	e.Select(newStart, newEnd)
+	e.History.Break()
+}
+
+// ShrinkSelection undoes the last ExpandSelection, as long as the selection hasn't changed since
+func (e *Editor) ShrinkSelection() {
+
+	start, end := e.Selection()
+	if len(e.expandHistory) == 0 || e.expandedTo != [2]int{start, end} {
+		return
+	}
+
+	prev := e.expandHistory[len(e.expandHistory)-1]
+	e.expandHistory = e.expandHistory[:len(e.expandHistory)-1]
+	e.expandedTo = prev
+	e.Select(prev[0], prev[1])
+	e.History.Break()
+}
+
+// GoToMatchingBracket moves the cursor to the bracket that matches the one next to it
+func (e *Editor) GoToMatchingBracket() {
+
+	if e.Tree == nil {
+		return
+	}
+
+	off := e.CursorOffset()
+	open, close, ok := e.Tree.MatchingBracket(off)
+	if !ok {
+		return
+	}
+
+	if off == open || off == open+1 {
+		e.SetCursorFromOffset(close)
+	} else {
+		e.SetCursorFromOffset(open)
+	}
+
+	e.History.Break()
+}
@@ -0,0 +1,283 @@
+package syntax
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	clikeStateBlockComment State = 1
+	//clikeStateString+i means we are in a string that was opened by multilineQuotes[i] on an earlier line
+	clikeStateString State = 2
+)
+
+const operatorChars = "+-*/%=&|^!<>~?:;,.(){}[]@"
+
+// clikeLexer handles languages made of identifiers, numbers, strings, comments and punctuation,
+// which with a bit of configuration covers most programming and data languages
+type clikeLexer struct {
+	keywords map[string]TokenKind
+
+	lineComments []string
+	blockComment [2]string
+	//commentNeedsSpace only starts line comments at the start of a word (e.g. in shell 'a#b' isn't a comment)
+	commentNeedsSpace bool
+
+	quotes string
+	//multilineQuotes are quotes whose strings can go on to the next lines, and rawQuotes are quotes whose strings have no escapes
+	multilineQuotes string
+	rawQuotes       string
+
+	//funcCalls colours identifiers followed by '(' as functions
+	funcCalls bool
+	//variables colours '$name' and '${name}'
+	variables bool
+	//preprocessor colours lines that start with '#' as preprocessor lines
+	preprocessor bool
+	//keysBeforeColon colours strings followed by ':' as keys
+	keysBeforeColon bool
+}
+
+func (l *clikeLexer) Lex(line []byte, state State, dst []Token) ([]Token, State) {
+
+	i := 0
+
+	//Finish what the previous line started
+	switch {
+	case state == clikeStateBlockComment:
+
+		end := bytes.Index(line, []byte(l.blockComment[1]))
+		if end == -1 {
+			return append(dst, Token{Start: 0, End: len(line), Kind: TokenComment}), state
+		}
+
+		i = end + len(l.blockComment[1])
+		dst = append(dst, Token{Start: 0, End: i, Kind: TokenComment})
+
+	case state >= clikeStateString:
+
+		quoteIndex := int(state - clikeStateString)
+		if quoteIndex >= len(l.multilineQuotes) {
+			break
+		}
+
+		end, closed := l.stringEnd(line, 0, l.multilineQuotes[quoteIndex])
+		dst = append(dst, Token{Start: 0, End: end, Kind: TokenString})
+		if !closed {
+			return dst, state
+		}
+
+		i = end
+	}
+
+	if l.preprocessor && i == 0 {
+
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) > 0 && trimmed[0] == '#' {
+			return append(dst, Token{Start: 0, End: len(line), Kind: TokenPreprocessor}), 0
+		}
+	}
+
+	for i < len(line) {
+
+		c := line[i]
+		switch {
+
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+
+		case l.isLineComment(line, i):
+			return append(dst, Token{Start: i, End: len(line), Kind: TokenComment}), 0
+
+		case l.blockComment[0] != "" && bytes.HasPrefix(line[i:], []byte(l.blockComment[0])):
+
+			start := i
+			end := bytes.Index(line[i+len(l.blockComment[0]):], []byte(l.blockComment[1]))
+			if end == -1 {
+				return append(dst, Token{Start: start, End: len(line), Kind: TokenComment}), clikeStateBlockComment
+			}
+
+			i += len(l.blockComment[0]) + end + len(l.blockComment[1])
+			dst = append(dst, Token{Start: start, End: i, Kind: TokenComment})
+
+		case bytes.IndexByte([]byte(l.quotes), c) != -1:
+
+			start := i
+			end, closed := l.stringEnd(line, i+1, c)
+			i = end
+
+			kind := TokenString
+			if l.keysBeforeColon && nextNonSpace(line, i) == ':' {
+				kind = TokenKey
+			}
+
+			dst = append(dst, Token{Start: start, End: end, Kind: kind})
+			if !closed {
+				if q := bytes.IndexByte([]byte(l.multilineQuotes), c); q != -1 {
+					return dst, clikeStateString + State(q)
+				}
+			}
+
+		case isDigit(c) || (c == '.' && i+1 < len(line) && isDigit(line[i+1])):
+
+			start := i
+			for i < len(line) && (isIdentByte(line[i]) || line[i] == '.' || ((line[i] == '+' || line[i] == '-') && (line[i-1] == 'e' || line[i-1] == 'E'))) {
+				i++
+			}
+
+			dst = append(dst, Token{Start: start, End: i, Kind: TokenNumber})
+
+		case l.variables && c == '$':
+
+			start := i
+			i = variableEnd(line, i)
+			dst = append(dst, Token{Start: start, End: i, Kind: TokenVariable})
+
+		case isIdentStart(line[i:]):
+
+			start := i
+			i = identEnd(line, i)
+
+			if kind, ok := l.keywords[string(line[start:i])]; ok {
+				dst = append(dst, Token{Start: start, End: i, Kind: kind})
+			} else if l.funcCalls && nextNonSpace(line, i) == '(' {
+				dst = append(dst, Token{Start: start, End: i, Kind: TokenFunction})
+			}
+
+		case bytes.IndexByte([]byte(operatorChars), c) != -1:
+
+			start := i
+			for i < len(line) && bytes.IndexByte([]byte(operatorChars), line[i]) != -1 && !l.isLineComment(line, i) &&
+				(l.blockComment[0] == "" || !bytes.HasPrefix(line[i:], []byte(l.blockComment[0]))) {
+				i++
+			}
+
+			if i == start {
+				i++
+			}
+
+			dst = append(dst, Token{Start: start, End: i, Kind: TokenOperator})
+
+		default:
+			_, size := utf8.DecodeRune(line[i:])
+			i += size
+		}
+	}
+
+	return dst, 0
+}
+
+func (l *clikeLexer) isLineComment(line []byte, i int) bool {
+
+	if l.commentNeedsSpace && i > 0 && line[i-1] != ' ' && line[i-1] != '\t' {
+		return false
+	}
+
+	for _, prefix := range l.lineComments {
+		if bytes.HasPrefix(line[i:], []byte(prefix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stringEnd returns the index after the quote that closes a string whose contents start at from.
+// closed is false if the string goes on past the end of the line.
+func (l *clikeLexer) stringEnd(line []byte, from int, quote byte) (end int, closed bool) {
+
+	raw := bytes.IndexByte([]byte(l.rawQuotes), quote) != -1
+	for i := from; i < len(line); i++ {
+
+		if !raw && line[i] == '\\' {
+			i++
+			continue
+		}
+
+		if line[i] == quote {
+			return i + 1, true
+		}
+	}
+
+	return len(line), false
+}
+
+// variableEnd returns the end of a '$name', '${...}' or '$1' style variable that starts at i
+func variableEnd(line []byte, i int) int {
+
+	i++
+	if i >= len(line) {
+		return i
+	}
+
+	if line[i] == '{' {
+
+		if end := bytes.IndexByte(line[i:], '}'); end != -1 {
+			return i + end + 1
+		}
+
+		return len(line)
+	}
+
+	if !isIdentStart(line[i:]) {
+		//Special variables like $1, $? and $@
+		return i + 1
+	}
+
+	return identEnd(line, i)
+}
+
+func identEnd(line []byte, i int) int {
+
+	for i < len(line) {
+
+		r, size := utf8.DecodeRune(line[i:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+
+		i += size
+	}
+
+	return i
+}
+
+func isIdentStart(b []byte) bool {
+	r, _ := utf8.DecodeRune(b)
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// nextNonSpace returns the first byte at or after i that isn't a space or tab, or 0 if there is none
+func nextNonSpace(line []byte, i int) byte {
+
+	for ; i < len(line); i++ {
+		if line[i] != ' ' && line[i] != '\t' {
+			return line[i]
+		}
+	}
+
+	return 0
+}
+
+// words maps each of the space separated words to kind
+func words(kind TokenKind, list string, m map[string]TokenKind) map[string]TokenKind {
+
+	if m == nil {
+		m = map[string]TokenKind{}
+	}
+
+	for _, w := range bytes.Fields([]byte(list)) {
+		m[string(w)] = kind
+	}
+
+	return m
+}
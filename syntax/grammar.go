@@ -0,0 +1,499 @@
+package syntax
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxLoopsAtPos stops grammars that keep matching empty text at the same position (e.g. pushing and popping forever)
+	maxLoopsAtPos = 32
+)
+
+// Grammar is a lexer driven by a TextMate or Sublime grammar file.
+//
+// Both formats are loaded into the same model: a context is a list of rules, and rules match text and can push
+// contexts onto a stack, pop them off, or include the rules of other contexts (possibly of other grammars, which
+// is how embedded languages work). A TextMate begin/end rule is a rule that pushes a context whose end pattern is
+// checked before its own rules.
+type Grammar struct {
+	Name      string
+	ScopeName string
+	FileTypes []string
+
+	main     *context
+	contexts map[string]*context
+	//prototype is the Sublime context whose rules are included in every context
+	prototype *context
+	isSublime bool
+
+	//frames holds every distinct stack frame seen so far, and State is an index into it. 0 is the bottom of the stack.
+	frames     []frame
+	frameIndex map[frameKey]State
+}
+
+type context struct {
+	grammar *Grammar
+	rules   []*rule
+
+	//metaScope covers everything in the context including the text that pushed and popped it,
+	//while contentScope only covers what is in between
+	metaScope    string
+	contentScope string
+
+	//end is the pattern that pops the context. If it has back references they refer to the captures of the pattern that pushed it.
+	end           *onigRegexp
+	endSource     string
+	endCaptures   map[int]string
+	endHasBackref bool
+	endLast       bool
+
+	//Sublime contexts include the prototype unless told not to
+	noPrototype bool
+
+	flat     []*rule
+	flatDone bool
+}
+
+type rule struct {
+	grammar *Grammar
+
+	re       *onigRegexp
+	scope    string
+	captures map[int]string
+
+	//pop is how many contexts are popped by the rule
+	pop int
+	//push contexts are pushed in order, so the last one ends up on top. set is the same but pops the current context first.
+	push    []contextRef
+	setCtxs []contextRef
+
+	//include is the name of a context whose rules are used in place of this rule
+	include string
+}
+
+// contextRef is either a context or the name of one, which is resolved once it's used so that grammars can refer to each other
+type contextRef struct {
+	ctx  *context
+	name string
+}
+
+type frame struct {
+	ctx    *context
+	end    *onigRegexp
+	parent State
+}
+
+type frameKey struct {
+	parent State
+	ctx    *context
+	endSrc string
+}
+
+var grammarsByScope = map[string]*Grammar{}
+
+func (g *Grammar) Lex(line []byte, state State, dst []Token) ([]Token, State) {
+
+	if int(state) >= len(g.frames) {
+		state = 0
+	}
+
+	pos := 0
+	loops := 0
+	for pos <= len(line) {
+
+		f := &g.frames[state]
+		stackKind := g.stackKind(state)
+
+		r, loc, isEnd := g.nextMatch(line, pos, f)
+		if loc == nil {
+			dst = appendToken(dst, pos, len(line), stackKind)
+			break
+		}
+
+		dst = appendToken(dst, pos, loc[0], stackKind)
+
+		//Guard against rules that match nothing and change nothing
+		if loc[1] == loc[0] {
+			loops++
+			if loops > maxLoopsAtPos || (!isEnd && r.pop == 0 && len(r.push) == 0 && len(r.setCtxs) == 0) {
+				if pos == len(line) {
+					break
+				}
+				dst = appendToken(dst, pos, pos+1, stackKind)
+				pos++
+				loops = 0
+				continue
+			}
+		} else {
+			loops = 0
+		}
+
+		if isEnd {
+			kind := g.scopeKindOr(f.ctx.metaScope, stackKind)
+			dst = appendCaptures(dst, loc, f.ctx.endCaptures, kind)
+			state = f.parent
+			pos = loc[1]
+			continue
+		}
+
+		newState := state
+		matchKind := g.scopeKindOr(r.scope, stackKind)
+		if r.pop > 0 || len(r.setCtxs) > 0 {
+
+			matchKind = g.scopeKindOr(r.scope, g.scopeKindOr(f.ctx.metaScope, stackKind))
+			for i := 0; i < maxInt(r.pop, 1); i++ {
+				newState = g.frames[newState].parent
+			}
+		}
+
+		pushes := r.push
+		if len(r.setCtxs) > 0 {
+			pushes = r.setCtxs
+		}
+
+		for i := 0; i < len(pushes); i++ {
+
+			ctx := g.resolveRef(r.grammar, &pushes[i])
+			if ctx == nil {
+				continue
+			}
+
+			newState = g.pushFrame(newState, ctx, line, loc)
+			if i == len(pushes)-1 {
+				matchKind = g.scopeKindOr(r.scope, g.scopeKindOr(ctx.metaScope, matchKind))
+			}
+		}
+
+		dst = appendCaptures(dst, loc, r.captures, matchKind)
+		state = newState
+		pos = loc[1]
+	}
+
+	return dst, state
+}
+
+// nextMatch returns the rule that matches earliest at or after pos, with the end pattern of the frame winning ties
+// (unless the context asks for it to be checked last). isEnd is true if it was the end pattern that matched.
+func (g *Grammar) nextMatch(line []byte, pos int, f *frame) (best *rule, bestLoc []int, isEnd bool) {
+
+	if f.end != nil && !f.ctx.endLast {
+		bestLoc = f.end.find(line, pos)
+		isEnd = bestLoc != nil
+		if bestLoc != nil && bestLoc[0] == pos {
+			return nil, bestLoc, true
+		}
+	}
+
+	for _, r := range g.flatRules(f.ctx) {
+
+		if r.re == nil {
+			continue
+		}
+
+		loc := r.re.find(line, pos)
+		if loc == nil || (bestLoc != nil && loc[0] >= bestLoc[0]) {
+			continue
+		}
+
+		best, bestLoc, isEnd = r, loc, false
+		if loc[0] == pos {
+			break
+		}
+	}
+
+	if f.end != nil && f.ctx.endLast {
+		if loc := f.end.find(line, pos); loc != nil && (bestLoc == nil || loc[0] < bestLoc[0]) {
+			return nil, loc, true
+		}
+	}
+
+	return best, bestLoc, isEnd
+}
+
+// flatRules returns the rules of ctx with all includes replaced by the rules they refer to
+func (g *Grammar) flatRules(ctx *context) []*rule {
+
+	if !ctx.flatDone {
+		ctx.flatDone = true
+		ctx.flat = g.flatten(ctx, nil, map[*context]bool{})
+	}
+
+	return ctx.flat
+}
+
+func (g *Grammar) flatten(ctx *context, dst []*rule, seen map[*context]bool) []*rule {
+
+	if seen[ctx] {
+		return dst
+	}
+	seen[ctx] = true
+
+	if p := ctx.grammar.prototype; p != nil && ctx.grammar.isSublime && !ctx.noPrototype && p != ctx {
+		dst = g.flatten(p, dst, seen)
+	}
+
+	for _, r := range ctx.rules {
+
+		if r.include == "" {
+			dst = append(dst, r)
+			continue
+		}
+
+		if inc := g.resolveRef(r.grammar, &contextRef{name: r.include}); inc != nil {
+			dst = g.flatten(inc, dst, seen)
+		}
+	}
+
+	return dst
+}
+
+// resolveRef finds the context a reference points to, where names are resolved relative to grammar owner
+func (g *Grammar) resolveRef(owner *Grammar, ref *contextRef) *context {
+
+	if ref.ctx != nil {
+		return ref.ctx
+	}
+
+	name := ref.name
+	switch {
+	case name == "$self":
+		ref.ctx = owner.main
+	case name == "$base":
+		return g.main
+	case strings.HasPrefix(name, "#"):
+		ref.ctx = owner.contexts[name[1:]]
+	case strings.HasPrefix(name, "scope:") || strings.HasPrefix(name, "source.") || strings.HasPrefix(name, "text."):
+
+		scopeName := strings.TrimPrefix(name, "scope:")
+		ctxName := ""
+		if i := strings.IndexByte(scopeName, '#'); i != -1 {
+			scopeName, ctxName = scopeName[:i], scopeName[i+1:]
+		}
+
+		other := grammarsByScope[scopeName]
+		if other == nil {
+			return nil
+		}
+
+		if ctxName == "" {
+			ref.ctx = other.main
+		} else {
+			ref.ctx = other.contexts[ctxName]
+		}
+
+	case owner.isSublime:
+		ref.ctx = owner.contexts[name]
+	}
+
+	return ref.ctx
+}
+
+// pushFrame returns the state of ctx pushed on top of parent. loc is the match that pushed it, used to fill in back references.
+func (g *Grammar) pushFrame(parent State, ctx *context, line []byte, loc []int) State {
+
+	endSrc := ""
+	if ctx.endHasBackref {
+		endSrc = fillBackrefs(ctx.endSource, line, loc)
+	}
+
+	key := frameKey{parent: parent, ctx: ctx, endSrc: endSrc}
+	if s, ok := g.frameIndex[key]; ok {
+		return s
+	}
+
+	f := frame{ctx: ctx, end: ctx.end, parent: parent}
+	if ctx.endHasBackref {
+		f.end, _ = compileOnig(endSrc)
+	}
+
+	g.frames = append(g.frames, f)
+	s := State(len(g.frames) - 1)
+	g.frameIndex[key] = s
+	return s
+}
+
+var backrefRegexp = regexp.MustCompile(`\\[1-9]`)
+
+// fillBackrefs replaces '\1' style back references in pattern with the (escaped) text of the captures in loc
+func fillBackrefs(pattern string, line []byte, loc []int) string {
+
+	return backrefRegexp.ReplaceAllStringFunc(pattern, func(ref string) string {
+
+		group, _ := strconv.Atoi(ref[1:])
+		if 2*group+1 >= len(loc) || loc[2*group] < 0 {
+			return ""
+		}
+
+		return regexp.QuoteMeta(string(line[loc[2*group]:loc[2*group+1]]))
+	})
+}
+
+// stackKind returns the token kind of text in the current context, which is decided by the innermost scope that has a kind
+func (g *Grammar) stackKind(state State) TokenKind {
+
+	for {
+
+		f := &g.frames[state]
+		if kind, ok := ScopeKind(f.ctx.contentScope); ok {
+			return kind
+		}
+
+		if kind, ok := ScopeKind(f.ctx.metaScope); ok {
+			return kind
+		}
+
+		if state == 0 {
+			return TokenText
+		}
+
+		state = f.parent
+	}
+}
+
+func (g *Grammar) scopeKindOr(scope string, fallback TokenKind) TokenKind {
+
+	if kind, ok := ScopeKind(scope); ok {
+		return kind
+	}
+
+	return fallback
+}
+
+// scopeKinds maps scope name prefixes to token kinds. Longer prefixes are listed first so they win over shorter ones.
+var scopeKinds = []struct {
+	prefix string
+	kind   TokenKind
+}{
+	{"comment", TokenComment},
+	{"string", TokenString},
+	{"constant.numeric", TokenNumber},
+	{"constant.character.escape", TokenString},
+	{"constant", TokenConstant},
+	{"keyword.operator", TokenOperator},
+	{"keyword.control.import", TokenPreprocessor},
+	{"keyword", TokenKeyword},
+	{"storage.type", TokenType},
+	{"storage", TokenKeyword},
+	{"entity.name.function", TokenFunction},
+	{"support.function", TokenFunction},
+	{"meta.function-call", TokenFunction},
+	{"entity.name.type", TokenType},
+	{"entity.name.class", TokenType},
+	{"support.type.property-name", TokenKey},
+	{"support.type", TokenType},
+	{"support.class", TokenType},
+	{"entity.name.tag", TokenKeyword},
+	{"entity.other.attribute-name", TokenKey},
+	{"entity.name.section", TokenHeading},
+	{"variable", TokenVariable},
+	{"meta.preprocessor", TokenPreprocessor},
+	{"markup.heading", TokenHeading},
+	{"markup.bold", TokenEmphasis},
+	{"markup.italic", TokenEmphasis},
+	{"markup.underline.link", TokenLink},
+	{"markup.inline.raw", TokenCode},
+	{"markup.raw", TokenCode},
+	{"markup.quote", TokenComment},
+	{"markup.list", TokenOperator},
+}
+
+// ScopeKind returns the token kind of a TextMate scope. Scopes can hold multiple space separated names, in which case the last one with a kind wins.
+func ScopeKind(scope string) (TokenKind, bool) {
+
+	if scope == "" {
+		return TokenText, false
+	}
+
+	names := strings.Fields(scope)
+	for i := len(names) - 1; i >= 0; i-- {
+		for _, sk := range scopeKinds {
+			if names[i] == sk.prefix || strings.HasPrefix(names[i], sk.prefix+".") {
+				return sk.kind, true
+			}
+		}
+	}
+
+	return TokenText, false
+}
+
+// appendCaptures adds the tokens of a match, where captured groups that have a scope get their own kind
+func appendCaptures(dst []Token, loc []int, captures map[int]string, kind TokenKind) []Token {
+
+	if loc[0] == loc[1] {
+		return dst
+	}
+
+	if len(captures) == 0 {
+		return appendToken(dst, loc[0], loc[1], kind)
+	}
+
+	if k, ok := ScopeKind(captures[0]); ok {
+		kind = k
+	}
+
+	//Groups can nest, so the kind of every byte is worked out first with inner (later) groups overwriting outer ones
+	kinds := make([]TokenKind, loc[1]-loc[0])
+	for i := range kinds {
+		kinds[i] = kind
+	}
+
+	for group := 1; 2*group+1 < len(loc); group++ {
+
+		k, ok := ScopeKind(captures[group])
+		if !ok || loc[2*group] < 0 {
+			continue
+		}
+
+		for i := loc[2*group]; i < loc[2*group+1]; i++ {
+			kinds[i-loc[0]] = k
+		}
+	}
+
+	start := 0
+	for i := 1; i <= len(kinds); i++ {
+		if i == len(kinds) || kinds[i] != kinds[start] {
+			dst = appendToken(dst, loc[0]+start, loc[0]+i, kinds[start])
+			start = i
+		}
+	}
+
+	return dst
+}
+
+// appendToken adds a token, merging it into the previous one if they touch and are of the same kind.
+// Plain text isn't stored as tokens.
+func appendToken(dst []Token, start, end int, kind TokenKind) []Token {
+
+	if start >= end || kind == TokenText {
+		return dst
+	}
+
+	if n := len(dst); n > 0 && dst[n-1].End == start && dst[n-1].Kind == kind {
+		dst[n-1].End = end
+		return dst
+	}
+
+	return append(dst, Token{Start: start, End: end, Kind: kind})
+}
+
+func newGrammar(name, scopeName string, fileTypes []string, isSublime bool) *Grammar {
+
+	g := &Grammar{
+		Name:       name,
+		ScopeName:  scopeName,
+		FileTypes:  fileTypes,
+		contexts:   map[string]*context{},
+		isSublime:  isSublime,
+		frameIndex: map[frameKey]State{},
+	}
+
+	return g
+}
+
+// finish adds the bottom stack frame once the main context is known
+func (g *Grammar) finish(main *context) {
+	g.main = main
+	g.frames = []frame{{ctx: main}}
+}
@@ -0,0 +1,94 @@
+package syntax
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with the current output")
+
+// TestGrammarSamples lexes every file in testdata/samples with the grammar its extension maps to, and compares
+// the tokens to the sample's .golden file. The shipped grammars are tested along with the ones in testdata/grammars,
+// which exist to cover the grammar features (embedding, back references, push/set/pop and so on).
+//
+// Run with -update to rewrite the golden files after an intended change, and check the diff.
+func TestGrammarSamples(t *testing.T) {
+
+	for _, dir := range []string{"../res/grammars", "testdata/grammars"} {
+
+		errs := LoadGrammars(dir)
+		for _, err := range errs {
+			t.Error(err)
+		}
+	}
+
+	samples, err := filepath.Glob("testdata/samples/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sample := range samples {
+
+		if strings.HasSuffix(sample, ".golden") {
+			continue
+		}
+
+		lang := ForFile(sample)
+		if lang == nil {
+			t.Errorf("%s: no grammar for the sample", sample)
+			continue
+		}
+
+		if _, ok := lang.Lexer.(*Grammar); !ok {
+			t.Errorf("%s: language '%s' isn't grammar based", sample, lang.Name)
+			continue
+		}
+
+		data, err := os.ReadFile(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := lexGolden(lang.Lexer, data)
+		goldenPath := sample + ".golden"
+		if *update {
+			if err := os.WriteFile(goldenPath, out, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		golden, err := os.ReadFile(goldenPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(out, golden) {
+			t.Errorf("%s: tokens differ from %s, got:\n%s", sample, goldenPath, out)
+		}
+	}
+}
+
+// lexGolden lexes data line by line like the highlighter does, and writes each line followed by its tokens
+func lexGolden(lexer Lexer, data []byte) []byte {
+
+	sb := bytes.Buffer{}
+	state := State(0)
+	var tokens []Token
+	for i, line := range bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) {
+
+		tokens, state = lexer.Lex(line, state, tokens[:0])
+
+		fmt.Fprintf(&sb, "%d| %s\n", i+1, line)
+		for _, tok := range tokens {
+			fmt.Fprintf(&sb, "   %-9s %q\n", tok.Kind, line[tok.Start:tok.End])
+		}
+	}
+
+	return sb.Bytes()
+}
@@ -0,0 +1,69 @@
+package syntax
+
+import "github.com/bloeys/gopad/buffer"
+
+// Highlighter tokenizes the lines of a buffer.
+//
+// It remembers the lexer state at the start of every line lexed so far, so a line can be lexed without going through the
+// lines before it again. After an edit only the states from the edited line onwards are dropped, and they are only lexed
+// again once a line after the edit is needed.
+type Highlighter struct {
+	Lang *Language
+
+	buf        *buffer.Buffer
+	listenerID buffer.ListenerID
+
+	//states[i] is the lexer state at the start of line i
+	states []State
+	tokens []Token
+}
+
+// LineTokens returns the tokens of a line. The returned slice is reused by the next call.
+func (h *Highlighter) LineTokens(lineNum int) []Token {
+
+	if lineNum < 0 || lineNum >= h.buf.LineCount() {
+		return nil
+	}
+
+	//@PERF: The first time a line far into a big file is needed every line before it is lexed to find its start state.
+	//This only happens once, but a background pass could avoid the stall.
+	for len(h.states) <= lineNum {
+		i := len(h.states) - 1
+		var end State
+		h.tokens, end = h.Lang.Lexer.Lex(h.buf.Line(i), h.states[i], h.tokens[:0])
+		h.states = append(h.states, end)
+	}
+
+	h.tokens, _ = h.Lang.Lexer.Lex(h.buf.Line(lineNum), h.states[lineNum], h.tokens[:0])
+	return h.tokens
+}
+
+// invalidate drops the states after lineNum. The state at the start of lineNum is still valid
+// because a change inside a line can only affect the lines after it.
+func (h *Highlighter) invalidate(lineNum int) {
+
+	if lineNum+1 < len(h.states) {
+		h.states = h.states[:lineNum+1]
+	}
+}
+
+// NewHighlighter returns a highlighter for buf that stays up to date as buf is edited
+func NewHighlighter(lang *Language, buf *buffer.Buffer) *Highlighter {
+
+	h := &Highlighter{
+		Lang:   lang,
+		buf:    buf,
+		states: []State{0},
+	}
+
+	h.listenerID = buf.Listen(func(off, removed, inserted int) {
+		h.invalidate(buf.OffsetToLine(off))
+	})
+
+	return h
+}
+
+// Close stops the highlighter from following the edits of its buffer, after which it must not be used
+func (h *Highlighter) Close() {
+	h.buf.Unlisten(h.listenerID)
+}
@@ -0,0 +1,77 @@
+package syntax
+
+func init() {
+
+	Register(&Language{
+		Name:       "Go",
+		Extensions: []string{".go"},
+		Lexer: &clikeLexer{
+			keywords: words(TokenType, "bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr any comparable",
+				words(TokenConstant, "true false nil iota",
+					words(TokenKeyword, "break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var", nil))),
+			lineComments:    []string{"//"},
+			blockComment:    [2]string{"/*", "*/"},
+			quotes:          "\"'`",
+			multilineQuotes: "`",
+			rawQuotes:       "`",
+			funcCalls:       true,
+		},
+		Parser: goParser{},
+	})
+
+	Register(&Language{
+		Name:       "C",
+		Extensions: []string{".c", ".h", ".cpp", ".hpp", ".cc", ".cxx"},
+		Lexer: &clikeLexer{
+			keywords: words(TokenType, "void char short int long float double signed unsigned bool size_t ssize_t int8_t int16_t int32_t int64_t uint8_t uint16_t uint32_t uint64_t",
+				words(TokenConstant, "true false NULL nullptr",
+					words(TokenKeyword, "auto break case const continue default do else enum extern for goto if inline register restrict return sizeof static struct switch typedef union volatile while class namespace template typename public private protected virtual new delete this using", nil))),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       "\"'",
+			funcCalls:    true,
+			preprocessor: true,
+		},
+	})
+
+	Register(&Language{
+		Name:       "JSON",
+		Extensions: []string{".json", ".jsonc"},
+		Lexer: &clikeLexer{
+			keywords:        words(TokenConstant, "true false null", nil),
+			lineComments:    []string{"//"},
+			blockComment:    [2]string{"/*", "*/"},
+			quotes:          "\"",
+			keysBeforeColon: true,
+		},
+		Parser: jsonParser{},
+	})
+
+	Register(&Language{
+		Name:       "Shell",
+		Extensions: []string{".sh", ".bash", ".zsh"},
+		FileNames:  []string{".bashrc", ".bash_profile", ".zshrc", ".profile"},
+		Lexer: &clikeLexer{
+			keywords: words(TokenFunction, "echo printf cd ls test read source exit set unset shift eval exec trap",
+				words(TokenKeyword, "if then else elif fi for while until do done case esac in function return local export select break continue", nil)),
+			lineComments:      []string{"#"},
+			commentNeedsSpace: true,
+			quotes:            "\"'",
+			multilineQuotes:   "\"'",
+			rawQuotes:         "'",
+			variables:         true,
+		},
+	})
+
+	Register(&Language{
+		Name:       "YAML",
+		Extensions: []string{".yaml", ".yml"},
+		Lexer:      yamlLexer{},
+	})
+
+	Register(&Language{
+		Name:       "Markdown",
+		Extensions: []string{".md", ".markdown"},
+		Lexer:      markdownLexer{},
+	})
+}
@@ -0,0 +1,87 @@
+package syntax
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadGrammars loads every TextMate (.tmLanguage.json) and Sublime (.sublime-syntax) grammar in dir and
+// registers them as languages. A missing dir isn't an error. Grammars that fail to load are skipped and reported
+// in the returned errors, as are patterns inside a grammar that couldn't be used.
+func LoadGrammars(dir string) []error {
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return []error{err}
+	}
+
+	var errs []error
+	for _, e := range entries {
+
+		if e.IsDir() || (!strings.HasSuffix(e.Name(), ".tmLanguage.json") && !strings.HasSuffix(e.Name(), ".sublime-syntax")) {
+			continue
+		}
+
+		if _, err := LoadGrammarFile(filepath.Join(dir, e.Name())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// LoadGrammarFile loads and registers a single grammar. The grammar is returned even if some of its patterns
+// couldn't be used, in which case the error lists them.
+func LoadGrammarFile(fPath string) (*Grammar, error) {
+
+	data, err := os.ReadFile(fPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var g *Grammar
+	var warnings []string
+	if strings.HasSuffix(fPath, ".sublime-syntax") {
+		g, warnings, err = parseSublime(data)
+	} else {
+		g, warnings, err = parseTextMate(data)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load grammar '%s': %w", fPath, err)
+	}
+
+	if g.Name == "" {
+		g.Name = filepath.Base(fPath)
+		g.Name = g.Name[:strings.IndexByte(g.Name, '.')]
+	}
+
+	if g.ScopeName != "" {
+		grammarsByScope[g.ScopeName] = g
+	}
+
+	lang := &Language{Name: g.Name, Lexer: g}
+	for _, ft := range g.FileTypes {
+
+		//File types are extensions without the dot, but some are whole file names (e.g. 'Makefile')
+		if strings.Contains(ft, ".") || strings.ToLower(ft) != ft {
+			lang.FileNames = append(lang.FileNames, ft)
+		} else {
+			lang.Extensions = append(lang.Extensions, "."+ft)
+		}
+	}
+
+	Register(lang)
+
+	if len(warnings) > 0 {
+		return g, fmt.Errorf("grammar '%s' has unsupported patterns that were skipped:\n%s", fPath, strings.Join(warnings, "\n"))
+	}
+
+	return g, nil
+}
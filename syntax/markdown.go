@@ -0,0 +1,140 @@
+package syntax
+
+import "bytes"
+
+// markdownStateFence means we are inside a fenced code block
+const markdownStateFence State = 1
+
+// markdownLexer colours the block structure of Markdown (headings, fences, quotes, lists) and the common inline elements
+type markdownLexer struct{}
+
+func (markdownLexer) Lex(line []byte, state State, dst []Token) ([]Token, State) {
+
+	trimmed := bytes.TrimLeft(line, " ")
+	indent := len(line) - len(trimmed)
+	isFence := bytes.HasPrefix(trimmed, []byte("```")) || bytes.HasPrefix(trimmed, []byte("~~~"))
+
+	if state == markdownStateFence {
+
+		dst = append(dst, Token{Start: 0, End: len(line), Kind: TokenCode})
+		if isFence {
+			return dst, 0
+		}
+
+		return dst, state
+	}
+
+	if isFence {
+		return append(dst, Token{Start: 0, End: len(line), Kind: TokenCode}), markdownStateFence
+	}
+
+	if len(trimmed) > 0 && trimmed[0] == '#' {
+		return append(dst, Token{Start: indent, End: len(line), Kind: TokenHeading}), 0
+	}
+
+	if len(trimmed) > 0 && trimmed[0] == '>' {
+		return append(dst, Token{Start: indent, End: len(line), Kind: TokenComment}), 0
+	}
+
+	i := indent
+	if markerEnd := listMarkerEnd(line, i); markerEnd != -1 {
+		dst = append(dst, Token{Start: i, End: markerEnd, Kind: TokenOperator})
+		i = markerEnd
+	}
+
+	return markdownInline(line, i, dst), 0
+}
+
+// listMarkerEnd returns the end of a list marker ('- ', '* ', '+ ' or '1. ') at i, or -1 if there isn't one
+func listMarkerEnd(line []byte, i int) int {
+
+	if i+1 < len(line) && (line[i] == '-' || line[i] == '*' || line[i] == '+') && line[i+1] == ' ' {
+		return i + 1
+	}
+
+	j := i
+	for j < len(line) && isDigit(line[j]) {
+		j++
+	}
+
+	if j > i && j+1 < len(line) && (line[j] == '.' || line[j] == ')') && line[j+1] == ' ' {
+		return j + 1
+	}
+
+	return -1
+}
+
+func markdownInline(line []byte, i int, dst []Token) []Token {
+
+	for i < len(line) {
+
+		c := line[i]
+		switch c {
+
+		case '`':
+
+			end := bytes.IndexByte(line[i+1:], '`')
+			if end == -1 {
+				i++
+				continue
+			}
+
+			dst = append(dst, Token{Start: i, End: i + end + 2, Kind: TokenCode})
+			i += end + 2
+
+		case '*', '_':
+
+			//'**x**' and '*x*' (or with underscores), where the delimiter run must be closed by the same run
+			run := 1
+			if i+1 < len(line) && line[i+1] == c {
+				run = 2
+			}
+
+			//Underscores inside words (e.g. snake_case) aren't emphasis
+			delim := line[i : i+run]
+			end := bytes.Index(line[i+run:], delim)
+			if end <= 0 || line[i+run] == ' ' || (c == '_' && i > 0 && isIdentByte(line[i-1])) {
+				i += run
+				continue
+			}
+
+			dst = append(dst, Token{Start: i, End: i + run + end + run, Kind: TokenEmphasis})
+			i += run + end + run
+
+		case '[':
+
+			//[text](url)
+			textEnd := bytes.IndexByte(line[i:], ']')
+			if textEnd == -1 || i+textEnd+1 >= len(line) || line[i+textEnd+1] != '(' {
+				i++
+				continue
+			}
+
+			urlEnd := bytes.IndexByte(line[i+textEnd+1:], ')')
+			if urlEnd == -1 {
+				i++
+				continue
+			}
+
+			end := i + textEnd + 1 + urlEnd + 1
+			dst = append(dst, Token{Start: i, End: end, Kind: TokenLink})
+			i = end
+
+		case '<':
+
+			end := bytes.IndexByte(line[i:], '>')
+			if end == -1 || !bytes.Contains(line[i:i+end], []byte("://")) {
+				i++
+				continue
+			}
+
+			dst = append(dst, Token{Start: i, End: i + end + 1, Kind: TokenLink})
+			i += end + 1
+
+		default:
+			i++
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,406 @@
+package syntax
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// onigRegexp is a TextMate/Sublime (Oniguruma) regexp translated to a Go regexp.
+//
+// Go's regexp has no look-arounds, so a look-behind at the very start and a look-ahead at the very end of a pattern
+// (which is where grammars nearly always put them) are split off and checked separately around each match.
+// Look-arounds anywhere else are dropped, which makes the pattern match a bit more than it should.
+type onigRegexp struct {
+	re *regexp.Regexp
+	//reMid is re with '^' never matching, for searching from the middle of a line
+	reMid *regexp.Regexp
+
+	ahead     *regexp.Regexp
+	aheadNeg  bool
+	behind    *regexp.Regexp
+	behindNeg bool
+
+	//startsWithWordBoundary patterns must also have a word boundary relative to the text before the search start
+	startsWithWordBoundary bool
+}
+
+// find returns the submatch indices of the first match at or after pos in line, or nil
+func (o *onigRegexp) find(line []byte, pos int) []int {
+
+	for pos <= len(line) {
+
+		re := o.re
+		if pos > 0 {
+			re = o.reMid
+		}
+
+		loc := re.FindSubmatchIndex(line[pos:])
+		if loc == nil {
+			return nil
+		}
+
+		for i := 0; i < len(loc); i++ {
+			if loc[i] >= 0 {
+				loc[i] += pos
+			}
+		}
+
+		if o.accepts(line, loc) {
+			return loc
+		}
+
+		//Try again after the start of the rejected match
+		_, size := utf8.DecodeRune(line[loc[0]:])
+		pos = loc[0] + maxInt(size, 1)
+	}
+
+	return nil
+}
+
+func (o *onigRegexp) accepts(line []byte, loc []int) bool {
+
+	if o.startsWithWordBoundary && loc[0] > 0 && loc[0] < len(line) && isIdentByte(line[loc[0]-1]) && isIdentByte(line[loc[0]]) {
+		return false
+	}
+
+	if o.behind != nil && o.behind.Match(line[:loc[0]]) == o.behindNeg {
+		return false
+	}
+
+	if o.ahead != nil && o.ahead.Match(line[loc[1]:]) == o.aheadNeg {
+		return false
+	}
+
+	return true
+}
+
+// compileOnig translates and compiles an Oniguruma pattern
+func compileOnig(pattern string) (*onigRegexp, error) {
+
+	pattern = stripExtendedMode(pattern)
+
+	o := &onigRegexp{}
+
+	//Pull out a look-behind at the start and a look-ahead at the end
+	if strings.HasPrefix(pattern, "(?<=") || strings.HasPrefix(pattern, "(?<!") {
+
+		if end := groupEnd(pattern, 0); end != -1 {
+
+			behind, err := regexp.Compile("(?:" + translateOnig(pattern[4:end]) + ")$")
+			if err != nil {
+				return nil, err
+			}
+
+			o.behind = behind
+			o.behindNeg = pattern[3] == '!'
+			pattern = pattern[end+1:]
+		}
+	}
+
+	if start := trailingLookAhead(pattern); start != -1 {
+
+		ahead, err := regexp.Compile("^(?:" + translateOnig(pattern[start+3:len(pattern)-1]) + ")")
+		if err != nil {
+			return nil, err
+		}
+
+		o.ahead = ahead
+		o.aheadNeg = pattern[start+2] == '!'
+		pattern = pattern[:start]
+	}
+
+	translated := translateOnig(pattern)
+	o.startsWithWordBoundary = strings.HasPrefix(translated, `\b`)
+
+	var err error
+	o.re, err = regexp.Compile(translated)
+	if err != nil {
+		return nil, err
+	}
+
+	o.reMid, err = regexp.Compile(neverMatchCaret(translated))
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// translateOnig rewrites the Oniguruma-only parts of a pattern into Go syntax, dropping what has no equivalent
+func translateOnig(p string) string {
+
+	sb := strings.Builder{}
+	sb.Grow(len(p))
+
+	inClass := false
+	for i := 0; i < len(p); i++ {
+
+		c := p[i]
+		switch {
+
+		case c == '\\' && i+1 < len(p):
+
+			next := p[i+1]
+			i++
+			switch next {
+			case 'h':
+				if inClass {
+					sb.WriteString("0-9a-fA-F")
+				} else {
+					sb.WriteString("[0-9a-fA-F]")
+				}
+			case 'H':
+				sb.WriteString("[^0-9a-fA-F]")
+			case 'G':
+				//Matches where the last match ended, which is always where we search from anyway
+			case 'Z':
+				sb.WriteString("$")
+			case 'e':
+				sb.WriteString(`\x1b`)
+			case 'k':
+				//Named back references can't be supported, so this is left for the compile to fail on
+				sb.WriteString(`\k`)
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(next)
+			}
+
+		case inClass:
+			if c == ']' {
+				inClass = false
+			} else if c == '[' && i+1 < len(p) && p[i+1] == ':' {
+				//POSIX classes like [:alpha:] are passed through whole
+				if end := strings.Index(p[i:], ":]"); end != -1 {
+					sb.WriteString(p[i : i+end+2])
+					i += end + 1
+					continue
+				}
+			} else if c == '&' && i+1 < len(p) && p[i+1] == '&' {
+				//Class intersections are not supported, so the part after them is ignored
+				end := classEnd(p, i)
+				i = end - 1
+				continue
+			}
+			sb.WriteByte(c)
+
+		case c == '[':
+			inClass = true
+			sb.WriteByte(c)
+			//A ']' right after the opening (or after '^') is a literal
+			if i+1 < len(p) && p[i+1] == '^' {
+				sb.WriteByte('^')
+				i++
+			}
+			if i+1 < len(p) && p[i+1] == ']' {
+				sb.WriteString(`\]`)
+				i++
+			}
+
+		case c == '(' && strings.HasPrefix(p[i:], "(?>"):
+			//Atomic groups become normal groups
+			sb.WriteString("(?:")
+			i += 2
+
+		case c == '(' && strings.HasPrefix(p[i:], "(?<") && i+3 < len(p) && p[i+3] != '=' && p[i+3] != '!':
+			sb.WriteString("(?P<")
+			i += 2
+
+		case c == '(' && (strings.HasPrefix(p[i:], "(?=") || strings.HasPrefix(p[i:], "(?!") || strings.HasPrefix(p[i:], "(?<=") || strings.HasPrefix(p[i:], "(?<!")):
+			//Look-arounds in the middle of a pattern are dropped
+			if end := groupEnd(p, i); end != -1 {
+				i = end
+				continue
+			}
+			sb.WriteByte(c)
+
+		case (c == '+') && i > 0 && isQuantifierEnd(p, i-1):
+			//Possessive quantifiers become greedy ones
+
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String()
+}
+
+// isQuantifierEnd returns true if p[i] ends a quantifier (so a following '+' makes it possessive)
+func isQuantifierEnd(p string, i int) bool {
+
+	if i > 0 && p[i-1] == '\\' {
+		return false
+	}
+
+	return p[i] == '*' || p[i] == '+' || p[i] == '?' || p[i] == '}'
+}
+
+// groupEnd returns the index of the ')' closing the group that opens at p[start], or -1
+func groupEnd(p string, start int) int {
+
+	depth := 0
+	inClass := false
+	for i := start; i < len(p); i++ {
+
+		switch c := p[i]; {
+		case c == '\\':
+			i++
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+			if i+1 < len(p) && p[i+1] == ']' {
+				i++
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// classEnd returns the index of the ']' that closes the class p[i] is in, skipping nested classes (e.g. in '[a-z&&[^b]]')
+func classEnd(p string, i int) int {
+
+	depth := 0
+	for ; i < len(p); i++ {
+
+		switch p[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+
+	return len(p)
+}
+
+// trailingLookAhead returns where a look-ahead group that ends the pattern starts, or -1
+func trailingLookAhead(p string) int {
+
+	if !strings.HasSuffix(p, ")") {
+		return -1
+	}
+
+	for i := 0; i < len(p); i++ {
+
+		if p[i] == '\\' {
+			i++
+			continue
+		}
+
+		if p[i] != '(' {
+			continue
+		}
+
+		end := groupEnd(p, i)
+		if end == len(p)-1 && (strings.HasPrefix(p[i:], "(?=") || strings.HasPrefix(p[i:], "(?!")) {
+			return i
+		}
+
+		//Only top level groups can be the trailing one
+		if end != -1 {
+			i = end
+		}
+	}
+
+	return -1
+}
+
+// neverMatchCaret replaces every '^' anchor outside classes with something that can't match
+func neverMatchCaret(p string) string {
+
+	sb := strings.Builder{}
+	inClass := false
+	for i := 0; i < len(p); i++ {
+
+		c := p[i]
+		switch {
+		case c == '\\' && i+1 < len(p):
+			sb.WriteByte(c)
+			sb.WriteByte(p[i+1])
+			i++
+			continue
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+			if i+1 < len(p) && p[i+1] == '^' {
+				sb.WriteString("[^")
+				i++
+				continue
+			}
+		case c == '^':
+			sb.WriteString(`[^\x00-\x{10FFFF}]`)
+			continue
+		}
+
+		sb.WriteByte(c)
+	}
+
+	return sb.String()
+}
+
+// stripExtendedMode removes the whitespace and comments of patterns that use the (?x) flag, which Go doesn't have
+func stripExtendedMode(p string) string {
+
+	if !strings.HasPrefix(p, "(?x)") {
+		return p
+	}
+
+	p = p[4:]
+	sb := strings.Builder{}
+	inClass := false
+	for i := 0; i < len(p); i++ {
+
+		c := p[i]
+		switch {
+		case c == '\\' && i+1 < len(p):
+			sb.WriteByte(c)
+			sb.WriteByte(p[i+1])
+			i++
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+			sb.WriteByte(c)
+		case c == '[':
+			inClass = true
+			sb.WriteByte(c)
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == '#':
+			for i < len(p) && p[i] != '\n' {
+				i++
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String()
+}
+
+func maxInt(x, y int) int {
+
+	if x > y {
+		return x
+	}
+
+	return y
+}
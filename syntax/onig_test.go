@@ -0,0 +1,77 @@
+package syntax
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOnigFind(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		pattern string
+		line    string
+		pos     int
+
+		//expect is the [start, end) of the match, or nil if there should be none
+		expect []int
+	}{
+		{"hex digit", `\h+`, "zz0aF9g", 0, []int{2, 6}},
+		{"hex digit in class", `[\h_]+`, "x1_f", 0, []int{1, 4}},
+		{"not hex digit", `\H+`, "12xyz3", 0, []int{2, 5}},
+		{"\\G is where the search starts", `\Gab`, "xxab", 2, []int{2, 4}},
+		{"\\Z is the line end", `b\Z`, "ab", 0, []int{1, 2}},
+		{"escape char", `\e\[`, "x\x1b[", 0, []int{1, 3}},
+		{"atomic group", `(?>if|in)\b`, "x in", 0, []int{2, 4}},
+		{"named group", `(?<word>[a-z]+)=`, "  key=1", 0, []int{2, 6}},
+		{"possessive star", `a*+b`, "aaab", 0, []int{0, 4}},
+		{"possessive plus", `\d++`, "x42", 0, []int{1, 3}},
+		{"escaped plus isn't possessive", `a\++`, "a++", 0, []int{0, 3}},
+		{"posix class", `[[:alpha:]]+`, "12abc3", 0, []int{2, 5}},
+		{"leading ] is literal", `[]a]+`, "x]a]", 0, []int{1, 4}},
+		{"leading ] after ^ is literal", `[^]a]+`, "]]bc", 0, []int{2, 4}},
+		{"class intersection keeps the first part", `[a-z&&[^b]]+`, "abc", 0, []int{0, 3}},
+
+		{"look-behind", `(?<=\.)\w+`, "a.b", 0, []int{2, 3}},
+		{"look-behind rejects", `(?<=\.)\w+`, "ab", 0, nil},
+		{"negative look-behind", `(?<!\$)\b\w+`, "$a b", 0, []int{3, 4}},
+		{"look-behind sees text before pos", `(?<=:)\w+`, "a:b", 2, []int{2, 3}},
+		{"look-ahead", `\w+(?=\()`, "a b(", 0, []int{2, 3}},
+		{"negative look-ahead", `\d+(?!px)\b`, "1px 2em 3", 0, []int{8, 9}},
+		{"look-ahead with groups inside", `\w+(?=\s*(?:=|:))`, "a b = 1", 0, []int{2, 3}},
+		{"look-around in the middle is dropped", `a(?=b)\w`, "ac", 0, []int{0, 2}},
+
+		{"extended mode", "(?x) a \\  b # comment", "xa b", 0, []int{1, 4}},
+		{"extended mode keeps class spaces", "(?x) [ ]+ x", "a  x", 0, []int{1, 4}},
+		{"caret at line start", `^\w+`, "ab cd", 0, []int{0, 2}},
+		{"caret after line start", `^\w+`, "ab cd", 2, nil},
+		{"caret in class", `[^a]+`, "aab", 1, []int{2, 3}},
+		{"word boundary counts the text before pos", `\bfoo`, "xfoo foo", 1, []int{5, 8}},
+	}
+
+	for _, tt := range tests {
+
+		o, err := compileOnig(tt.pattern)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		loc := o.find([]byte(tt.line), tt.pos)
+		if loc != nil {
+			loc = loc[:2]
+		}
+
+		if !reflect.DeepEqual(loc, tt.expect) {
+			t.Errorf("%s: pattern %q on %q from %d: expected %v but got %v", tt.name, tt.pattern, tt.line, tt.pos, tt.expect, loc)
+		}
+	}
+}
+
+func TestOnigUnsupported(t *testing.T) {
+
+	//Named back references have no Go equivalent, so the pattern must fail to compile instead of matching something else
+	if _, err := compileOnig(`(?<q>['"]).*\k<q>`); err == nil {
+		t.Error("expected named back references to fail")
+	}
+}
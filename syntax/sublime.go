@@ -0,0 +1,251 @@
+package syntax
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+)
+
+var sublimeVarRegexp = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// parseSublime loads a .sublime-syntax grammar. Patterns that can't be translated to Go regexps
+// are skipped and returned as warnings.
+func parseSublime(data []byte) (g *Grammar, warnings []string, err error) {
+
+	doc, err := parseYAML(string(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, nil, errors.New("sublime syntax must be a mapping")
+	}
+
+	ctxs, ok := root["contexts"].(map[string]interface{})
+	if !ok || ctxs["main"] == nil {
+		return nil, nil, errors.New("sublime syntax has no main context")
+	}
+
+	name, _ := root["name"].(string)
+	scopeName, _ := root["scope"].(string)
+
+	var fileTypes []string
+	exts, _ := root["file_extensions"].([]interface{})
+	for _, ext := range exts {
+		if s, ok := ext.(string); ok {
+			fileTypes = append(fileTypes, s)
+		}
+	}
+
+	g = newGrammar(name, scopeName, fileTypes, true)
+	c := &sublimeConverter{tmConverter: tmConverter{g: g}, rawVars: map[string]string{}, vars: map[string]string{}}
+	if vars, ok := root["variables"].(map[string]interface{}); ok {
+		for k, v := range vars {
+			c.rawVars[k], _ = v.(string)
+		}
+	}
+
+	//Contexts are created first so rules can point at them while being converted
+	for name := range ctxs {
+		g.contexts[name] = &context{grammar: g}
+	}
+
+	for name, items := range ctxs {
+		list, _ := items.([]interface{})
+		c.fillContext(g.contexts[name], list)
+	}
+
+	g.prototype = g.contexts["prototype"]
+	g.finish(g.contexts["main"])
+
+	return g, c.warnings, nil
+}
+
+type sublimeConverter struct {
+	tmConverter
+
+	rawVars map[string]string
+	vars    map[string]string
+}
+
+func (c *sublimeConverter) fillContext(ctx *context, items []interface{}) {
+
+	for _, item := range items {
+
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if v, ok := m["meta_scope"].(string); ok {
+			ctx.metaScope = v
+		}
+
+		if v, ok := m["meta_content_scope"].(string); ok {
+			ctx.contentScope = v
+		}
+
+		if v, ok := m["meta_include_prototype"].(string); ok && v == "false" {
+			ctx.noPrototype = true
+		}
+
+		if inc, ok := m["include"].(string); ok {
+			ctx.rules = append(ctx.rules, &rule{grammar: c.g, include: inc})
+			continue
+		}
+
+		if _, ok := m["match"]; ok {
+			if r := c.rule(m); r != nil {
+				ctx.rules = append(ctx.rules, r)
+			}
+		}
+	}
+}
+
+func (c *sublimeConverter) rule(m map[string]interface{}) *rule {
+
+	pattern, _ := m["match"].(string)
+	re := c.compile(c.expandVars(pattern, 0))
+	if re == nil {
+		return nil
+	}
+
+	r := &rule{grammar: c.g, re: re}
+	r.scope, _ = m["scope"].(string)
+	r.captures = sublimeCaptures(m["captures"])
+
+	//pop is either true or the number of contexts to pop
+	if pop, ok := m["pop"].(string); ok {
+		if pop == "true" {
+			r.pop = 1
+		} else if n, err := strconv.Atoi(pop); err == nil && n > 0 {
+			r.pop = n
+		}
+	}
+
+	if v, ok := m["push"]; ok {
+		r.push = c.contextRefs(v)
+	}
+
+	if v, ok := m["set"]; ok {
+		r.setCtxs = c.contextRefs(v)
+	}
+
+	//Embedding is a push of a context that includes the other grammar and ends at the escape pattern
+	if embed, ok := m["embed"].(string); ok {
+
+		escape, _ := m["escape"].(string)
+		ctx := &context{
+			grammar:      c.g,
+			contentScope: strOr(m["embed_scope"], ""),
+			endSource:    c.expandVars(escape, 0),
+			endCaptures:  sublimeCaptures(m["escape_captures"]),
+			noPrototype:  true,
+			rules:        []*rule{{grammar: c.g, include: embed}},
+		}
+
+		ctx.endHasBackref = backrefRegexp.MatchString(ctx.endSource)
+		if !ctx.endHasBackref {
+			if ctx.end = c.compile(ctx.endSource); ctx.end == nil {
+				return nil
+			}
+		}
+
+		r.push = []contextRef{{ctx: ctx}}
+	}
+
+	return r
+}
+
+// contextRefs converts the value of a push or set, which is a context name, an anonymous context
+// (a list of rules), or a list of either.
+func (c *sublimeConverter) contextRefs(v interface{}) []contextRef {
+
+	switch v := v.(type) {
+
+	case string:
+		return []contextRef{{name: v}}
+
+	case []interface{}:
+
+		if len(v) > 0 {
+			if _, isRule := v[0].(map[string]interface{}); isRule {
+				return []contextRef{{ctx: c.anonContext(v)}}
+			}
+		}
+
+		refs := make([]contextRef, 0, len(v))
+		for _, item := range v {
+			switch item := item.(type) {
+			case string:
+				refs = append(refs, contextRef{name: item})
+			case []interface{}:
+				refs = append(refs, contextRef{ctx: c.anonContext(item)})
+			}
+		}
+
+		return refs
+	}
+
+	return nil
+}
+
+func (c *sublimeConverter) anonContext(items []interface{}) *context {
+	ctx := &context{grammar: c.g}
+	c.fillContext(ctx, items)
+	return ctx
+}
+
+// expandVars replaces '{{name}}' with the value of the variable, which can itself use other variables
+func (c *sublimeConverter) expandVars(pattern string, depth int) string {
+
+	//Guards against variables that refer to themselves
+	if depth > 10 {
+		return pattern
+	}
+
+	return sublimeVarRegexp.ReplaceAllStringFunc(pattern, func(ref string) string {
+
+		name := ref[2 : len(ref)-2]
+		if v, ok := c.vars[name]; ok {
+			return v
+		}
+
+		raw, ok := c.rawVars[name]
+		if !ok {
+			c.warnings = append(c.warnings, "unknown variable '"+name+"'")
+			return ""
+		}
+
+		v := c.expandVars(raw, depth+1)
+		c.vars[name] = v
+		return v
+	})
+}
+
+func sublimeCaptures(v interface{}) map[int]string {
+
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return nil
+	}
+
+	out := make(map[int]string, len(m))
+	for k, scope := range m {
+		if group, err := strconv.Atoi(k); err == nil {
+			out[group], _ = scope.(string)
+		}
+	}
+
+	return out
+}
+
+func strOr(v interface{}, fallback string) string {
+
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	return fallback
+}
@@ -0,0 +1,135 @@
+// Package syntax splits lines of text into tokens (keywords, strings, comments etc.) so they can be coloured
+package syntax
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+type TokenKind uint8
+
+const (
+	TokenText TokenKind = iota
+	TokenKeyword
+	TokenType
+	TokenConstant
+	TokenNumber
+	TokenString
+	TokenComment
+	TokenFunction
+	TokenOperator
+	TokenKey
+	TokenVariable
+	TokenPreprocessor
+	TokenHeading
+	TokenEmphasis
+	TokenLink
+	TokenCode
+
+	TokenKindCount
+)
+
+var tokenKindNames = [TokenKindCount]string{
+	TokenText:         "text",
+	TokenKeyword:      "keyword",
+	TokenType:         "type",
+	TokenConstant:     "constant",
+	TokenNumber:       "number",
+	TokenString:       "string",
+	TokenComment:      "comment",
+	TokenFunction:     "function",
+	TokenOperator:     "operator",
+	TokenKey:          "key",
+	TokenVariable:     "variable",
+	TokenPreprocessor: "preprocessor",
+	TokenHeading:      "heading",
+	TokenEmphasis:     "emphasis",
+	TokenLink:         "link",
+	TokenCode:         "code",
+}
+
+// String returns the name of the kind, which is also what themes use to give it a colour
+func (k TokenKind) String() string {
+
+	if k >= TokenKindCount {
+		return "text"
+	}
+
+	return tokenKindNames[k]
+}
+
+// Token is the byte range [Start, End) of a line that is of one kind. Parts of a line not covered by a token are plain text.
+type Token struct {
+	Start int
+	End   int
+	Kind  TokenKind
+}
+
+// State is what a lexer needs to know about the lines before the current one (e.g. that we are inside a block comment).
+// Its meaning is up to each lexer, except that zero is always the state at the start of a file.
+type State int
+
+type Lexer interface {
+	// Lex appends the tokens of line (which has no newline) to dst, given the state at the end of the previous line.
+	// It returns the tokens and the state at the end of this line.
+	Lex(line []byte, state State, dst []Token) ([]Token, State)
+}
+
+type Language struct {
+	Name string
+
+	//Extensions are file extensions including the dot (e.g. '.go'), and FileNames are whole file names (e.g. '.bashrc')
+	Extensions []string
+	FileNames  []string
+
+	Lexer Lexer
+	//Parser is nil for languages without structural features (e.g. bracket matching and folding)
+	Parser Parser
+}
+
+var (
+	languages        []*Language
+	languagesByExt   = map[string]*Language{}
+	languagesByFName = map[string]*Language{}
+)
+
+// Register adds a language to the registry. A language registered later takes over any extensions of ones registered before it.
+func Register(lang *Language) {
+
+	languages = append(languages, lang)
+	for _, ext := range lang.Extensions {
+		languagesByExt[strings.ToLower(ext)] = lang
+	}
+
+	for _, fName := range lang.FileNames {
+		languagesByFName[fName] = lang
+	}
+}
+
+// ForFile returns the language of a file based on its name, or nil if it isn't known
+func ForFile(fileName string) *Language {
+
+	base := filepath.Base(fileName)
+	if lang, ok := languagesByFName[base]; ok {
+		return lang
+	}
+
+	return languagesByExt[strings.ToLower(filepath.Ext(base))]
+}
+
+// ByName returns the registered language with the given name (ignoring case), or nil
+func ByName(name string) *Language {
+
+	for i := len(languages) - 1; i >= 0; i-- {
+		if strings.EqualFold(languages[i].Name, name) {
+			return languages[i]
+		}
+	}
+
+	return nil
+}
+
+// Languages returns all registered languages in the order they were registered
+func Languages() []*Language {
+	return languages
+}
@@ -0,0 +1,208 @@
+package syntax
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// tmRule is a rule of a .tmLanguage.json grammar
+type tmRule struct {
+	Include string `json:"include"`
+
+	Name        string `json:"name"`
+	ContentName string `json:"contentName"`
+
+	Match    string               `json:"match"`
+	Captures map[string]tmCapture `json:"captures"`
+
+	Begin         string               `json:"begin"`
+	BeginCaptures map[string]tmCapture `json:"beginCaptures"`
+	End           string               `json:"end"`
+	EndCaptures   map[string]tmCapture `json:"endCaptures"`
+	//While rules continue for as long as each line starts with the pattern. They are approximated as ending with their first line.
+	While               string               `json:"while"`
+	WhileCaptures       map[string]tmCapture `json:"whileCaptures"`
+	ApplyEndPatternLast interface{}          `json:"applyEndPatternLast"`
+
+	Patterns   []tmRule          `json:"patterns"`
+	Repository map[string]tmRule `json:"repository"`
+}
+
+type tmCapture struct {
+	Name string `json:"name"`
+}
+
+type tmGrammar struct {
+	Name       string            `json:"name"`
+	ScopeName  string            `json:"scopeName"`
+	FileTypes  []string          `json:"fileTypes"`
+	Patterns   []tmRule          `json:"patterns"`
+	Repository map[string]tmRule `json:"repository"`
+}
+
+// parseTextMate loads a TextMate grammar in its JSON form. Patterns that can't be translated to Go regexps
+// are skipped and returned as warnings.
+func parseTextMate(data []byte) (g *Grammar, warnings []string, err error) {
+
+	tg := tmGrammar{}
+	if err := json.Unmarshal(data, &tg); err != nil {
+		return nil, nil, err
+	}
+
+	g = newGrammar(tg.Name, tg.ScopeName, tg.FileTypes, false)
+	c := &tmConverter{g: g}
+
+	//Repository entries are created first so rules can point at them while being converted
+	for name := range tg.Repository {
+		g.contexts[name] = &context{grammar: g}
+	}
+
+	for name, r := range tg.Repository {
+		c.fillRepositoryContext(g.contexts[name], &r)
+	}
+
+	main := &context{grammar: g}
+	main.rules = c.rules(tg.Patterns)
+	g.finish(main)
+
+	return g, c.warnings, nil
+}
+
+type tmConverter struct {
+	g        *Grammar
+	warnings []string
+}
+
+func (c *tmConverter) fillRepositoryContext(ctx *context, r *tmRule) {
+
+	//Nested repositories are flattened into the grammar one
+	for name, nested := range r.Repository {
+		if _, ok := c.g.contexts[name]; !ok {
+			nestedCtx := &context{grammar: c.g}
+			c.g.contexts[name] = nestedCtx
+			c.fillRepositoryContext(nestedCtx, &nested)
+		}
+	}
+
+	if r.Match == "" && r.Begin == "" && r.Include == "" {
+		ctx.rules = c.rules(r.Patterns)
+		return
+	}
+
+	if conv := c.rule(r); conv != nil {
+		ctx.rules = []*rule{conv}
+	}
+}
+
+func (c *tmConverter) rules(trs []tmRule) []*rule {
+
+	rules := make([]*rule, 0, len(trs))
+	for i := 0; i < len(trs); i++ {
+		if r := c.rule(&trs[i]); r != nil {
+			rules = append(rules, r)
+		}
+	}
+
+	return rules
+}
+
+func (c *tmConverter) rule(tr *tmRule) *rule {
+
+	switch {
+
+	case tr.Include != "":
+		return &rule{grammar: c.g, include: tr.Include}
+
+	case tr.Match != "":
+
+		re := c.compile(tr.Match)
+		if re == nil {
+			return nil
+		}
+
+		return &rule{grammar: c.g, re: re, scope: tr.Name, captures: tmCaptures(tr.Captures)}
+
+	case tr.Begin != "":
+
+		re := c.compile(tr.Begin)
+		if re == nil {
+			return nil
+		}
+
+		beginCaptures := tr.BeginCaptures
+		if beginCaptures == nil {
+			beginCaptures = tr.Captures
+		}
+
+		endCaptures := tr.EndCaptures
+		if endCaptures == nil {
+			endCaptures = tr.Captures
+		}
+
+		end := tr.End
+		if tr.While != "" {
+			end = "$"
+			endCaptures = tr.WhileCaptures
+		}
+
+		ctx := &context{
+			grammar:      c.g,
+			metaScope:    tr.Name,
+			contentScope: tr.ContentName,
+			endSource:    end,
+			endCaptures:  tmCaptures(endCaptures),
+			endLast:      tr.ApplyEndPatternLast == true || tr.ApplyEndPatternLast == 1.0,
+			rules:        c.rules(tr.Patterns),
+		}
+
+		ctx.endHasBackref = backrefRegexp.MatchString(end)
+		if !ctx.endHasBackref {
+
+			//A context that can never end would swallow the rest of the file
+			ctx.end = c.compile(end)
+			if ctx.end == nil {
+				return nil
+			}
+		}
+
+		return &rule{grammar: c.g, re: re, captures: tmCaptures(beginCaptures), push: []contextRef{{ctx: ctx}}}
+
+	case len(tr.Patterns) > 0:
+
+		//A rule that is only a list of patterns works like an include of them
+		ctx := &context{grammar: c.g, rules: c.rules(tr.Patterns)}
+		name := "\x00anon" + strconv.Itoa(len(c.g.contexts))
+		c.g.contexts[name] = ctx
+		return &rule{grammar: c.g, include: "#" + name}
+	}
+
+	return nil
+}
+
+func (c *tmConverter) compile(pattern string) *onigRegexp {
+
+	re, err := compileOnig(pattern)
+	if err != nil {
+		c.warnings = append(c.warnings, "skipped pattern '"+pattern+"': "+err.Error())
+		return nil
+	}
+
+	return re
+}
+
+func tmCaptures(caps map[string]tmCapture) map[int]string {
+
+	if len(caps) == 0 {
+		return nil
+	}
+
+	out := make(map[int]string, len(caps))
+	for k, v := range caps {
+		if group, err := strconv.Atoi(strings.TrimSpace(k)); err == nil {
+			out[group] = v.Name
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,397 @@
+package syntax
+
+import (
+	"sort"
+
+	"github.com/bloeys/gopad/buffer"
+)
+
+type NodeKind int
+
+const (
+	NodeRoot NodeKind = iota
+	//NodeBraces, NodeParens and NodeBrackets are the text between (and including) a pair of '{}', '()' or '[]'
+	NodeBraces
+	NodeParens
+	NodeBrackets
+	NodeString
+	NodeComment
+	//NodeWord is an identifier, keyword, number or other literal
+	NodeWord
+	//NodeStatement groups the nodes of one statement (or top level declaration) in a block
+	NodeStatement
+	//NodePair is a key and its value in an object (e.g. JSON)
+	NodePair
+)
+
+// Node is a range of the document in a syntax tree. Children are sorted and don't overlap.
+type Node struct {
+	Kind NodeKind
+
+	//Start and End are byte offsets of the range [Start, End)
+	Start int
+	End   int
+
+	//Unclosed is true for delimited nodes whose closing delimiter is missing, in which case they end where their parent does
+	Unclosed bool
+
+	Parent   *Node
+	Children []*Node
+}
+
+// IsDelimited returns true if the node starts and ends with a pair of brackets
+func (n *Node) IsDelimited() bool {
+	return n.Kind == NodeBraces || n.Kind == NodeParens || n.Kind == NodeBrackets
+}
+
+// Inner returns the range inside the delimiters of the node, or the whole node if it isn't delimited
+func (n *Node) Inner() (start, end int) {
+
+	if !n.IsDelimited() {
+		return n.Start, n.End
+	}
+
+	if n.Unclosed {
+		return n.Start + 1, n.End
+	}
+
+	return n.Start + 1, n.End - 1
+}
+
+// Parser builds the syntax tree of a language
+type Parser interface {
+	// Parse adds the nodes found in src to parent, where src starts at byte offset base of the document.
+	// It returns false if src isn't balanced on its own (e.g. has a closing bracket without an opening one or an
+	// unterminated block comment), which means the nodes depend on the text around src.
+	Parse(src []byte, base int, parent *Node) bool
+}
+
+// FoldRange is a range of lines that can be folded. StartLine stays visible, while the lines after it up to and
+// including EndLine are hidden.
+type FoldRange struct {
+	StartLine int
+	EndLine   int
+}
+
+// Tree is the syntax tree of a buffer, which is updated as the buffer is edited.
+//
+// After an edit only the statements (or pairs, or other children) of the innermost delimited node that touch it are
+// parsed again, as long as they come out balanced. Otherwise more of the node's children are parsed, then its parent,
+// and so on up to the root.
+type Tree struct {
+	Lang *Language
+
+	buf        *buffer.Buffer
+	listenerID buffer.ListenerID
+	root       *Node
+
+	folds     []FoldRange
+	foldsDone bool
+}
+
+// Root returns the root of the tree, parsing the whole buffer the first time it is needed
+func (t *Tree) Root() *Node {
+
+	if t.root == nil {
+		t.root = &Node{Kind: NodeRoot, End: t.buf.Len()}
+		t.Lang.Parser.Parse(t.buf.Bytes(), 0, t.root)
+	}
+
+	return t.root
+}
+
+func (t *Tree) edit(off, removed, inserted int) {
+
+	t.foldsDone = false
+	if t.root == nil {
+		return
+	}
+
+	//Find the innermost node whose contents hold the whole edit
+	oldEnd := off + removed
+	n := t.root
+	for {
+
+		var next *Node
+		for _, c := range n.Children {
+
+			if c.Start > off {
+				break
+			}
+
+			//Statements and pairs aren't parsed on their own, but they can hold delimited nodes
+			if (c.Kind == NodeStatement || c.Kind == NodePair) && oldEnd <= c.End {
+				next = c
+				break
+			}
+
+			innerStart, innerEnd := c.Inner()
+			if c.IsDelimited() && !c.Unclosed && innerStart <= off && oldEnd <= innerEnd {
+				next = c
+				break
+			}
+		}
+
+		if next == nil {
+			break
+		}
+
+		n = next
+	}
+
+	for n.Kind == NodeStatement || n.Kind == NodePair {
+		n = n.Parent
+	}
+
+	//@PERF: Shifting touches every node after the edit. Storing offsets relative to the parent would avoid that.
+	delta := inserted - removed
+	for a := n; a != nil; a = a.Parent {
+
+		a.End += delta
+		if a.Parent == nil {
+			continue
+		}
+
+		siblings := a.Parent.Children
+		for i := len(siblings) - 1; i >= 0 && siblings[i] != a; i-- {
+			shiftNode(siblings[i], delta)
+		}
+	}
+
+	//The children that touch the edit are parsed again, and the ones after them are moved
+	first := sort.Search(len(n.Children), func(i int) bool { return n.Children[i].End >= off })
+	last := sort.Search(len(n.Children), func(i int) bool { return n.Children[i].Start > oldEnd })
+	for _, c := range n.Children[last:] {
+		shiftNode(c, delta)
+	}
+
+	t.reparse(n, first, last)
+}
+
+// reparse parses the children [first, last) of n again, along with a sibling on each side since an edit can join
+// a child with its neighbours (e.g. a value typed after a key). While the result isn't balanced more siblings are added,
+// then n is parsed again as part of its parent, up to the root.
+//
+// The children outside of [first, last) must already be where they are after the edit.
+func (t *Tree) reparse(n *Node, first, last int) {
+
+	for {
+
+		for step := 1; ; step *= 2 {
+
+			first = maxInt(first-step, 0)
+			last = minInt(last+step, len(n.Children))
+
+			start, end := n.Inner()
+			if first > 0 {
+				start = n.Children[first-1].End
+			}
+			if last < len(n.Children) {
+				end = n.Children[last].Start
+			}
+
+			//The new children are parsed into a node of the same kind, since statements and pairs depend on it
+			tmp := &Node{Kind: n.Kind, Start: start, End: end}
+			balanced := t.Lang.Parser.Parse(t.buf.Slice(start, end-start), start, tmp)
+			whole := first == 0 && last == len(n.Children)
+			if balanced || (whole && n.Kind == NodeRoot) {
+
+				for _, c := range tmp.Children {
+					c.Parent = n
+				}
+
+				children := make([]*Node, 0, len(n.Children)-(last-first)+len(tmp.Children))
+				children = append(children, n.Children[:first]...)
+				children = append(children, tmp.Children...)
+				n.Children = append(children, n.Children[last:]...)
+				return
+			}
+
+			if whole {
+				break
+			}
+		}
+
+		//The contents of n depend on the text around it, so the child of the parent that holds n is parsed again
+		child := n
+		n = n.Parent
+		for n.Kind == NodeStatement || n.Kind == NodePair {
+			child = n
+			n = n.Parent
+		}
+
+		first = 0
+		for n.Children[first] != child {
+			first++
+		}
+		last = first + 1
+	}
+}
+
+// shiftNode moves n and everything in it by delta bytes
+func shiftNode(n *Node, delta int) {
+
+	n.Start += delta
+	n.End += delta
+	for _, c := range n.Children {
+		shiftNode(c, delta)
+	}
+}
+
+// NodeAt returns the innermost node that covers the range [start, end)
+func (t *Tree) NodeAt(start, end int) *Node {
+
+	n := t.Root()
+	for {
+
+		var next *Node
+		for _, c := range n.Children {
+
+			if c.Start > start {
+				break
+			}
+
+			if end <= c.End && (start < c.End || start == end) {
+				next = c
+				break
+			}
+		}
+
+		if next == nil {
+			return n
+		}
+
+		n = next
+	}
+}
+
+// ExpandSelection returns the smallest range of a node (or the contents of a delimited node) that holds the range [start, end)
+// and is bigger than it. ok is false if the range already covers the whole document.
+func (t *Tree) ExpandSelection(start, end int) (newStart, newEnd int, ok bool) {
+
+	for n := t.NodeAt(start, end); n != nil; n = n.Parent {
+
+		if n.IsDelimited() || (n.Kind == NodeString && n.End-n.Start >= 2) {
+			innerStart, innerEnd := n.Start+1, n.End-1
+			if n.Unclosed {
+				innerEnd = n.End
+			}
+
+			if innerStart <= start && end <= innerEnd && innerEnd-innerStart > end-start {
+				return innerStart, innerEnd, true
+			}
+		}
+
+		if n.Start <= start && end <= n.End && n.End-n.Start > end-start {
+			return n.Start, n.End, true
+		}
+	}
+
+	return start, end, false
+}
+
+// MatchingBracket returns the offsets of a pair of brackets where one of them is right after or right before off.
+// The bracket after off is preferred. Brackets in strings and comments aren't matched.
+func (t *Tree) MatchingBracket(off int) (open, close int, ok bool) {
+
+	for _, at := range [2]int{off, off - 1} {
+
+		if at < 0 {
+			continue
+		}
+
+		for n := t.NodeAt(at, at+1); n != nil; n = n.Parent {
+			if n.IsDelimited() && !n.Unclosed && (n.Start == at || n.End-1 == at) {
+				return n.Start, n.End - 1, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// FoldRanges returns the ranges of lines that can be folded, sorted by their start line.
+// No two ranges start on the same line.
+func (t *Tree) FoldRanges() []FoldRange {
+
+	if t.foldsDone {
+		return t.folds
+	}
+
+	t.folds = t.folds[:0]
+	t.addFolds(t.Root())
+
+	sort.SliceStable(t.folds, func(i, j int) bool {
+		return t.folds[i].StartLine < t.folds[j].StartLine
+	})
+
+	//Of the ranges that start on the same line the biggest one is kept
+	out := t.folds[:0]
+	for _, f := range t.folds {
+
+		if n := len(out); n > 0 && out[n-1].StartLine == f.StartLine {
+			if f.EndLine > out[n-1].EndLine {
+				out[n-1] = f
+			}
+			continue
+		}
+
+		out = append(out, f)
+	}
+
+	t.folds = out
+	t.foldsDone = true
+	return t.folds
+}
+
+func (t *Tree) addFolds(n *Node) {
+
+	for _, c := range n.Children {
+
+		if c.IsDelimited() || c.Kind == NodeComment || c.Kind == NodeString {
+
+			startLine := t.buf.OffsetToLine(c.Start)
+			endLine := t.buf.OffsetToLine(c.End - 1)
+
+			//The line with the closing bracket stays visible
+			if c.IsDelimited() && !c.Unclosed {
+				endLine--
+			}
+
+			if endLine > startLine {
+				t.folds = append(t.folds, FoldRange{StartLine: startLine, EndLine: endLine})
+			}
+		}
+
+		t.addFolds(c)
+	}
+}
+
+// FoldAt returns the fold range that starts on lineNum
+func (t *Tree) FoldAt(lineNum int) (FoldRange, bool) {
+
+	folds := t.FoldRanges()
+	i := sort.Search(len(folds), func(i int) bool { return folds[i].StartLine >= lineNum })
+	if i < len(folds) && folds[i].StartLine == lineNum {
+		return folds[i], true
+	}
+
+	return FoldRange{}, false
+}
+
+// NewTree returns the syntax tree of buf, which stays up to date as buf is edited. lang must have a parser.
+func NewTree(lang *Language, buf *buffer.Buffer) *Tree {
+
+	t := &Tree{
+		Lang: lang,
+		buf:  buf,
+	}
+
+	t.listenerID = buf.Listen(t.edit)
+	return t
+}
+
+// Close stops the tree from following the edits of its buffer, after which it must not be used
+func (t *Tree) Close() {
+	t.buf.Unlisten(t.listenerID)
+}
@@ -0,0 +1,174 @@
+package syntax
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bloeys/gopad/buffer"
+)
+
+// countingParser counts the bytes its parser is given
+type countingParser struct {
+	Parser
+	parsed int
+}
+
+func (p *countingParser) Parse(src []byte, base int, parent *Node) bool {
+	p.parsed += len(src)
+	return p.Parser.Parse(src, base, parent)
+}
+
+// dumpTree writes n and everything in it on one line, so two trees can be compared
+func dumpTree(n *Node) string {
+
+	sb := strings.Builder{}
+	var dump func(n *Node)
+	dump = func(n *Node) {
+
+		fmt.Fprintf(&sb, "%d[%d,%d", n.Kind, n.Start, n.End)
+		if n.Unclosed {
+			sb.WriteString(" unclosed")
+		}
+
+		for _, c := range n.Children {
+			if c.Parent != n {
+				sb.WriteString(" wrong parent")
+			}
+			sb.WriteByte(' ')
+			dump(c)
+		}
+
+		sb.WriteByte(']')
+	}
+
+	dump(n)
+	return sb.String()
+}
+
+func parseWhole(parser Parser, text string) string {
+	root := &Node{Kind: NodeRoot, End: len(text)}
+	parser.Parse([]byte(text), 0, root)
+	return dumpTree(root)
+}
+
+func TestTreeEdit(t *testing.T) {
+
+	goSrc := "package main\n\nimport \"fmt\"\n\n// main prints\nfunc main() {\n\tx := 1 +\n\t\t2\n\tfmt.Println(x)\n}\n\nfunc f(a int) int {\n\treturn a\n}\n"
+	jsonSrc := "{\n\t\"a\": 1,\n\t\"b\": [1, 2, {\"c\": true}],\n\t\"d\": \"x\"\n}\n"
+
+	type edit struct {
+		find    string
+		removed int
+		insert  string
+	}
+
+	tests := []struct {
+		name   string
+		src    string
+		parser Parser
+		edits  []edit
+	}{
+		{"go: new top level declaration", goSrc, goParser{}, []edit{{"func f", 0, "var v = 1\n\n"}}},
+		{"go: edit in a statement", goSrc, goParser{}, []edit{{"Println(x)", 8, "Printf(\"%d\", x)"}}},
+		{"go: operator joins the next line", goSrc, goParser{}, []edit{{"\n\tfmt.", 0, " +"}}},
+		{"go: operator removed splits a statement", goSrc, goParser{}, []edit{{" +\n\t\t2", 2, ""}}},
+		{"go: new statement between others", goSrc, goParser{}, []edit{{"\tfmt.", 0, "y := x\n"}}},
+		{"go: open brace", goSrc, goParser{}, []edit{{"\nfunc f", 0, "\nvar s = struct {"}, {"var s = struct {", 0, "}"}}},
+		{"go: extra close brace", goSrc, goParser{}, []edit{{"\treturn a", 0, "}\n"}}},
+		{"go: block comment opened then closed", goSrc, goParser{}, []edit{{"func main", 0, "/* "}, {"func f", 0, " */\n"}}},
+		{"go: unclosed string", goSrc, goParser{}, []edit{{"return a", 0, "`"}}},
+		{"go: delete a whole function", goSrc, goParser{}, []edit{{"func f(a int) int {\n\treturn a\n}\n", 30, ""}}},
+		{"go: delete across functions", goSrc, goParser{}, []edit{{"Println(x)\n}\n\nfunc", 20, ""}}},
+		{"go: type at the end", goSrc, goParser{}, []edit{{"", -1, "var z = [3]int{"}, {"", -1, "1, 2"}, {"", -1, "}"}}},
+		{"go: empty document", "", goParser{}, []edit{{"", 0, "func a() {}"}, {"", 0, "x"}}},
+
+		{"json: new pair", jsonSrc, jsonParser{}, []edit{{"\n\t\"d\"", 0, "\n\t\"e\": null,"}}},
+		{"json: value typed after a key", "{\"a\": , \"b\": 2}", jsonParser{}, []edit{{" , \"b\"", 1, " [1]"}}},
+		{"json: key made a string", jsonSrc, jsonParser{}, []edit{{"\"c\"", 0, ", \"k\": 3"}}},
+		{"json: remove a colon", jsonSrc, jsonParser{}, []edit{{": \"x\"", 1, ""}}},
+		{"json: close bracket removed", jsonSrc, jsonParser{}, []edit{{"}],", 2, ","}}},
+	}
+
+	for _, tt := range tests {
+
+		buf := buffer.New([]byte(tt.src))
+		lang := &Language{Name: "test", Parser: tt.parser}
+		tree := NewTree(lang, buf)
+		tree.Root()
+
+		for i, ed := range tt.edits {
+
+			off := strings.Index(buf.String(), ed.find)
+			if ed.removed == -1 {
+				off = buf.Len()
+				ed.removed = 0
+			}
+
+			if off == -1 {
+				t.Fatalf("%s: edit %d: '%s' isn't in the text", tt.name, i, ed.find)
+			}
+
+			if ed.removed > 0 {
+				buf.Delete(off, ed.removed)
+			}
+
+			if ed.insert != "" {
+				buf.InsertString(off, ed.insert)
+			}
+
+			got := dumpTree(tree.Root())
+			expected := parseWhole(tt.parser, buf.String())
+			if got != expected {
+				t.Errorf("%s: edit %d: the tree doesn't match parsing the whole text %q\nexpected: %s\ngot:      %s", tt.name, i, buf.String(), expected, got)
+				break
+			}
+		}
+
+		tree.Close()
+	}
+}
+
+func TestTreeEditParsesLittle(t *testing.T) {
+
+	sb := strings.Builder{}
+	sb.WriteString("package main\n\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&sb, "func f%d(a, b int) int {\n\tx := a * b\n\tif x > %d {\n\t\treturn x\n\t}\n\treturn a + b\n}\n\n", i, i)
+	}
+
+	parser := &countingParser{Parser: goParser{}}
+	buf := buffer.New([]byte(sb.String()))
+	tree := NewTree(&Language{Name: "test", Parser: parser}, buf)
+	defer tree.Close()
+	tree.Root()
+
+	funcLen := strings.Index(sb.String(), "func f1(") - strings.Index(sb.String(), "func f0(")
+	tests := []struct {
+		name   string
+		find   string
+		insert string
+	}{
+		{"top level declaration", "func f50(", "var v = 1\n\n"},
+		{"top level comment", "func f20(", "// f20 does things\n"},
+		{"statement in a function", "\treturn a + b\n}\n\nfunc f71(", "\ty := 2\n"},
+		{"word in a nested block", "\t\treturn x\n\t}\n\treturn a + b\n}\n\nfunc f31(", "\t\tx++\n"},
+	}
+
+	for _, tt := range tests {
+
+		parser.parsed = 0
+		off := strings.Index(buf.String(), tt.find)
+		buf.InsertString(off, tt.insert)
+
+		//The edited function (or statement) and the ones on each side of it, with room for the edit and the space between them
+		limit := 4 * funcLen
+		if parser.parsed > limit {
+			t.Errorf("%s: expected at most %d bytes to be parsed but %d were, of %d", tt.name, limit, parser.parsed, buf.Len())
+		}
+
+		if got, expected := dumpTree(tree.Root()), parseWhole(goParser{}, buf.String()); got != expected {
+			t.Errorf("%s: the tree doesn't match parsing the whole text", tt.name)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package syntax
+
+import "bytes"
+
+// treeBuilder builds the nodes of a tree from a stream of tokens, keeping a stack of the delimited nodes that are still open
+type treeBuilder struct {
+	src  []byte
+	base int
+
+	stack    []*treeFrame
+	balanced bool
+
+	//statements groups the children of braces (and the root) into statements, and pairs groups keys with their values
+	statements bool
+	pairs      bool
+}
+
+type treeFrame struct {
+	node   *Node
+	closer byte
+
+	//bounds are the offsets statements end at, and endsStatement is true if a newline now would end one (Go's semicolon rule)
+	bounds        []int
+	endsStatement bool
+
+	//pairKey is the key waiting for its value after a ':'
+	pairKey *Node
+}
+
+func newTreeBuilder(src []byte, base int, parent *Node) *treeBuilder {
+	return &treeBuilder{
+		src:      src,
+		base:     base,
+		stack:    []*treeFrame{{node: parent}},
+		balanced: true,
+	}
+}
+
+func (b *treeBuilder) top() *treeFrame {
+	return b.stack[len(b.stack)-1]
+}
+
+// leaf adds a node without children covering src[start:end]
+func (b *treeBuilder) leaf(kind NodeKind, start, end int) *Node {
+
+	n := &Node{Kind: kind, Start: b.base + start, End: b.base + end}
+	b.add(n)
+	b.value(n)
+	return n
+}
+
+func (b *treeBuilder) add(n *Node) {
+	f := b.top()
+	n.Parent = f.node
+	f.node.Children = append(f.node.Children, n)
+}
+
+func (b *treeBuilder) open(kind NodeKind, off int, closer byte) {
+
+	n := &Node{Kind: kind, Start: b.base + off}
+	b.add(n)
+	b.top().endsStatement = false
+	b.stack = append(b.stack, &treeFrame{node: n, closer: closer})
+}
+
+// close ends the innermost open node if c is its closing bracket. A closing bracket that doesn't match is ignored.
+func (b *treeBuilder) close(c byte, off int) {
+
+	f := b.top()
+	if len(b.stack) == 1 || f.closer != c {
+		b.balanced = false
+		return
+	}
+
+	f.node.End = b.base + off + 1
+	b.pop()
+	b.top().endsStatement = true
+}
+
+func (b *treeBuilder) pop() {
+
+	f := b.top()
+	if b.statements && f.node.Kind == NodeBraces {
+		b.groupStatements(f)
+	}
+
+	b.stack = b.stack[:len(b.stack)-1]
+	b.value(f.node)
+}
+
+// value is called once a node is complete, and makes it the value of a pair if a key is waiting for one
+func (b *treeBuilder) value(n *Node) {
+
+	f := b.top()
+	if !b.pairs || f.pairKey == nil || f.pairKey == n {
+		return
+	}
+
+	children := f.node.Children
+	keyIndex := len(children) - 1
+	for keyIndex > 0 && children[keyIndex] != f.pairKey {
+		keyIndex--
+	}
+
+	pair := &Node{Kind: NodePair, Start: f.pairKey.Start, End: n.End, Parent: f.node}
+	pair.Children = append(pair.Children, children[keyIndex:]...)
+	for _, c := range pair.Children {
+		c.Parent = pair
+	}
+
+	f.node.Children = append(children[:keyIndex], pair)
+	f.pairKey = nil
+}
+
+// endStatement marks a statement as ending at off, if the innermost open node has statements
+func (b *treeBuilder) endStatement(off int) {
+
+	f := b.top()
+	if b.statements && (f.node.Kind == NodeBraces || f.node.Kind == NodeRoot) {
+		f.bounds = append(f.bounds, b.base+off)
+	}
+
+	f.endsStatement = false
+}
+
+// finish closes the nodes that are still open and returns whether src was balanced
+func (b *treeBuilder) finish() bool {
+
+	for len(b.stack) > 1 {
+		f := b.top()
+		f.node.Unclosed = true
+		f.node.End = b.base + len(b.src)
+		b.balanced = false
+		b.pop()
+	}
+
+	f := b.stack[0]
+	if b.statements && (f.node.Kind == NodeBraces || f.node.Kind == NodeRoot) {
+		b.groupStatements(f)
+	}
+
+	return b.balanced
+}
+
+// groupStatements puts the children between each pair of statement bounds under a statement node.
+// Comments before and after a statement are left out of it.
+func (b *treeBuilder) groupStatements(f *treeFrame) {
+
+	children := f.node.Children
+	grouped := make([]*Node, 0, len(children))
+	boundIndex := 0
+	for i := 0; i < len(children); {
+
+		for boundIndex < len(f.bounds) && f.bounds[boundIndex] <= children[i].Start {
+			boundIndex++
+		}
+
+		//Find the children of this statement
+		j := i + 1
+		for j < len(children) && (boundIndex == len(f.bounds) || children[j].Start < f.bounds[boundIndex]) {
+			j++
+		}
+
+		first, last := i, j-1
+		for first <= last && children[first].Kind == NodeComment {
+			first++
+		}
+		for last >= first && children[last].Kind == NodeComment {
+			last--
+		}
+
+		grouped = append(grouped, children[i:first]...)
+		if last-first >= 1 {
+
+			stmt := &Node{Kind: NodeStatement, Start: children[first].Start, End: children[last].End, Parent: f.node}
+			stmt.Children = append(stmt.Children, children[first:last+1]...)
+			for _, c := range stmt.Children {
+				c.Parent = stmt
+			}
+
+			grouped = append(grouped, stmt)
+		} else if last == first {
+			grouped = append(grouped, children[first])
+		}
+
+		if last >= first {
+			grouped = append(grouped, children[last+1:j]...)
+		}
+
+		i = j
+	}
+
+	f.node.Children = grouped
+}
+
+// goParser builds trees for Go source
+type goParser struct{}
+
+func (goParser) Parse(src []byte, base int, parent *Node) bool {
+
+	b := newTreeBuilder(src, base, parent)
+	b.statements = true
+
+	for i := 0; i < len(src); {
+
+		c := src[i]
+		switch {
+
+		case c == '\n':
+			if b.top().endsStatement {
+				b.endStatement(i)
+			}
+			i++
+
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			end := b.lineEnd(i)
+			b.leaf(NodeComment, i, end)
+			i = end
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end == -1 {
+				end = len(src)
+				b.balanced = false
+			} else {
+				end += i + 4
+			}
+
+			//A block comment with a newline counts as a newline
+			if b.top().endsStatement && bytes.IndexByte(src[i:end], '\n') != -1 {
+				b.endStatement(i)
+			}
+
+			b.leaf(NodeComment, i, end)
+			i = end
+
+		case c == '"' || c == '\'':
+			end := b.quoted(i, c)
+			b.leaf(NodeString, i, end)
+			b.top().endsStatement = true
+			i = end
+
+		case c == '`':
+
+			end := bytes.IndexByte(src[i+1:], '`')
+			if end == -1 {
+				end = len(src)
+				b.balanced = false
+			} else {
+				end += i + 2
+			}
+
+			b.leaf(NodeString, i, end)
+			b.top().endsStatement = true
+			i = end
+
+		case c == '{':
+			b.open(NodeBraces, i, '}')
+			i++
+		case c == '(':
+			b.open(NodeParens, i, ')')
+			i++
+		case c == '[':
+			b.open(NodeBrackets, i, ']')
+			i++
+		case c == '}' || c == ')' || c == ']':
+			b.close(c, i)
+			i++
+
+		case c == ';':
+			b.endStatement(i)
+			i++
+
+		case isIdentByte(c) || c >= 0x80:
+
+			end := i + 1
+			for end < len(src) && (isIdentByte(src[end]) || src[end] >= 0x80 || (isDigit(c) && isNumberByte(src, end))) {
+				end++
+			}
+
+			b.leaf(NodeWord, i, end)
+			b.top().endsStatement = true
+			i = end
+
+		case (c == '+' || c == '-') && i+1 < len(src) && src[i+1] == c:
+			b.top().endsStatement = true
+			i += 2
+
+		default:
+			b.top().endsStatement = false
+			i++
+		}
+	}
+
+	return b.finish()
+}
+
+// jsonParser builds trees for JSON (with comments)
+type jsonParser struct{}
+
+func (jsonParser) Parse(src []byte, base int, parent *Node) bool {
+
+	b := newTreeBuilder(src, base, parent)
+	b.pairs = true
+
+	for i := 0; i < len(src); {
+
+		c := src[i]
+		switch {
+
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			end := b.lineEnd(i)
+			b.add(&Node{Kind: NodeComment, Start: base + i, End: base + end})
+			i = end
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end == -1 {
+				end = len(src)
+				b.balanced = false
+			} else {
+				end += i + 4
+			}
+
+			b.add(&Node{Kind: NodeComment, Start: base + i, End: base + end})
+			i = end
+
+		case c == '"':
+			end := b.quoted(i, c)
+			b.leaf(NodeString, i, end)
+			i = end
+
+		case c == '{':
+			b.open(NodeBraces, i, '}')
+			i++
+		case c == '[':
+			b.open(NodeBrackets, i, ']')
+			i++
+		case c == '}' || c == ']':
+			b.close(c, i)
+			i++
+
+		case c == ':':
+
+			f := b.top()
+			if n := len(f.node.Children); f.node.Kind == NodeBraces && n > 0 && f.node.Children[n-1].Kind == NodeString {
+				f.pairKey = f.node.Children[n-1]
+			}
+			i++
+
+		case c == ',':
+			b.top().pairKey = nil
+			i++
+
+		default:
+
+			end := i + 1
+			for end < len(src) && bytes.IndexByte([]byte(" \t\r\n,:[]{}\"/"), src[end]) == -1 {
+				end++
+			}
+
+			b.leaf(NodeWord, i, end)
+			i = end
+		}
+	}
+
+	return b.finish()
+}
+
+// quoted returns the end of the string starting at src[start]. Strings end at the closing quote or the end of the line.
+// A string that runs to the end of src without a newline is unbalanced, since it could be closed after src.
+func (b *treeBuilder) quoted(start int, quote byte) int {
+
+	for i := start + 1; i < len(b.src); i++ {
+		switch b.src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			return i
+		}
+	}
+
+	b.balanced = false
+	return len(b.src)
+}
+
+// lineEnd returns the end of the line comment starting at src[start]. Like strings, a comment that runs to the end
+// of src is unbalanced.
+func (b *treeBuilder) lineEnd(start int) int {
+
+	if end := bytes.IndexByte(b.src[start:], '\n'); end != -1 {
+		return start + end
+	}
+
+	b.balanced = false
+	return len(b.src)
+}
+
+// isNumberByte returns true if src[i] continues a number (e.g. the '.' and '-' of '1.5e-3')
+func isNumberByte(src []byte, i int) bool {
+
+	c := src[i]
+	if c == '.' {
+		return true
+	}
+
+	return (c == '+' || c == '-') && (src[i-1] == 'e' || src[i-1] == 'E' || src[i-1] == 'p' || src[i-1] == 'P')
+}
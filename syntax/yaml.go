@@ -0,0 +1,209 @@
+package syntax
+
+import "bytes"
+
+// yamlStateBlock+n means we are in a block scalar ('|' or '>') whose key was indented by n spaces
+const yamlStateBlock State = 1
+
+var yamlConstants = words(TokenConstant, "true false yes no on off null True False Yes No On Off Null TRUE FALSE NULL ~", nil)
+
+// yamlLexer colours YAML, which is line based enough to not need a general lexer
+type yamlLexer struct{}
+
+func (yamlLexer) Lex(line []byte, state State, dst []Token) ([]Token, State) {
+
+	indent := 0
+	for indent < len(line) && line[indent] == ' ' {
+		indent++
+	}
+
+	//Block scalar lines are the ones indented more than their key, and blank lines in between
+	if state >= yamlStateBlock {
+
+		keyIndent := int(state - yamlStateBlock)
+		if indent == len(line) {
+			return dst, state
+		}
+
+		if indent > keyIndent {
+			return append(dst, Token{Start: indent, End: len(line), Kind: TokenString}), state
+		}
+	}
+
+	i := indent
+	if bytes.HasPrefix(line[i:], []byte("---")) || bytes.HasPrefix(line[i:], []byte("...")) {
+		return append(dst, Token{Start: i, End: len(line), Kind: TokenOperator}), 0
+	}
+
+	//List items
+	for i+1 < len(line) && line[i] == '-' && line[i+1] == ' ' {
+		dst = append(dst, Token{Start: i, End: i + 1, Kind: TokenOperator})
+		i += 2
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+	}
+
+	if i < len(line) && line[i] == '#' {
+		return append(dst, Token{Start: i, End: len(line), Kind: TokenComment}), 0
+	}
+
+	if colon := yamlKeyEnd(line, i); colon != -1 {
+		dst = append(dst, Token{Start: i, End: colon, Kind: TokenKey}, Token{Start: colon, End: colon + 1, Kind: TokenOperator})
+		i = colon + 1
+	}
+
+	return yamlValue(line, i, indent, dst)
+}
+
+// yamlKeyEnd returns the index of the ':' that ends a key starting at i, or -1 if there is no key
+func yamlKeyEnd(line []byte, i int) int {
+
+	if i < len(line) && (line[i] == '"' || line[i] == '\'') {
+
+		end := bytes.IndexByte(line[i+1:], line[i])
+		if end == -1 {
+			return -1
+		}
+
+		i += end + 2
+		if i < len(line) && line[i] == ':' {
+			return i
+		}
+
+		return -1
+	}
+
+	for j := i; j < len(line); j++ {
+
+		switch line[j] {
+		case ':':
+			if j+1 == len(line) || line[j+1] == ' ' || line[j+1] == '\t' {
+				return j
+			}
+		case '#', '{', '[', '"', '\'':
+			return -1
+		}
+	}
+
+	return -1
+}
+
+func yamlValue(line []byte, i, indent int, dst []Token) ([]Token, State) {
+
+	for i < len(line) {
+
+		c := line[i]
+		switch {
+
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return append(dst, Token{Start: i, End: len(line), Kind: TokenComment}), 0
+
+		case c == '"' || c == '\'':
+
+			start := i
+			i++
+			for i < len(line) && line[i] != c {
+				if c == '"' && line[i] == '\\' {
+					i++
+				}
+				i++
+			}
+
+			i = minInt(i+1, len(line))
+			dst = append(dst, Token{Start: start, End: i, Kind: TokenString})
+
+		case c == '|' || c == '>':
+
+			//A block scalar indicator is the last thing in the value, optionally followed by chomping/indent indicators
+			rest := bytes.TrimRight(line[i+1:], " \t\r+-0123456789")
+			if len(rest) == 0 || rest[0] == '#' {
+				dst = append(dst, Token{Start: i, End: len(line), Kind: TokenOperator})
+				return dst, yamlStateBlock + State(indent)
+			}
+
+			i++
+
+		case bytes.IndexByte([]byte("{}[],"), c) != -1:
+			dst = append(dst, Token{Start: i, End: i + 1, Kind: TokenOperator})
+			i++
+
+		case c == '&' || c == '*' || c == '!':
+
+			//Anchors, aliases and tags
+			start := i
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' && line[i] != ',' {
+				i++
+			}
+
+			kind := TokenVariable
+			if c == '!' {
+				kind = TokenType
+			}
+
+			dst = append(dst, Token{Start: start, End: i, Kind: kind})
+
+		default:
+
+			start := i
+			i = scalarEnd(line, i)
+
+			word := bytes.TrimSpace(line[start:i])
+			kind := TokenString
+			if k, ok := yamlConstants[string(word)]; ok {
+				kind = k
+			} else if isNumber(word) {
+				kind = TokenNumber
+			}
+
+			dst = append(dst, Token{Start: start, End: start + len(word), Kind: kind})
+		}
+	}
+
+	return dst, 0
+}
+
+// scalarEnd returns where an unquoted value starting at i ends, which is at a comment, a flow collection char or the line end
+func scalarEnd(line []byte, i int) int {
+
+	for ; i < len(line); i++ {
+
+		c := line[i]
+		if c == ',' || c == ']' || c == '}' || (c == '#' && (line[i-1] == ' ' || line[i-1] == '\t')) {
+			return i
+		}
+	}
+
+	return i
+}
+
+func isNumber(b []byte) bool {
+
+	if len(b) > 0 && (b[0] == '-' || b[0] == '+') {
+		b = b[1:]
+	}
+
+	if len(b) == 0 || (!isDigit(b[0]) && b[0] != '.') {
+		return false
+	}
+
+	for _, c := range b {
+		if !isIdentByte(c) && c != '.' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func minInt(x, y int) int {
+
+	if x < y {
+		return x
+	}
+
+	return y
+}
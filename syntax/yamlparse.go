@@ -0,0 +1,466 @@
+package syntax
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// yamlParser parses the subset of YAML used by .sublime-syntax files: block mappings and sequences,
+// plain and quoted scalars, block scalars ('|' and '>') and simple flow collections.
+// Mappings become map[string]interface{}, sequences []interface{} and scalars strings.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+type yamlLine struct {
+	indent int
+	text   string
+	//num is the 1 based line number for errors
+	num int
+}
+
+func parseYAML(data string) (interface{}, error) {
+
+	p := &yamlParser{}
+	for i, l := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+
+		trimmed := strings.TrimLeft(l, " ")
+		p.lines = append(p.lines, yamlLine{indent: len(l) - len(trimmed), text: strings.TrimRight(trimmed, " \t"), num: i + 1})
+	}
+
+	//Skip the header
+	for p.pos < len(p.lines) && (p.isBlank(p.pos) || strings.HasPrefix(p.lines[p.pos].text, "%") || p.lines[p.pos].text == "---") {
+		p.pos++
+	}
+
+	return p.parseNode(0)
+}
+
+func (p *yamlParser) isBlank(i int) bool {
+	t := p.lines[i].text
+	return t == "" || t[0] == '#'
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.isBlank(p.pos) {
+		p.pos++
+	}
+}
+
+// parseNode parses the block node at the current line, which must be indented at least minIndent
+func (p *yamlParser) parseNode(minIndent int) (interface{}, error) {
+
+	p.skipBlank()
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent < minIndent {
+		return nil, nil
+	}
+
+	l := &p.lines[p.pos]
+	if isYAMLSeqItem(l.text) {
+		return p.parseSeq(l.indent)
+	}
+
+	if _, _, ok := splitYAMLKey(l.text); ok {
+		return p.parseMap(l.indent)
+	}
+
+	p.pos++
+	return parseYAMLInline(l.text, l.num)
+}
+
+func (p *yamlParser) parseMap(indent int) (interface{}, error) {
+
+	m := map[string]interface{}{}
+	for {
+
+		p.skipBlank()
+		if p.pos >= len(p.lines) || p.lines[p.pos].indent != indent {
+			return m, nil
+		}
+
+		l := &p.lines[p.pos]
+		key, rest, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, yamlErr(l.num, "expected a 'key: value' pair")
+		}
+		p.pos++
+
+		var val interface{}
+		var err error
+		switch {
+
+		case rest == "":
+
+			//Sequences are allowed to be at the same indent as their key
+			p.skipBlank()
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSeqItem(p.lines[p.pos].text) {
+				val, err = p.parseSeq(indent)
+			} else {
+				val, err = p.parseNode(indent + 1)
+			}
+
+		case isBlockScalar(rest):
+			val = p.parseBlockScalar(rest, indent)
+
+		default:
+			val, err = parseYAMLInline(rest, l.num)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		m[key] = val
+	}
+}
+
+func (p *yamlParser) parseSeq(indent int) (interface{}, error) {
+
+	seq := []interface{}{}
+	for {
+
+		p.skipBlank()
+		if p.pos >= len(p.lines) || p.lines[p.pos].indent != indent || !isYAMLSeqItem(p.lines[p.pos].text) {
+			return seq, nil
+		}
+
+		l := &p.lines[p.pos]
+		rest := strings.TrimLeft(l.text[1:], " ")
+
+		var val interface{}
+		var err error
+		switch {
+
+		case rest == "":
+			p.pos++
+			val, err = p.parseNode(indent + 1)
+
+		case isBlockScalar(rest):
+			p.pos++
+			val = p.parseBlockScalar(rest, indent)
+
+		case isYAMLSeqItem(rest):
+			//A nested sequence starting on the same line ('- - a') is parsed by treating the dash as indentation
+			l.indent += len(l.text) - len(rest)
+			l.text = rest
+			val, err = p.parseSeq(l.indent)
+
+		default:
+
+			if _, _, ok := splitYAMLKey(rest); ok {
+
+				//'- key: value' starts a mapping whose keys are aligned with the first key
+				l.indent += len(l.text) - len(rest)
+				l.text = rest
+				val, err = p.parseMap(l.indent)
+				break
+			}
+
+			p.pos++
+			val, err = parseYAMLInline(rest, l.num)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		seq = append(seq, val)
+	}
+}
+
+// parseBlockScalar reads the lines of a '|' or '>' scalar whose parent is at parentIndent
+func (p *yamlParser) parseBlockScalar(indicator string, parentIndent int) string {
+
+	folded := indicator[0] == '>'
+	chomp := byte(0)
+	if strings.ContainsAny(indicator, "-") {
+		chomp = '-'
+	} else if strings.ContainsAny(indicator, "+") {
+		chomp = '+'
+	}
+
+	var lines []string
+	contentIndent := -1
+	for p.pos < len(p.lines) {
+
+		l := &p.lines[p.pos]
+		if l.text == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+
+		if l.indent <= parentIndent {
+			break
+		}
+
+		if contentIndent == -1 {
+			contentIndent = l.indent
+		}
+
+		lines = append(lines, strings.Repeat(" ", maxInt(l.indent-contentIndent, 0))+l.text)
+		p.pos++
+	}
+
+	//Trailing blank lines belong to the scalar only for chomping, so the parser can see them again
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var text string
+	if folded {
+		sb := strings.Builder{}
+		for i, l := range lines {
+			switch {
+			case i == 0:
+			case l == "" || lines[i-1] == "":
+				sb.WriteByte('\n')
+			default:
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(l)
+		}
+		text = sb.String()
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+
+	switch chomp {
+	case '-':
+		return text
+	case '+':
+		return text + "\n" + strings.Repeat("\n", trailing)
+	default:
+		return text + "\n"
+	}
+}
+
+// parseYAMLInline parses a scalar or flow collection that is written on a single line
+func parseYAMLInline(text string, lineNum int) (interface{}, error) {
+
+	v, rest, err := parseYAMLFlow(text, lineNum, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rest = strings.TrimSpace(rest)
+	if rest != "" && rest[0] != '#' {
+		return nil, yamlErr(lineNum, "unexpected '"+rest+"'")
+	}
+
+	return v, nil
+}
+
+// parseYAMLFlow parses a value at the start of text and returns what is left after it.
+// inFlow is true inside '[]' and '{}', where ',' and the closing brackets end plain scalars.
+func parseYAMLFlow(text string, lineNum int, inFlow bool) (v interface{}, rest string, err error) {
+
+	text = strings.TrimLeft(text, " \t")
+	if text == "" {
+		return "", "", nil
+	}
+
+	switch text[0] {
+
+	case '"':
+		return parseDoubleQuoted(text, lineNum)
+
+	case '\'':
+		sb := strings.Builder{}
+		for i := 1; i < len(text); i++ {
+			if text[i] == '\'' {
+				if i+1 < len(text) && text[i+1] == '\'' {
+					sb.WriteByte('\'')
+					i++
+					continue
+				}
+				return sb.String(), text[i+1:], nil
+			}
+			sb.WriteByte(text[i])
+		}
+		return nil, "", yamlErr(lineNum, "unterminated single quoted string")
+
+	case '[':
+
+		seq := []interface{}{}
+		rest = strings.TrimLeft(text[1:], " ")
+		for {
+
+			if strings.HasPrefix(rest, "]") {
+				return seq, rest[1:], nil
+			}
+
+			var item interface{}
+			item, rest, err = parseYAMLFlow(rest, lineNum, true)
+			if err != nil {
+				return nil, "", err
+			}
+			seq = append(seq, item)
+
+			rest = strings.TrimLeft(rest, " ")
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimLeft(rest[1:], " ")
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, "", yamlErr(lineNum, "expected ',' or ']'")
+			}
+		}
+
+	case '{':
+
+		m := map[string]interface{}{}
+		rest = strings.TrimLeft(text[1:], " ")
+		for {
+
+			if strings.HasPrefix(rest, "}") {
+				return m, rest[1:], nil
+			}
+
+			var key, val interface{}
+			key, rest, err = parseYAMLFlow(rest, lineNum, true)
+			if err != nil {
+				return nil, "", err
+			}
+
+			rest = strings.TrimLeft(rest, " ")
+			if !strings.HasPrefix(rest, ":") {
+				return nil, "", yamlErr(lineNum, "expected ':' in flow mapping")
+			}
+
+			val, rest, err = parseYAMLFlow(rest[1:], lineNum, true)
+			if err != nil {
+				return nil, "", err
+			}
+
+			keyStr, _ := key.(string)
+			m[keyStr] = val
+
+			rest = strings.TrimLeft(rest, " ")
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimLeft(rest[1:], " ")
+			} else if !strings.HasPrefix(rest, "}") {
+				return nil, "", yamlErr(lineNum, "expected ',' or '}'")
+			}
+		}
+	}
+
+	//Plain scalar, which ends at a comment (or in flow context at ',', ':', ']' and '}')
+	end := len(text)
+	for i := 0; i < len(text); i++ {
+
+		c := text[i]
+		if c == '#' && i > 0 && (text[i-1] == ' ' || text[i-1] == '\t') {
+			end = i
+			break
+		}
+
+		if inFlow && (c == ',' || c == ']' || c == '}' || (c == ':' && (i+1 == len(text) || text[i+1] == ' '))) {
+			end = i
+			break
+		}
+	}
+
+	return strings.TrimRight(text[:end], " \t"), text[end:], nil
+}
+
+func parseDoubleQuoted(text string, lineNum int) (v interface{}, rest string, err error) {
+
+	sb := strings.Builder{}
+	for i := 1; i < len(text); i++ {
+
+		c := text[i]
+		if c == '"' {
+			return sb.String(), text[i+1:], nil
+		}
+
+		if c != '\\' || i+1 >= len(text) {
+			sb.WriteByte(c)
+			continue
+		}
+
+		i++
+		switch text[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case '0':
+			sb.WriteByte(0)
+		case 'x', 'u', 'U':
+
+			n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[text[i]]
+			if i+n >= len(text) {
+				return nil, "", yamlErr(lineNum, "bad escape")
+			}
+
+			code, err := strconv.ParseUint(text[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return nil, "", yamlErr(lineNum, "bad escape")
+			}
+
+			sb.WriteRune(rune(code))
+			i += n
+
+		default:
+			sb.WriteByte(text[i])
+		}
+	}
+
+	return nil, "", yamlErr(lineNum, "unterminated double quoted string")
+}
+
+// splitYAMLKey splits a 'key: value' line. ok is false if the line isn't a mapping entry.
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+
+	if text == "" || text[0] == '[' || text[0] == '{' || text[0] == '#' {
+		return "", "", false
+	}
+
+	//Quoted keys
+	if text[0] == '"' || text[0] == '\'' {
+
+		v, after, err := parseYAMLFlow(text, 0, false)
+		if err != nil || !strings.HasPrefix(after, ":") || (len(after) > 1 && after[1] != ' ') {
+			return "", "", false
+		}
+
+		key, _ = v.(string)
+		return key, strings.TrimSpace(after[1:]), true
+	}
+
+	for i := 0; i < len(text); i++ {
+
+		if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+			return "", "", false
+		}
+
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+
+	return "", "", false
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func isBlockScalar(text string) bool {
+
+	if text == "" || (text[0] != '|' && text[0] != '>') {
+		return false
+	}
+
+	rest := strings.TrimLeft(text[1:], "+-0123456789")
+	return rest == "" || strings.HasPrefix(strings.TrimSpace(rest), "#")
+}
+
+func yamlErr(lineNum int, msg string) error {
+	return errors.New("line " + strconv.Itoa(lineNum) + ": " + msg)
+}
@@ -0,0 +1,232 @@
+package theme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bloeys/gopad/settings"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// Theme is a colour scheme. Colors are keyed by the names in colorVars, and Syntax by token kind name (see syntax.TokenKind).
+// Colours are written as '#rrggbb' or '#rrggbbaa'. Anything a theme doesn't set keeps its default colour.
+type Theme struct {
+	Name   string            `json:"name"`
+	Colors map[string]string `json:"colors"`
+	Syntax map[string]string `json:"syntax"`
+
+	//Path is the file the theme was loaded from
+	Path string `json:"-"`
+}
+
+// colorVars are the settings a theme can set
+var colorVars = map[string]*imgui.Vec4{
+	"editor.background":   &settings.EditorBgColor,
+	"editor.text":         &settings.TextColor,
+	"editor.selection":    &settings.TextSelectionColor,
+	"editor.cursor":       &settings.CursorColor,
+	"editor.findMatch":    &settings.FindMatchColor,
+	"editor.bracketMatch": &settings.BracketMatchColor,
+	"gutter.background":   &settings.GutterBgColor,
+	"gutter.lineNumber":   &settings.LineNumberColor,
+	"sidebar.background":  &settings.SidebarBgColor,
+	"sidebar.text":        &settings.SidebarTextColor,
+	"menubar.background":  &settings.MenubarBgColor,
+	"tab.background":      &settings.TabColor,
+	"tab.hovered":         &settings.TabHoveredColor,
+	"tab.active":          &settings.TabActiveColor,
+	"popup.background":    &settings.PopupBgColor,
+	"widget.background":   &settings.WidgetBgColor,
+	"widget.hovered":      &settings.WidgetHoveredColor,
+	"ui.text":             &settings.UITextColor,
+	"error.text":          &settings.ErrorTextColor,
+	"diff.added":          &settings.DiffAddedColor,
+	"diff.removed":        &settings.DiffRemovedColor,
+}
+
+// defaultColors and defaultSyntax are the colours settings start with, which are restored before applying a theme
+var (
+	defaultColors = map[string]imgui.Vec4{}
+	defaultSyntax = map[string]imgui.Vec4{}
+)
+
+func init() {
+
+	for name, v := range colorVars {
+		defaultColors[name] = *v
+	}
+
+	for kind, c := range settings.SyntaxColors {
+		defaultSyntax[kind] = c
+	}
+}
+
+// Apply sets the colours in settings to the ones of the theme
+func (t *Theme) Apply() {
+
+	for name, v := range colorVars {
+		*v = defaultColors[name]
+	}
+
+	syntaxColors := make(map[string]imgui.Vec4, len(defaultSyntax))
+	for kind, c := range defaultSyntax {
+		syntaxColors[kind] = c
+	}
+
+	//Themes are validated when loaded, so bad colours can't happen here
+	for name, hex := range t.Colors {
+		if v, ok := colorVars[name]; ok {
+			*v, _ = ParseColor(hex)
+		}
+	}
+
+	for kind, hex := range t.Syntax {
+		syntaxColors[kind], _ = ParseColor(hex)
+	}
+
+	settings.SyntaxColors = syntaxColors
+	settings.Theme = t.Name
+}
+
+// validate drops the colours that can't be used and returns an error listing them
+func (t *Theme) validate() error {
+
+	var problems []string
+	for name, hex := range t.Colors {
+
+		if _, ok := colorVars[name]; !ok {
+			problems = append(problems, "unknown colour '"+name+"'")
+			delete(t.Colors, name)
+			continue
+		}
+
+		if _, err := ParseColor(hex); err != nil {
+			problems = append(problems, "colour '"+name+"': "+err.Error())
+			delete(t.Colors, name)
+		}
+	}
+
+	for kind, hex := range t.Syntax {
+		if _, err := ParseColor(hex); err != nil {
+			problems = append(problems, "syntax colour '"+kind+"': "+err.Error())
+			delete(t.Syntax, kind)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return errors.New(strings.Join(problems, "\n"))
+}
+
+// Marshal returns the theme file contents of the theme
+func (t *Theme) Marshal() ([]byte, error) {
+	return json.MarshalIndent(t, "", "\t")
+}
+
+// Load reads a theme file. If some of its colours are bad the theme is still returned without them, along with an error.
+func Load(fPath string) (*Theme, error) {
+
+	data, err := os.ReadFile(fPath)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Theme{}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("failed to load theme '%s': %w", fPath, err)
+	}
+
+	t.Path = fPath
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(fPath), filepath.Ext(fPath))
+	}
+
+	if err := t.validate(); err != nil {
+		return t, fmt.Errorf("theme '%s' has bad colours that were skipped:\n%w", fPath, err)
+	}
+
+	return t, nil
+}
+
+// LoadDir loads every .json theme in dir. A missing dir isn't an error.
+func LoadDir(dir string) (themes []*Theme, errs []error) {
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, []error{err}
+	}
+
+	for _, e := range entries {
+
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+
+		t, err := Load(filepath.Join(dir, e.Name()))
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if t != nil {
+			themes = append(themes, t)
+		}
+	}
+
+	return themes, errs
+}
+
+// ParseColor parses a '#rgb', '#rgba', '#rrggbb' or '#rrggbbaa' colour
+func ParseColor(hex string) (imgui.Vec4, error) {
+
+	s := strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(s) == 3 || len(s) == 4 {
+
+		long := make([]byte, 0, 8)
+		for i := 0; i < len(s); i++ {
+			long = append(long, s[i], s[i])
+		}
+		s = string(long)
+	}
+
+	if len(s) == 6 {
+		s += "ff"
+	}
+
+	if len(s) != 8 {
+		return imgui.Vec4{}, errors.New("'" + hex + "' is not a colour")
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return imgui.Vec4{}, errors.New("'" + hex + "' is not a colour")
+	}
+
+	return imgui.Vec4{
+		X: float32(v>>24&0xff) / 255,
+		Y: float32(v>>16&0xff) / 255,
+		Z: float32(v>>8&0xff) / 255,
+		W: float32(v&0xff) / 255,
+	}, nil
+}
+
+// FormatColor returns c as '#rrggbbaa'
+func FormatColor(c imgui.Vec4) string {
+
+	toByte := func(f float32) uint32 {
+		return uint32(f*255+0.5) & 0xff
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x%02x", toByte(c.X), toByte(c.Y), toByte(c.Z), toByte(c.W))
+}
@@ -0,0 +1,181 @@
+package theme
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/bloeys/gopad/syntax"
+)
+
+// vscodeColors maps the colours of a theme to the VS Code colours they are taken from, in order of preference
+var vscodeColors = map[string][]string{
+	"editor.background":   {"editor.background"},
+	"editor.text":         {"editor.foreground", "foreground"},
+	"editor.selection":    {"editor.selectionBackground"},
+	"editor.cursor":       {"editorCursor.foreground"},
+	"editor.findMatch":    {"editor.findMatchHighlightBackground", "editor.findMatchBackground"},
+	"editor.bracketMatch": {"editorBracketMatch.background"},
+	"gutter.background":   {"editorGutter.background", "editor.background"},
+	"gutter.lineNumber":   {"editorLineNumber.foreground"},
+	"sidebar.background":  {"sideBar.background"},
+	"sidebar.text":        {"sideBar.foreground", "foreground"},
+	"menubar.background":  {"titleBar.activeBackground", "menu.background"},
+	"tab.background":      {"tab.inactiveBackground"},
+	"tab.hovered":         {"tab.hoverBackground", "list.hoverBackground"},
+	"tab.active":          {"tab.activeBackground"},
+	"popup.background":    {"editorWidget.background", "menu.background"},
+	"widget.background":   {"input.background", "dropdown.background"},
+	"widget.hovered":      {"list.hoverBackground", "button.hoverBackground"},
+	"ui.text":             {"foreground", "editor.foreground"},
+	"error.text":          {"errorForeground", "editorError.foreground"},
+	"diff.added":          {"gitDecoration.addedResourceForeground", "editorGutter.addedBackground"},
+	"diff.removed":        {"gitDecoration.deletedResourceForeground", "editorGutter.deletedBackground"},
+}
+
+type vscodeTheme struct {
+	Name        string            `json:"name"`
+	Colors      map[string]string `json:"colors"`
+	TokenColors []struct {
+		//Scope is either a string of comma separated scopes or a list of scopes
+		Scope    interface{} `json:"scope"`
+		Settings struct {
+			Foreground string `json:"foreground"`
+		} `json:"settings"`
+	} `json:"tokenColors"`
+}
+
+// ImportVSCode converts a VS Code colour theme (the .json files in the 'themes' folder of theme extensions).
+// Themes that include other themes are imported without the colours of the included theme.
+func ImportVSCode(data []byte) (*Theme, error) {
+
+	vt := vscodeTheme{}
+	if err := json.Unmarshal(stripJSONC(data), &vt); err != nil {
+		return nil, err
+	}
+
+	t := &Theme{
+		Name:   vt.Name,
+		Colors: map[string]string{},
+		Syntax: map[string]string{},
+	}
+
+	for name, sources := range vscodeColors {
+		for _, src := range sources {
+			if c, err := ParseColor(vt.Colors[src]); err == nil {
+				t.Colors[name] = FormatColor(c)
+				break
+			}
+		}
+	}
+
+	//A token kind takes the colour of its most general scope (e.g. 'comment' over 'comment.line.double-slash'),
+	//with later rules winning ties like they do in VS Code
+	depth := map[string]int{}
+	for _, tc := range vt.TokenColors {
+
+		c, err := ParseColor(tc.Settings.Foreground)
+		if err != nil {
+			continue
+		}
+
+		var scopes []string
+		switch s := tc.Scope.(type) {
+		case string:
+			scopes = strings.Split(s, ",")
+		case []interface{}:
+			for _, item := range s {
+				if str, ok := item.(string); ok {
+					scopes = append(scopes, str)
+				}
+			}
+		}
+
+		for _, scope := range scopes {
+
+			scope = strings.TrimSpace(scope)
+			kind, ok := syntax.ScopeKind(scope)
+			if !ok {
+				continue
+			}
+
+			//Scopes like 'meta.function string' only apply in some places, so the last name is what matters
+			names := strings.Fields(scope)
+			d := strings.Count(names[len(names)-1], ".") + 10*(len(names)-1)
+			if prev, ok := depth[kind.String()]; ok && prev < d {
+				continue
+			}
+
+			depth[kind.String()] = d
+			t.Syntax[kind.String()] = FormatColor(c)
+		}
+	}
+
+	if err := t.validate(); err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
+// stripJSONC removes the comments and trailing commas VS Code allows in its JSON files
+func stripJSONC(data []byte) []byte {
+
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+
+		c := data[i]
+		if inString {
+
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+
+			continue
+		}
+
+		switch {
+
+		case c == '"':
+			inString = true
+			out = append(out, c)
+
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := strings.Index(string(data[i+2:]), "*/")
+			if end == -1 {
+				i = len(data)
+			} else {
+				i += end + 3
+			}
+
+		case c == ']' || c == '}':
+
+			//Drop a comma before the closing bracket
+			j := len(out) - 1
+			for j >= 0 && (out[j] == ' ' || out[j] == '\t' || out[j] == '\n' || out[j] == '\r') {
+				j--
+			}
+
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+
+			out = append(out, c)
+
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
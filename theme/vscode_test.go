@@ -0,0 +1,71 @@
+package theme
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestImportVSCode(t *testing.T) {
+
+	data, err := os.ReadFile("testdata/monokai-color-theme.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	th, err := ImportVSCode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if th.Name != "Monokai" {
+		t.Errorf("expected name 'Monokai' but got '%s'", th.Name)
+	}
+
+	//Colours missing from the theme fall back to the next VS Code colour in vscodeColors, or are left unset (e.g. sidebar.text)
+	expectColors := map[string]string{
+		"editor.background":   "#272822ff",
+		"editor.text":         "#f8f8f2ff",
+		"editor.selection":    "#878b9180",
+		"editor.cursor":       "#f8f8f0ff",
+		"editor.findMatch":    "#ffe79250",
+		"editor.bracketMatch": "#75715eff",
+		"gutter.background":   "#272822ff",
+		"gutter.lineNumber":   "#90908aff",
+		"sidebar.background":  "#1e1f1cff",
+		"menubar.background":  "#1e1f1cff",
+		"tab.background":      "#34352fff",
+		"tab.hovered":         "#3e3d32ff",
+		"tab.active":          "#272822ff",
+		"popup.background":    "#1e1f1cff",
+		"widget.background":   "#414339ff",
+		"widget.hovered":      "#3e3d32ff",
+		"ui.text":             "#f8f8f2ff",
+		"error.text":          "#f92672ff",
+		"diff.added":          "#a6e22eff",
+		"diff.removed":        "#f92672ff",
+	}
+
+	//The most general scope of a kind wins (support.function over entity.name.function, string over the JSON string),
+	//and later rules win ties (storage over keyword)
+	expectSyntax := map[string]string{
+		"comment":  "#88846fff",
+		"string":   "#e6db74ff",
+		"number":   "#ae81ffff",
+		"constant": "#ae81ffff",
+		"variable": "#f8f8f2ff",
+		"keyword":  "#f92672ff",
+		"type":     "#66d9efff",
+		"function": "#66d9efff",
+		"key":      "#a6e22eff",
+		"heading":  "#66d9efff",
+	}
+
+	if !reflect.DeepEqual(th.Colors, expectColors) {
+		t.Errorf("wrong colours:\nexpected %v\n but got %v", expectColors, th.Colors)
+	}
+
+	if !reflect.DeepEqual(th.Syntax, expectSyntax) {
+		t.Errorf("wrong syntax colours:\nexpected %v\n but got %v", expectSyntax, th.Syntax)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bloeys/gopad/settings"
+	"github.com/bloeys/gopad/theme"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// themeImport is the state of the popup that imports a VS Code theme
+type themeImport struct {
+	open bool
+	path string
+}
+
+// loadThemes loads the shipped themes and the ones the user imported, then applies the theme in settings
+func (g *Gopad) loadThemes() {
+
+	themes, errs := theme.LoadDir("./res/themes")
+	if dir, err := appDataDir("themes"); err == nil {
+		userThemes, userErrs := theme.LoadDir(dir)
+		themes = append(themes, userThemes...)
+		errs = append(errs, userErrs...)
+	} else {
+		errs = append(errs, err)
+	}
+
+	g.themes = themes
+	if len(errs) > 0 {
+
+		errMsg := ""
+		for _, err := range errs {
+			errMsg += err.Error() + "\n"
+		}
+
+		g.triggerError(errMsg)
+	}
+
+	if t := g.findTheme(settings.Theme); t != nil {
+		g.applyTheme(t)
+		return
+	}
+
+	g.applyTheme(&theme.Theme{Name: settings.Theme})
+}
+
+// findTheme returns the theme with the given name. Themes loaded later (i.e. imported ones) override shipped ones.
+func (g *Gopad) findTheme(name string) *theme.Theme {
+
+	for i := len(g.themes) - 1; i >= 0; i-- {
+		if strings.EqualFold(g.themes[i].Name, name) {
+			return g.themes[i]
+		}
+	}
+
+	return nil
+}
+
+// applyTheme sets the colours in settings, which the editors read every frame, and the imgui colours of the rest of the UI
+func (g *Gopad) applyTheme(t *theme.Theme) {
+
+	t.Apply()
+
+	style := imgui.CurrentStyle()
+	style.SetColor(imgui.StyleColorText, settings.UITextColor)
+	style.SetColor(imgui.StyleColorWindowBg, settings.SidebarBgColor)
+	style.SetColor(imgui.StyleColorPopupBg, settings.PopupBgColor)
+	style.SetColor(imgui.StyleColorMenuBarBg, settings.MenubarBgColor)
+
+	style.SetColor(imgui.StyleColorTab, settings.TabColor)
+	style.SetColor(imgui.StyleColorTabHovered, settings.TabHoveredColor)
+	style.SetColor(imgui.StyleColorTabActive, settings.TabActiveColor)
+	style.SetColor(imgui.StyleColorTabUnfocused, settings.TabColor)
+	style.SetColor(imgui.StyleColorTabUnfocusedActive, settings.TabActiveColor)
+
+	style.SetColor(imgui.StyleColorFrameBg, settings.WidgetBgColor)
+	style.SetColor(imgui.StyleColorFrameBgHovered, settings.WidgetHoveredColor)
+	style.SetColor(imgui.StyleColorButton, settings.WidgetBgColor)
+	style.SetColor(imgui.StyleColorButtonHovered, settings.WidgetHoveredColor)
+	style.SetColor(imgui.StyleColorHeader, settings.WidgetBgColor)
+	style.SetColor(imgui.StyleColorHeaderHovered, settings.WidgetHoveredColor)
+}
+
+// drawThemeMenu draws the theme entries of the View menu
+func (g *Gopad) drawThemeMenu() {
+
+	if !imgui.BeginMenu("Theme") {
+		return
+	}
+
+	for _, t := range g.themes {
+		if imgui.MenuItemV(t.Name, "", strings.EqualFold(t.Name, settings.Theme), true) {
+			g.applyTheme(t)
+			g.setUserSetting("theme", t.Name)
+		}
+	}
+
+	imgui.Separator()
+
+	if imgui.MenuItem("Import VS Code Theme...") {
+		g.themeImport.open = true
+	}
+
+	imgui.EndMenu()
+}
+
+// drawThemeImport draws the popup that asks for the path of a VS Code theme to import
+func (g *Gopad) drawThemeImport() {
+
+	if g.themeImport.open {
+		imgui.OpenPopup("importTheme")
+		g.themeImport.open = false
+	}
+
+	imgui.SetNextWindowPos(imgui.Vec2{X: g.winWidth * 0.5, Y: g.winHeight * 0.5})
+	if !imgui.BeginPopup("importTheme") {
+		return
+	}
+
+	imgui.Text("Path of a VS Code colour theme (.json):")
+	imgui.PushItemWidth(g.winWidth * 0.4)
+	imgui.InputText("##themePath", &g.themeImport.path)
+	imgui.PopItemWidth()
+
+	if imgui.Button("Import") {
+		imgui.CloseCurrentPopup()
+		g.importTheme(g.themeImport.path)
+	}
+
+	imgui.SameLine()
+	if imgui.Button("Cancel") {
+		imgui.CloseCurrentPopup()
+	}
+
+	imgui.EndPopup()
+}
+
+// importTheme converts a VS Code theme, saves it with the user's themes and applies it
+func (g *Gopad) importTheme(fPath string) {
+
+	data, err := os.ReadFile(fPath)
+	if err != nil {
+		g.triggerError("Failed to import theme. Error: " + err.Error())
+		return
+	}
+
+	t, err := theme.ImportVSCode(data)
+	if t == nil {
+		g.triggerError("Failed to import theme '" + fPath + "'. Error: " + err.Error())
+		return
+	}
+
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(fPath), filepath.Ext(fPath))
+	}
+
+	//Colours that couldn't be read are skipped, which is worth knowing but doesn't stop the import
+	if err != nil {
+		g.triggerError("Some colours of theme '" + t.Name + "' were skipped:\n" + err.Error())
+	}
+
+	dir, err := appDataDir("themes")
+	if err != nil {
+		g.triggerError("Failed to save imported theme. Error: " + err.Error())
+		return
+	}
+
+	data, err = t.Marshal()
+	if err != nil {
+		g.triggerError("Failed to save imported theme. Error: " + err.Error())
+		return
+	}
+
+	//Importing a theme again overwrites the old file, which shouldn't be left half written if saving fails
+	t.Path = filepath.Join(dir, themeFileName(t.Name))
+	if err := writeFileAtomic(t.Path, data, 0644); err != nil {
+		g.triggerError("Failed to save imported theme. Error: " + err.Error())
+		return
+	}
+
+	if old := g.findTheme(t.Name); old != nil && old.Path == t.Path {
+		*old = *t
+	} else {
+		g.themes = append(g.themes, t)
+	}
+
+	g.applyTheme(t)
+	g.setUserSetting("theme", t.Name)
+}
+
+// themeFileName returns a file name for a theme that is safe on all systems
+func themeFileName(name string) string {
+
+	b := strings.Builder{}
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+
+	return b.String() + ".json"
+}